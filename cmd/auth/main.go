@@ -22,8 +22,11 @@ func main() {
 
 	log.Info("Starting gRPC server")
 
-	application := app.New(log, cfg.GRPC.Port, cfg.StoragePath, cfg.TokenTTL)
+	application := app.New(log, cfg)
 	go application.GRPCServer.MustRun()
+	if application.HTTPServer != nil {
+		go application.HTTPServer.MustRun()
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
@@ -32,7 +35,9 @@ func main() {
 
 	log.Info("Shutting down gRPC server", "signal", sig)
 
-	application.GRPCServer.Stop()
+	if err := application.Stop(); err != nil {
+		log.Error("failed to close storage", slog.String("error", err.Error()))
+	}
 
 	log.Info("gRPC server stopped")
 }
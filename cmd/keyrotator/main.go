@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/app"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// keyrotator makes a freshly generated key the active signing key of an app.
+// Tokens signed with the previous key stay valid until the overlap ends.
+func main() {
+	var (
+		appID          int
+		overlap        time.Duration
+		revokePrevious bool
+	)
+	flag.IntVar(&appID, "app-id", 0, "id of the app whose signing key is rotated")
+	flag.DurationVar(&overlap, "overlap", 0, "how long the previous key keeps verifying tokens (signing.rotation_overlap if zero)")
+	flag.BoolVar(&revokePrevious, "revoke-previous", false, "retire the previous key at once")
+
+	cfg := config.MustLoad()
+
+	if appID == 0 {
+		panic("app-id is empty")
+	}
+	if cfg.Signing.KeyFile != "" {
+		panic("signing keys are loaded from signing.key_file and cannot be rotated")
+	}
+
+	storage, err := app.NewStorage(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	authService := auth.New(log, storage, cfg, nil, nil, nil, nil)
+
+	key, err := authService.RotateSigningKey(context.Background(), 0, int32(appID), overlap, revokePrevious)
+	_ = storage.Close()
+	if err != nil {
+		fmt.Println("Failed to rotate signing key: ", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Successfully rotated signing key of app %d: kid=%s alg=%s\n", appID, key.ID, key.Algorithm)
+}
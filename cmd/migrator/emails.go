@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/pkg/emailaddr"
+	"strings"
+)
+
+// normalizeEmails fills the normalized emails of the users that have none
+// with the rules of pkg/emailaddr, as SQL cannot apply them. It changes
+// nothing and lists the users to fix by hand if any of them have an invalid
+// email or would share a normalized email with another user of their app.
+func normalizeEmails(db *sql.DB, normalizer emailaddr.Normalizer) error {
+	rows, err := db.Query("SELECT id, app_id, email, email_normalized FROM users ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type key struct {
+		appID      sql.NullInt64
+		normalized string
+	}
+	var (
+		owners   = make(map[key][]int64)
+		order    []key
+		pending  = make(map[int64]string)
+		problems []string
+	)
+	for rows.Next() {
+		var (
+			id         int64
+			appID      sql.NullInt64
+			email      string
+			normalized sql.NullString
+		)
+		if err := rows.Scan(&id, &appID, &email, &normalized); err != nil {
+			return err
+		}
+
+		if !normalized.Valid {
+			address, err := normalizer.Normalize(email)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("user %d has an invalid email %q", id, email))
+				continue
+			}
+			normalized.String = address.Normalized
+			pending[id] = address.Normalized
+		}
+
+		k := key{appID: appID, normalized: normalized.String}
+		if owners[k] == nil {
+			order = append(order, k)
+		}
+		owners[k] = append(owners[k], id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, k := range order {
+		if ids := owners[k]; len(ids) > 1 {
+			problems = append(problems, fmt.Sprintf("users %s of app %d share the email %s",
+				joinIDs(ids), k.appID.Int64, k.normalized))
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("cannot normalize the emails of users, merge or delete them and migrate again:\n  " +
+			strings.Join(problems, "\n  "))
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for id, normalized := range pending {
+		if _, err := tx.Exec("UPDATE users SET email_normalized = ? WHERE id = ?", normalized, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func joinIDs(ids []int64) string {
+	s := make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = fmt.Sprint(id)
+	}
+	return strings.Join(s, ", ")
+}
@@ -1,44 +1,312 @@
 package main
 
 import (
+	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/pkg/emailaddr"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
 
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
 	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
+	_ "github.com/mattn/go-sqlite3"
 )
 
+// Exit codes of the migrator.
+const (
+	exitOK = 0
+	// exitFailed means the command was run but failed.
+	exitFailed = 1
+	// exitUsage means the command line is invalid, as with unknown flags.
+	exitUsage = 2
+)
+
+const usage = `Usage: migrator [flags] [command]
+
+Commands:
+  up [N]          apply the next N migrations, or all pending ones if N is omitted (default command)
+  down N          revert the last N applied migrations
+  goto VERSION    migrate up or down to VERSION
+  force VERSION   set the version without running migrations, e.g. to clear
+                  the dirty flag after fixing a failed migration by hand;
+                  -1 means no migration applied
+  version         print the current version
+  status          list the applied and pending migrations
+
+Flags:
+`
+
 func main() {
-	var storagePath, migrationsPath, migrationsTable string
-	flag.StringVar(&storagePath, "storage-path", "", "path for storage")
-	flag.StringVar(&migrationsPath, "migrations-path", "", "path for migrations")
-	flag.StringVar(&migrationsTable, "migrations-table", "migrations", "name of migrating table")
-	flag.Parse()
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line args and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	var storageDriver, storagePath, migrationsPath, migrationsTable, configPath string
+	var dryRun bool
+	flags := flag.NewFlagSet("migrator", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&storageDriver, "storage-driver", "sqlite", "sqlite or postgres")
+	flags.StringVar(&storagePath, "storage-path", "", "path for storage, or a postgres:// URL for postgres")
+	flags.StringVar(&migrationsPath, "migrations-path", "", "path for migrations")
+	flags.StringVar(&migrationsTable, "migrations-table", "migrations", "name of migrating table")
+	flags.BoolVar(&dryRun, "dry-run", false, "print the SQL of the migrations instead of running them")
+	flags.StringVar(&configPath, "config", "", "config of the service, whose email settings the emails of existing users are normalized with")
+	args, err := parseFlags(flags, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	cmd, err := parseCommand(args)
+	if err == nil && storagePath == "" {
+		err = errors.New("storage-path is empty")
+	}
+	if err == nil && migrationsPath == "" {
+		err = errors.New("migrations-path is empty")
+	}
+	if err == nil && dryRun && cmd.name == "force" {
+		err = errors.New("force does not support --dry-run")
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		flags.Usage()
+		return exitUsage
+	}
+
+	dbURL, err := databaseURL(storageDriver, storagePath, migrationsTable)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	var normalizer emailaddr.Normalizer
+	if configPath != "" {
+		cfg := config.MustLoadByPath(configPath)
+		normalizer = emailaddr.Normalizer{
+			CaseSensitiveLocalPart: cfg.Email.CaseSensitiveLocalPart,
+			AllowUnicode:           cfg.Email.AllowUnicode,
+		}
+	}
+	prereqs := prerequisites(storageDriver, storagePath, normalizer)
+
+	if err := execute(cmd, "file://"+migrationsPath, dbURL, prereqs, dryRun, stdout); err != nil {
+		fmt.Fprintln(stderr, "Failed to migrate:", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// parseFlags parses the flags wherever they are among the command and its
+// arguments and returns the latter. Integers such as -1 are arguments.
+func parseFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for len(args) > 0 {
+		if _, err := strconv.Atoi(args[0]); err == nil {
+			positional = append(positional, args[0])
+			args = args[1:]
+			continue
+		}
+
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
+		}
+	}
+	return positional, nil
+}
+
+// command is a parsed command of the migrator. n is the number of
+// migrations of up and down and the version of goto and force.
+type command struct {
+	name string
+	n    int
+}
+
+func parseCommand(args []string) (command, error) {
+	if len(args) == 0 {
+		return command{name: "up"}, nil
+	}
 
-	if storagePath == "" {
-		panic("storage-path is empty")
+	cmd := command{name: args[0]}
+	args = args[1:]
+	var err error
+	switch cmd.name {
+	case "up":
+		if len(args) == 0 {
+			return cmd, nil
+		}
+		cmd.n, err = parseArg(args, "N", 1)
+	case "down":
+		cmd.n, err = parseArg(args, "N", 1)
+	case "goto":
+		cmd.n, err = parseArg(args, "VERSION", 0)
+	case "force":
+		cmd.n, err = parseArg(args, "VERSION", -1)
+	case "version", "status":
+		if len(args) > 0 {
+			err = fmt.Errorf("%s takes no arguments", cmd.name)
+		}
+	default:
+		err = errors.New("unknown command: " + cmd.name)
+	}
+	return cmd, err
+}
+
+// parseArg parses the only argument of a command, an integer no less than min.
+func parseArg(args []string, name string, min int) (int, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("expected a single %s argument", name)
 	}
-	if migrationsPath == "" {
-		panic("migrations-path is empty")
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < min {
+		return 0, fmt.Errorf("%s must be an integer no less than %d: %s", name, min, args[0])
 	}
+	return n, nil
+}
 
-	m, err := migrate.New(
-		fmt.Sprintf("file://%s", migrationsPath),
-		fmt.Sprintf("sqlite3://%s?x-migrations-table=%s", storagePath, migrationsTable),
-	)
+// execute runs the command against the database, doing the prerequisites of
+// the migrations it applies first.
+func execute(cmd command, sourceURL, dbURL string, prereqs map[migration]prerequisite, dryRun bool, stdout io.Writer) error {
+	src, err := source.Open(sourceURL)
 	if err != nil {
-		panic(err)
+		return err
 	}
+	defer src.Close()
 
-	if err := m.Up(); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			fmt.Println("Failed to migrate: ", err)
-			return
-		} else {
-			panic(err)
+	m, err := migrate.New(sourceURL, dbURL)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	migrations, err := listMigrations(src)
+	if err != nil {
+		return err
+	}
+	state, err := readState(m, migrations)
+	if err != nil {
+		return err
+	}
+
+	switch cmd.name {
+	case "version":
+		fmt.Fprintln(stdout, state)
+		return nil
+	case "status":
+		return printStatus(stdout, migrations, state)
+	case "force":
+		if err := m.Force(cmd.n); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Forced version %d\n", cmd.n)
+		return nil
+	}
+
+	steps, err := plan(cmd, migrations, state)
+	if err != nil {
+		return err
+	}
+	if len(steps) == 0 {
+		fmt.Fprintln(stdout, "No migrations to apply")
+		return nil
+	}
+	if dryRun {
+		return printSteps(stdout, src, steps, prereqs)
+	}
+
+	for _, step := range steps {
+		n := -1
+		if step.up {
+			n = 1
+			if prereq, ok := prereqs[step.migration]; ok {
+				if err := prereq.run(); err != nil {
+					return fmt.Errorf("migration %d: %s: %w", step.version, prereq.description, err)
+				}
+			}
+		}
+		if err := m.Steps(n); err != nil {
+			return err
+		}
+	}
+
+	version, _, err := m.Version()
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+		fmt.Fprintln(stdout, "Successfully migrated, no migrations applied")
+	case err != nil:
+		return err
+	default:
+		fmt.Fprintf(stdout, "Successfully migrated to version %d\n", version)
+	}
+	return nil
+}
+
+// prerequisite is work in Go a migration depends on, done right before the
+// migration is applied. It must be safe to do again if the migration fails.
+type prerequisite struct {
+	description string
+	run         func() error
+}
+
+// prerequisites returns the prerequisites of the migrations of the storage.
+func prerequisites(storageDriver, storagePath string, normalizer emailaddr.Normalizer) map[migration]prerequisite {
+	if storageDriver != "sqlite" {
+		return nil
+	}
+
+	return map[migration]prerequisite{
+		{version: 17, name: "email_normalized_not_null"}: {
+			description: "normalize the emails of existing users",
+			run: func() error {
+				db, err := sql.Open("sqlite3", storagePath)
+				if err != nil {
+					return err
+				}
+				defer db.Close()
+				return normalizeEmails(db, normalizer)
+			},
+		},
+	}
+}
+
+// databaseURL returns the URL golang-migrate opens the storage with.
+func databaseURL(storageDriver, storagePath, migrationsTable string) (string, error) {
+	switch storageDriver {
+	case "sqlite":
+		return fmt.Sprintf("sqlite3://%s?x-migrations-table=%s", storagePath, migrationsTable), nil
+	case "postgres":
+		u, err := url.Parse(storagePath)
+		if err != nil {
+			return "", err
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return "", errors.New("storage-path must be a postgres:// URL")
 		}
+		u.Scheme = "pgx5"
+		q := u.Query()
+		q.Set("x-migrations-table", migrationsTable)
+		u.RawQuery = q.Encode()
+		return u.String(), nil
+	default:
+		return "", errors.New("unknown storage driver: " + storageDriver)
 	}
-	fmt.Println("Successfully migrated")
 }
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+	"io"
+	"os"
+	"slices"
+	"text/tabwriter"
+)
+
+// migration is a migration found in the migrations path.
+type migration struct {
+	version uint
+	name    string
+}
+
+// state is the version of the database.
+type state struct {
+	version uint
+	// applied is the number of migrations applied, so that the current
+	// version is migrations[applied-1].
+	applied int
+	dirty   bool
+}
+
+// step is a migration a command runs, up or down.
+type step struct {
+	migration
+	up bool
+}
+
+// listMigrations returns the migrations of src ordered by version.
+func listMigrations(src source.Driver) ([]migration, error) {
+	version, err := src.First()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []migration
+	for {
+		r, name, err := src.ReadUp(version)
+		if err != nil {
+			return nil, fmt.Errorf("migration %d: %w", version, err)
+		}
+		_ = r.Close()
+		migrations = append(migrations, migration{version: version, name: name})
+
+		next, err := src.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return migrations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		version = next
+	}
+}
+
+// readState returns the state of the database m migrates.
+func readState(m *migrate.Migrate, migrations []migration) (state, error) {
+	version, dirty, err := m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return state{}, nil
+	}
+	if err != nil {
+		return state{}, err
+	}
+
+	i := slices.IndexFunc(migrations, func(m migration) bool { return m.version == version })
+	if i < 0 {
+		return state{}, fmt.Errorf("database version %d is not among the migrations", version)
+	}
+	return state{version: version, applied: i + 1, dirty: dirty}, nil
+}
+
+// plan returns the steps of an up, down or goto command, in the order they run.
+func plan(cmd command, migrations []migration, current state) ([]step, error) {
+	if current.dirty {
+		return nil, fmt.Errorf("database version %d is dirty: fix it by hand and force the version", current.version)
+	}
+
+	target := len(migrations)
+	switch cmd.name {
+	case "up":
+		if cmd.n > 0 {
+			if pending := len(migrations) - current.applied; cmd.n > pending {
+				return nil, fmt.Errorf("cannot apply %d migrations: %d pending", cmd.n, pending)
+			}
+			target = current.applied + cmd.n
+		}
+	case "down":
+		if cmd.n > current.applied {
+			return nil, fmt.Errorf("cannot revert %d migrations: %d applied", cmd.n, current.applied)
+		}
+		target = current.applied - cmd.n
+	case "goto":
+		i := slices.IndexFunc(migrations, func(m migration) bool { return m.version == uint(cmd.n) })
+		if i < 0 {
+			return nil, fmt.Errorf("no migration with version %d", cmd.n)
+		}
+		target = i + 1
+	}
+
+	var steps []step
+	for i := current.applied; i < target; i++ {
+		steps = append(steps, step{migration: migrations[i], up: true})
+	}
+	for i := current.applied - 1; i >= target; i-- {
+		steps = append(steps, step{migration: migrations[i]})
+	}
+	return steps, nil
+}
+
+// printSteps prints the SQL of the steps, each headed by its file name and
+// the prerequisite done before it, if any.
+func printSteps(w io.Writer, src source.Driver, steps []step, prereqs map[migration]prerequisite) error {
+	for _, step := range steps {
+		read, direction := src.ReadUp, "up"
+		if !step.up {
+			read, direction = src.ReadDown, "down"
+		}
+		if prereq, ok := prereqs[step.migration]; ok && step.up {
+			fmt.Fprintf(w, "-- before %d_%s.up.sql: %s (in Go)\n", step.version, step.name, prereq.description)
+		}
+
+		r, _, err := read(step.version)
+		if err != nil {
+			return fmt.Errorf("migration %d: %w", step.version, err)
+		}
+		fmt.Fprintf(w, "-- %d_%s.%s.sql\n", step.version, step.name, direction)
+		_, err = io.Copy(w, r)
+		_ = r.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// String describes the version as the version command prints it.
+func (s state) String() string {
+	switch {
+	case s.applied == 0:
+		return "no migrations applied"
+	case s.dirty:
+		return fmt.Sprintf("version %d (dirty)", s.version)
+	default:
+		return fmt.Sprintf("version %d", s.version)
+	}
+}
+
+// printStatus lists the migrations and whether they are applied.
+func printStatus(w io.Writer, migrations []migration, current state) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, m := range migrations {
+		status := "pending"
+		switch {
+		case i == current.applied-1 && current.dirty:
+			status = "dirty"
+		case i < current.applied:
+			status = "applied"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.version, m.name, status)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%s, %d pending\n", current, len(migrations)-current.applied)
+	return nil
+}
@@ -2,33 +2,136 @@ package app
 
 import (
 	grpcapp "github.com/qu0ta/go-grpc-auth/internal/app/grpc"
+	httpapp "github.com/qu0ta/go-grpc-auth/internal/app/http"
+	purgeapp "github.com/qu0ta/go-grpc-auth/internal/app/purge"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/internal/notifier"
 	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/postgres"
 	"github.com/qu0ta/go-grpc-auth/internal/storage/sqlite"
+	"github.com/qu0ta/go-grpc-auth/pkg/breached"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"github.com/qu0ta/go-grpc-auth/pkg/passhash"
+	"io"
 	"log/slog"
-	"time"
+	"os"
 )
 
+// Storage is an auth.Storage holding resources released by Close.
+type Storage interface {
+	auth.Storage
+	io.Closer
+}
+
 type App struct {
 	GRPCServer *grpcapp.App
+	// HTTPServer is nil unless an HTTP port is configured.
+	HTTPServer *httpapp.App
+	// PurgeJob purges deleted accounts. It is started by New.
+	PurgeJob *purgeapp.App
+	// Storage is closed by Stop once nothing uses it anymore.
+	Storage Storage
+
+	auth *auth.Auth
 }
 
-func New(
-	log *slog.Logger,
-	grpcPort int,
-	storagePath string,
-	tokenTTL time.Duration,
-) *App {
-	storage, err := sqlite.New(storagePath)
+func New(log *slog.Logger, cfg *config.Config) *App {
+	storage, err := NewStorage(cfg)
 	if err != nil {
 		panic(err)
 	}
 
-	authService := auth.New(log, storage, tokenTTL)
-	grpcApp := grpcapp.New(log, grpcPort, authService)
+	var globalKey *jwt.Key
+	if cfg.Signing.KeyFile != "" {
+		key, err := jwt.LoadKeyFile(cfg.Signing.KeyFile)
+		if err != nil {
+			panic(err)
+		}
+		if key.Algorithm != cfg.Signing.Algorithm {
+			panic("signing key file does not match algorithm " + cfg.Signing.Algorithm)
+		}
+		globalKey = &key
+	}
+
+	hasher, err := passhash.New(cfg.Passwords.Algorithm, cfg.Passwords.BcryptCost, passhash.Argon2idParams{
+		Time:       cfg.Passwords.Argon2id.Time,
+		Memory:     cfg.Passwords.Argon2id.MemoryKiB,
+		Threads:    cfg.Passwords.Argon2id.Threads,
+		SaltLength: cfg.Passwords.Argon2id.SaltLength,
+		KeyLength:  cfg.Passwords.Argon2id.KeyLength,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var breachedPasswords auth.BreachedPasswords
+	if path := cfg.PasswordPolicy.BreachedPasswordsFile; path != "" {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			ranges, err := breached.OpenRanges(path)
+			if err != nil {
+				panic(err)
+			}
+			log.Info("breached password ranges opened", slog.String("dir", path))
+			breachedPasswords = ranges
+		} else {
+			list, err := breached.Load(path)
+			if err != nil {
+				panic(err)
+			}
+			log.Info("breached passwords loaded", slog.Int("count", list.Len()))
+			breachedPasswords = list
+		}
+	}
+
+	authService := auth.New(log, storage, cfg, globalKey, hasher, breachedPasswords, notifier.NewLog(log))
+	grpcApp := grpcapp.New(log, cfg.GRPC.Port, authService, authService)
+
+	var httpApp *httpapp.App
+	if cfg.HTTP.Port != 0 {
+		httpApp = httpapp.New(log, cfg.HTTP.Port, authService)
+	}
+
+	purgeJob := purgeapp.New(log, authService, cfg.Deletion.PurgeInterval)
+	go purgeJob.Run()
+
 	return &App{
 		GRPCServer: grpcApp,
+		HTTPServer: httpApp,
+		PurgeJob:   purgeJob,
+		Storage:    storage,
+		auth:       authService,
+	}
+
+}
+
+// Stop stops the servers and the purge job, waits for the auth service to
+// finish its background work and closes the storage.
+func (a *App) Stop() error {
+	a.GRPCServer.Stop()
+	if a.HTTPServer != nil {
+		a.HTTPServer.Stop()
 	}
+	a.PurgeJob.Stop()
+	a.auth.Wait()
+	return a.Storage.Close()
+}
 
+// NewStorage opens the storage selected by cfg.StorageDriver.
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		return postgres.New(cfg.StoragePath)
+	default:
+		return sqlite.New(cfg.StoragePath, sqlite.Options{
+			JournalMode:     cfg.SQLite.JournalMode,
+			BusyTimeout:     cfg.SQLite.BusyTimeout,
+			NoForeignKeys:   !cfg.SQLite.ForeignKeys,
+			Synchronous:     cfg.SQLite.Synchronous,
+			MaxOpenConns:    cfg.SQLite.MaxOpenConns,
+			MaxIdleConns:    cfg.SQLite.MaxIdleConns,
+			ConnMaxLifetime: cfg.SQLite.ConnMaxLifetime,
+		})
+	}
 }
 
 func (a *App) Run() error {
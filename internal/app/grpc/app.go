@@ -2,6 +2,7 @@ package grpcapp
 
 import (
 	"fmt"
+	admingrpc "github.com/qu0ta/go-grpc-auth/internal/grpc/admin"
 	authgrpc "github.com/qu0ta/go-grpc-auth/internal/grpc/auth"
 	"google.golang.org/grpc"
 	"log/slog"
@@ -19,13 +20,16 @@ type App struct {
 // Parameters:
 // - log: a pointer to a slog.Logger instance for logging.
 // - port: an integer representing the port number to listen on.
+// - authService: the implementation of the Auth service.
+// - adminService: the implementation of the Admin service.
 //
 // Returns:
 // - a pointer to an App instance.
-func New(log *slog.Logger, port int, authService authgrpc.Auth) *App {
+func New(log *slog.Logger, port int, authService authgrpc.Auth, adminService admingrpc.Admin) *App {
 	gRPCServer := grpc.NewServer()
 
 	authgrpc.Register(gRPCServer, authService)
+	admingrpc.Register(gRPCServer, adminService)
 
 	return &App{
 		log:        log,
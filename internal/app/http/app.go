@@ -0,0 +1,78 @@
+package httpapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/http/jwks"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+type App struct {
+	log        *slog.Logger
+	httpServer *http.Server
+	port       int
+}
+
+// New creates the HTTP server publishing the JWKS document of jwksProvider.
+func New(log *slog.Logger, port int, jwksProvider jwks.Provider) *App {
+	mux := http.NewServeMux()
+
+	jwks.Register(mux, log, jwksProvider)
+
+	return &App{
+		log: log,
+		httpServer: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+		port: port,
+	}
+}
+
+// Run starts the HTTP server and blocks until it is stopped.
+func (a *App) Run() error {
+	const op = "httpapp.Run"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("port", a.port),
+	)
+
+	l, err := net.Listen("tcp", fmt.Sprintf(":%d", a.port))
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("Starting HTTP server", slog.String("addr", l.Addr().String()))
+
+	if err := a.httpServer.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// MustRun starts the Run() method and panics if an error is encountered.
+func (a *App) MustRun() {
+	if err := a.Run(); err != nil {
+		panic(err)
+	}
+}
+
+// Stop gracefully shuts down the HTTP server, waiting for in-flight requests.
+func (a *App) Stop() {
+	const op = "httpapp.Stop"
+	a.log.With(slog.String("op", op)).Info("Stopping HTTP server", slog.Int("port", a.port))
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := a.httpServer.Shutdown(ctx); err != nil {
+		a.log.With(slog.String("op", op)).Error("failed to stop HTTP server", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,68 @@
+package purgeapp
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger removes the accounts whose deletion grace period has passed.
+type Purger interface {
+	PurgeDeletedAccounts(ctx context.Context) (purged int64, err error)
+}
+
+// App periodically purges deleted accounts in the background.
+type App struct {
+	log      *slog.Logger
+	purger   Purger
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func New(log *slog.Logger, purger Purger, interval time.Duration) *App {
+	return &App{
+		log:      log,
+		purger:   purger,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run purges deleted accounts once per interval until Stop is called.
+func (a *App) Run() {
+	const op = "purgeapp.Run"
+
+	defer close(a.done)
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Duration("interval", a.interval),
+	)
+
+	log.Info("Starting account purge job")
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		// Errors are logged by the purger and retried on the next tick.
+		_, _ = a.purger.PurgeDeletedAccounts(context.Background())
+
+		select {
+		case <-ticker.C:
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the job and waits for a running purge to finish.
+func (a *App) Stop() {
+	const op = "purgeapp.Stop"
+	a.log.With(slog.String("op", op)).Info("Stopping account purge job")
+
+	close(a.stop)
+	<-a.done
+}
@@ -3,21 +3,201 @@ package config
 import (
 	"flag"
 	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"github.com/qu0ta/go-grpc-auth/pkg/passhash"
 	"os"
 	"time"
 )
 
 type Config struct {
-	Env         string        `yaml:"env" env-default:"local"`
-	StoragePath string        `yaml:"storage_path" env-required:"true"`
-	TokenTTL    time.Duration `yaml:"token_ttl" env-required:"true"`
-	GRPC        GRPCConfig    `yaml:"grpc"`
+	Env            string               `yaml:"env" env-default:"local"`
+	StorageDriver  string               `yaml:"storage_driver" env:"STORAGE_DRIVER" env-default:"sqlite"`
+	StoragePath    string               `yaml:"storage_path" env:"STORAGE_PATH" env-required:"true"`
+	SQLite         SQLiteConfig         `yaml:"sqlite"`
+	TokenTTL       time.Duration        `yaml:"token_ttl" env-required:"true"`
+	Refresh        RefreshConfig        `yaml:"refresh"`
+	Signing        SigningConfig        `yaml:"signing"`
+	Revocation     RevocationConfig     `yaml:"revocation"`
+	MFA            MFAConfig            `yaml:"mfa"`
+	PasswordReset  PasswordResetConfig  `yaml:"password_reset"`
+	Verification   VerificationConfig   `yaml:"email_verification"`
+	Deletion       DeletionConfig       `yaml:"account_deletion"`
+	LoginThrottle  LoginThrottleConfig  `yaml:"login_throttle"`
+	Admin          AdminConfig          `yaml:"admin"`
+	Passwords      PasswordsConfig      `yaml:"password_hashing"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password_policy"`
+	Email          EmailConfig          `yaml:"email"`
+	GRPC           GRPCConfig           `yaml:"grpc"`
+	HTTP           HTTPConfig           `yaml:"http"`
+}
+
+// SQLiteConfig tunes the connections to the SQLite database. It is ignored by
+// the other storage drivers.
+type SQLiteConfig struct {
+	// JournalMode is the journal_mode pragma. WAL lets reads run alongside a write.
+	JournalMode string `yaml:"journal_mode" env-default:"WAL"`
+	// BusyTimeout is how long a statement waits for the lock held by another
+	// connection before failing.
+	BusyTimeout time.Duration `yaml:"busy_timeout" env-default:"5s"`
+	// ForeignKeys enforces the foreign keys of the schema. A false in the
+	// file reads as unset, so they can only be turned off with
+	// SQLITE_FOREIGN_KEYS=false.
+	ForeignKeys bool `yaml:"foreign_keys" env:"SQLITE_FOREIGN_KEYS" env-default:"true"`
+	// Synchronous is the synchronous pragma. NORMAL is safe with WAL and
+	// syncs far less often than FULL.
+	Synchronous     string        `yaml:"synchronous" env-default:"NORMAL"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env-default:"10"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+}
+type RefreshConfig struct {
+	TTL      time.Duration `yaml:"ttl" env-default:"720h"`
+	Rotation string        `yaml:"rotation" env-default:"strict"`
+}
+type SigningConfig struct {
+	// Algorithm is one of HS256, RS256, ES256 or EdDSA. HS256 signs with the app secret.
+	Algorithm string `yaml:"algorithm" env-default:"HS256"`
+	// KeyFile is a PEM private key shared by all apps. If empty, a key pair is
+	// generated for every app and stored in the database.
+	KeyFile string `yaml:"key_file"`
+	// RotationOverlap is how long the previous key keeps verifying tokens after
+	// a rotation. It should not be shorter than TokenTTL.
+	RotationOverlap time.Duration `yaml:"rotation_overlap" env-default:"24h"`
+}
+
+// RevocationConfig sizes the in-memory cache of revoked sessions consulted on
+// every token validation.
+type RevocationConfig struct {
+	CacheSize int `yaml:"cache_size" env-default:"10000"`
+	// CacheTTL is how long a session is assumed not revoked before the storage
+	// is asked again. It bounds how late revocations made by other replicas are seen.
+	CacheTTL time.Duration `yaml:"cache_ttl" env-default:"30s"`
+}
+
+// MFAConfig configures the second step of logins of users with TOTP enabled.
+type MFAConfig struct {
+	// ChallengeTTL is how long the token returned by Login can be exchanged in VerifyMFA.
+	ChallengeTTL time.Duration `yaml:"challenge_ttl" env-default:"5m"`
+	// MaxAttempts is the number of wrong codes after which a challenge is rejected.
+	MaxAttempts int `yaml:"max_attempts" env-default:"5"`
+	// RecoveryCodes is the number of recovery codes issued on confirmation.
+	RecoveryCodes int `yaml:"recovery_codes" env-default:"10"`
+}
+type PasswordResetConfig struct {
+	// TokenTTL is how long a password reset token can be used.
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"1h"`
+}
+type VerificationConfig struct {
+	// TokenTTL is how long an email verification token can be used.
+	TokenTTL time.Duration `yaml:"token_ttl" env-default:"24h"`
+}
+
+// DeletionConfig configures how long deleted accounts are kept.
+type DeletionConfig struct {
+	// GracePeriod is how long a deleted account is kept before it is purged.
+	GracePeriod time.Duration `yaml:"grace_period" env-default:"720h"`
+	// PurgeInterval is how often deleted accounts past the grace period are purged.
+	PurgeInterval time.Duration `yaml:"purge_interval" env-default:"1h"`
+}
+
+// LoginThrottleConfig configures the protection of Login against password
+// guessing. Failed logins are counted per account and per client address.
+type LoginThrottleConfig struct {
+	Account ThrottleConfig `yaml:"account"`
+	IP      ThrottleConfig `yaml:"ip"`
+}
+
+// ThrottleConfig configures how failed logins of an account or an address are
+// slowed down and then locked out.
+type ThrottleConfig struct {
+	// BackoffAfter is the number of failures after which logins are blocked for
+	// BaseDelay, doubled on every further failure up to MaxDelay.
+	BackoffAfter int           `yaml:"backoff_after" env-default:"3"`
+	BaseDelay    time.Duration `yaml:"base_delay" env-default:"1s"`
+	MaxDelay     time.Duration `yaml:"max_delay" env-default:"1m"`
+	// LockoutAfter is the number of failures after which logins are blocked for LockoutDuration.
+	LockoutAfter    int           `yaml:"lockout_after" env-default:"10"`
+	LockoutDuration time.Duration `yaml:"lockout_duration" env-default:"15m"`
+	// Window is how long failures are remembered after the last one.
+	Window time.Duration `yaml:"window" env-default:"1h"`
+}
+
+// AdminConfig configures who administers the service. The admins of an app
+// manage that app, its signing keys and the roles of its users.
+type AdminConfig struct {
+	// AppID is the app whose admins administer the service as a whole: they
+	// manage every app and create apps and roles. No one does if it is 0.
+	AppID int32 `yaml:"app_id"`
+}
+
+// PasswordsConfig configures how passwords are hashed. Hashes made with
+// another algorithm or other parameters keep working and are replaced on the
+// next successful login.
+type PasswordsConfig struct {
+	// Algorithm is argon2id or bcrypt.
+	Algorithm  string         `yaml:"algorithm" env-default:"argon2id"`
+	BcryptCost int            `yaml:"bcrypt_cost" env-default:"10"`
+	Argon2id   Argon2idConfig `yaml:"argon2id"`
+}
+type Argon2idConfig struct {
+	// Time is the number of passes over the memory.
+	Time uint32 `yaml:"time" env-default:"3"`
+	// MemoryKiB is the memory used by a single hash in KiB.
+	MemoryKiB  uint32 `yaml:"memory_kib" env-default:"65536"`
+	Threads    uint8  `yaml:"threads" env-default:"4"`
+	SaltLength uint32 `yaml:"salt_length" env-default:"16"`
+	KeyLength  uint32 `yaml:"key_length" env-default:"32"`
+}
+
+// PasswordPolicyConfig configures the checks of new passwords shared by all
+// apps. The rules themselves are part of every app.
+type PasswordPolicyConfig struct {
+	// BreachedPasswordsFile lists SHA-1 hashes of breached passwords in one of
+	// the formats of the Pwned Passwords downloads: a file of at most
+	// breached.MaxLen hashes, one per line, or a directory of range files
+	// named after the hash prefix. No password is considered breached if it is
+	// empty.
+	BreachedPasswordsFile string `yaml:"breached_passwords_file"`
+}
+
+// EmailConfig configures how emails are normalized before they are stored or
+// looked up. Changing it does not renormalize the emails already stored.
+type EmailConfig struct {
+	// CaseSensitiveLocalPart keeps the case of the part before the "@", so
+	// that emails differing only in its case belong to different users.
+	CaseSensitiveLocalPart bool `yaml:"case_sensitive_local_part"`
+	// AllowUnicode accepts internationalized emails instead of rejecting them.
+	AllowUnicode bool `yaml:"allow_unicode"`
 }
 type GRPCConfig struct {
-	Port    int           `yaml:"port"`
+	Port    int           `yaml:"port" env:"GRPC_PORT"`
 	Timeout time.Duration `yaml:"timeout"`
 }
 
+// HTTPConfig configures the optional HTTP server publishing the JWKS document.
+// It is disabled when Port is 0.
+type HTTPConfig struct {
+	Port int `yaml:"port"`
+}
+
+// Storage drivers. StoragePath is the path of the database file for
+// StorageSQLite and the connection string for StoragePostgres.
+const (
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+)
+
+// Refresh token rotation policies.
+const (
+	// RotationNone keeps a refresh token valid until it expires.
+	RotationNone = "none"
+	// RotationAlways issues a new refresh token on every use and rejects the old one.
+	RotationAlways = "always"
+	// RotationStrict rotates like RotationAlways and additionally revokes every
+	// token issued from the same login once an old token is presented again.
+	RotationStrict = "strict"
+)
+
 func MustLoad() *Config {
 	path := fetchConfigPath()
 	if path == "" {
@@ -38,6 +218,28 @@ func MustLoadByPath(configPath string) *Config {
 		panic("failed to read config: " + err.Error())
 	}
 
+	if cfg.StorageDriver != StorageSQLite && cfg.StorageDriver != StoragePostgres {
+		panic("unknown storage driver: " + cfg.StorageDriver)
+	}
+
+	switch cfg.Refresh.Rotation {
+	case RotationNone, RotationAlways, RotationStrict:
+	default:
+		panic("unknown refresh rotation policy: " + cfg.Refresh.Rotation)
+	}
+
+	if cfg.Passwords.Algorithm != passhash.Argon2id && cfg.Passwords.Algorithm != passhash.Bcrypt {
+		panic("unknown password hashing algorithm: " + cfg.Passwords.Algorithm)
+	}
+
+	if cfg.Signing.Algorithm != jwt.AlgHS256 && !jwt.IsAsymmetric(cfg.Signing.Algorithm) {
+		panic("unknown signing algorithm: " + cfg.Signing.Algorithm)
+	}
+
+	if cfg.Deletion.PurgeInterval <= 0 {
+		panic("account_deletion.purge_interval must be positive")
+	}
+
 	return &cfg
 }
 
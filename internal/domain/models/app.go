@@ -4,4 +4,36 @@ type App struct {
 	ID     int64
 	Name   string
 	Secret string
+	// RequireVerifiedEmail refuses logins of users who have not verified their email yet.
+	RequireVerifiedEmail bool
+	PasswordPolicy       PasswordPolicy
+	// Audiences are the audiences the tokens of the app are meant for.
+	Audiences []string
+	// RedirectURIs are the URIs users of the app may be sent back to after signing in.
+	RedirectURIs []string
+	// Disabled apps keep their users but issue no tokens.
+	Disabled bool
+}
+
+// PasswordPolicy is what the passwords of the users of an app must satisfy.
+type PasswordPolicy struct {
+	MinLength int
+	// MaxLength is counted in bytes, as bcrypt ignores everything after the 72nd byte.
+	MaxLength int
+	// MinClasses is the number of character classes (lowercase and uppercase
+	// letters, digits and symbols) a password must mix.
+	MinClasses int
+	// ForbidEmail rejects passwords containing the email of the user or its local part.
+	ForbidEmail bool
+	// CheckBreached rejects passwords found in the list of breached passwords, if one is configured.
+	CheckBreached bool
+}
+
+// DefaultPasswordPolicy is the policy of apps created without one.
+var DefaultPasswordPolicy = PasswordPolicy{
+	MinLength:     8,
+	MaxLength:     72,
+	MinClasses:    1,
+	ForbidEmail:   true,
+	CheckBreached: true,
 }
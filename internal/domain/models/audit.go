@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Audit actions.
+const (
+	AuditSigningKeyRotated = "signing_key.rotated"
+	AuditRoleCreated       = "role.created"
+	AuditRoleAssigned      = "role.assigned"
+	AuditRoleUnassigned    = "role.unassigned"
+	AuditAccountUnlocked   = "account.unlocked"
+	AuditAppCreated        = "app.created"
+	AuditAppUpdated        = "app.updated"
+	AuditAppSecretRotated  = "app.secret_rotated"
+	AuditAppDisabled       = "app.disabled"
+	AuditAppEnabled        = "app.enabled"
+	AuditAppDeleted        = "app.deleted"
+)
+
+type AuditEvent struct {
+	ID int64
+	// UserID is the user who performed the action, or 0 for system and CLI actions.
+	UserID    int64
+	AppID     int32
+	Action    string
+	Details   string
+	CreatedAt time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Claims struct {
+	TokenID       string
+	UserID        int64
+	Email         string
+	EmailVerified bool
+	AppID         int32
+	SessionID     string
+	Roles         []string
+	ExpiresAt     time.Time
+}
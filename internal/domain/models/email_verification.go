@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// EmailVerification proves that the user can receive mail at Email. It is
+// only valid while the user still has that email.
+type EmailVerification struct {
+	ID        int64
+	UserID    int64
+	Email     string
+	TokenHash []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	Used      bool
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scopes of failed login counters.
+const (
+	// LoginScopeAccount counts the failures of an email in an app.
+	LoginScopeAccount = "account"
+	// LoginScopeIP counts the failures of a client address.
+	LoginScopeIP = "ip"
+)
+
+// AccountLoginKey returns the key of the failed login counter of the email in
+// the app. The storages build the same key in SQL to drop the counters of
+// purged users.
+func AccountLoginKey(appID int32, normalizedEmail string) string {
+	return fmt.Sprintf("%d:%s", appID, normalizedEmail)
+}
+
+// LoginFailures counts the recent failed logins of an account or a client address.
+type LoginFailures struct {
+	Scope    string
+	Key      string
+	Failures int
+	// BlockedUntil is when logins are allowed again, zero if they are not blocked.
+	BlockedUntil  time.Time
+	LastFailureAt time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// MFA is the TOTP enrollment of a user. It only protects logins once Confirmed.
+type MFA struct {
+	UserID    int64
+	Secret    string
+	Confirmed bool
+	// LastStep is the TOTP step of the last accepted code. Codes of this or
+	// earlier steps are refused so that a code cannot be replayed.
+	LastStep  int64
+	CreatedAt time.Time
+}
+
+// MFAChallenge is the pending second step of a login whose password has been checked.
+type MFAChallenge struct {
+	ID        int64
+	TokenHash []byte
+	UserID    int64
+	AppID     int32
+	Attempts  int
+	ExpiresAt time.Time
+	Used      bool
+}
+
+// MFAEnrollment is returned when a user starts enrolling an authenticator app.
+type MFAEnrollment struct {
+	Secret string
+	URI    string
+}
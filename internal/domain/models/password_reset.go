@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type PasswordReset struct {
+	ID        int64
+	UserID    int64
+	TokenHash []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	Used      bool
+}
@@ -0,0 +1,13 @@
+package models
+
+// RoleAdmin is the built-in role checked by IsAdmin. It holds PermissionAll.
+const RoleAdmin = "admin"
+
+// PermissionAll grants every permission to the holders of a role.
+const PermissionAll = "*"
+
+type Role struct {
+	ID          int64
+	Name        string
+	Permissions []string
+}
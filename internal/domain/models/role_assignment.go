@@ -0,0 +1,7 @@
+package models
+
+// RoleAssignment is a role a user holds in an app.
+type RoleAssignment struct {
+	AppID int32
+	Role  string
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID        int64
+	UserID    int64
+	AppID     int32
+	Family    string
+	TokenHash []byte
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	Rotated   bool
+	Revoked   bool
+}
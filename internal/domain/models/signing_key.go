@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Signing key states. An app has at most one active key, which signs new
+// tokens. Verifying keys only check tokens issued before a rotation and
+// become retired once RetiresAt has passed.
+const (
+	KeyStatusActive    = "active"
+	KeyStatusVerifying = "verifying"
+	KeyStatusRetired   = "retired"
+)
+
+type SigningKey struct {
+	ID         string
+	AppID      int32
+	Algorithm  string
+	PrivateKey []byte
+	Status     string
+	CreatedAt  time.Time
+	RetiresAt  time.Time
+}
+
+// CanVerify reports whether tokens signed with the key are still accepted at t.
+func (k SigningKey) CanVerify(t time.Time) bool {
+	switch k.Status {
+	case KeyStatusActive:
+		return true
+	case KeyStatusVerifying:
+		return k.RetiresAt.IsZero() || t.Before(k.RetiresAt)
+	}
+	return false
+}
@@ -0,0 +1,9 @@
+package models
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	// MFAToken is set instead of the tokens when the user has to complete
+	// the login with a second factor.
+	MFAToken string
+}
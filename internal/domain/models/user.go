@@ -1,8 +1,12 @@
 package models
 
 type User struct {
-	ID           int64
-	Email        string
-	PasswordHash []byte
-	AppID        int32
+	ID    int64
+	Email string
+	// NormalizedEmail is the email in the normalized form it was stored with,
+	// which keys the account among the users and the failed logins of the app.
+	NormalizedEmail string
+	PasswordHash    []byte
+	AppID           int32
+	EmailVerified   bool
 }
@@ -0,0 +1,228 @@
+package admin
+
+import (
+	"context"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/grpc/grpcerr"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxPasswordClasses is the number of character classes a password policy can require.
+const maxPasswordClasses = 4
+
+type appServerAPI struct {
+	authv1.UnimplementedAppServiceServer
+	admin Admin
+}
+
+func (s *appServerAPI) CreateApp(ctx context.Context, req *authv1.CreateAppRequest) (*authv1.CreateAppResponse, error) {
+	if err := validateApp(req.GetName(), req.GetSettings()); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := s.admin.CreateApp(ctx, actorID, appFromRequest(0, req.GetName(), req.GetSettings()))
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.CreateAppResponse{App: appToProto(app), Secret: app.Secret}, nil
+}
+
+func (s *appServerAPI) GetApp(ctx context.Context, req *authv1.GetAppRequest) (*authv1.GetAppResponse, error) {
+	if err := validateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	if _, err := authorize(ctx, s.admin, req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	app, err := s.admin.App(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.GetAppResponse{App: appToProto(app)}, nil
+}
+
+func (s *appServerAPI) ListApps(ctx context.Context, _ *authv1.ListAppsRequest) (*authv1.ListAppsResponse, error) {
+	if _, err := authorize(ctx, s.admin, 0); err != nil {
+		return nil, err
+	}
+
+	apps, err := s.admin.Apps(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	resp := &authv1.ListAppsResponse{Apps: make([]*authv1.App, 0, len(apps))}
+	for _, app := range apps {
+		resp.Apps = append(resp.Apps, appToProto(app))
+	}
+	return resp, nil
+}
+
+func (s *appServerAPI) UpdateApp(ctx context.Context, req *authv1.UpdateAppRequest) (*authv1.UpdateAppResponse, error) {
+	if err := validateUpdateApp(req); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := s.admin.UpdateApp(ctx, actorID, appFromRequest(req.GetAppId(), req.GetName(), req.GetSettings()))
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.UpdateAppResponse{App: appToProto(app)}, nil
+}
+
+func (s *appServerAPI) RotateAppSecret(ctx context.Context, req *authv1.RotateAppSecretRequest) (*authv1.RotateAppSecretResponse, error) {
+	if err := validateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := s.admin.RotateAppSecret(ctx, actorID, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.RotateAppSecretResponse{Secret: secret}, nil
+}
+
+func (s *appServerAPI) SetAppDisabled(ctx context.Context, req *authv1.SetAppDisabledRequest) (*authv1.SetAppDisabledResponse, error) {
+	if err := validateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetAppDisabled(ctx, actorID, req.GetAppId(), req.GetDisabled()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.SetAppDisabledResponse{}, nil
+}
+
+func (s *appServerAPI) DeleteApp(ctx context.Context, req *authv1.DeleteAppRequest) (*authv1.DeleteAppResponse, error) {
+	if err := validateAppID(req.GetAppId()); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.DeleteApp(ctx, actorID, req.GetAppId()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.DeleteAppResponse{}, nil
+}
+
+// appFromRequest returns the app described by a request. An unset password
+// policy stands for models.DefaultPasswordPolicy.
+func appFromRequest(appID int32, name string, settings *authv1.AppSettings) models.App {
+	policy := models.DefaultPasswordPolicy
+	if p := settings.GetPasswordPolicy(); p != nil {
+		policy = models.PasswordPolicy{
+			MinLength:     int(p.GetMinLength()),
+			MaxLength:     int(p.GetMaxLength()),
+			MinClasses:    int(p.GetMinClasses()),
+			ForbidEmail:   p.GetForbidEmail(),
+			CheckBreached: p.GetCheckBreached(),
+		}
+	}
+
+	return models.App{
+		ID:                   int64(appID),
+		Name:                 name,
+		RequireVerifiedEmail: settings.GetRequireVerifiedEmail(),
+		PasswordPolicy:       policy,
+		Audiences:            settings.GetAudiences(),
+		RedirectURIs:         settings.GetRedirectUris(),
+	}
+}
+
+// appToProto describes the app without its secret.
+func appToProto(app models.App) *authv1.App {
+	return &authv1.App{
+		Id:   int32(app.ID),
+		Name: app.Name,
+		Settings: &authv1.AppSettings{
+			RequireVerifiedEmail: app.RequireVerifiedEmail,
+			PasswordPolicy: &authv1.PasswordPolicy{
+				MinLength:     int32(app.PasswordPolicy.MinLength),
+				MaxLength:     int32(app.PasswordPolicy.MaxLength),
+				MinClasses:    int32(app.PasswordPolicy.MinClasses),
+				ForbidEmail:   app.PasswordPolicy.ForbidEmail,
+				CheckBreached: app.PasswordPolicy.CheckBreached,
+			},
+			Audiences:    app.Audiences,
+			RedirectUris: app.RedirectURIs,
+		},
+		Disabled: app.Disabled,
+	}
+}
+
+func validateAppID(appID int32) error {
+	var v grpcerr.Validation
+	v.Check(appID != 0, "app_id", "is required")
+	return v.Err()
+}
+
+func validateUpdateApp(req *authv1.UpdateAppRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	checkApp(&v, req.GetName(), req.GetSettings())
+	return v.Err()
+}
+
+func validateApp(name string, settings *authv1.AppSettings) error {
+	var v grpcerr.Validation
+	checkApp(&v, name, settings)
+	return v.Err()
+}
+
+func checkApp(v *grpcerr.Validation, name string, settings *authv1.AppSettings) {
+	v.Check(name != "", "name", "is required")
+
+	if policy := settings.GetPasswordPolicy(); policy != nil {
+		v.Check(policy.GetMinLength() > 0, "settings.password_policy.min_length", "must be positive")
+		v.Check(policy.GetMaxLength() == 0 || policy.GetMaxLength() >= policy.GetMinLength(),
+			"settings.password_policy.max_length", "must be zero or at least min_length")
+		v.Check(policy.GetMinClasses() >= 0 && policy.GetMinClasses() <= maxPasswordClasses,
+			"settings.password_policy.min_classes", "must be between 0 and 4")
+	}
+
+	v.Check(!slices.Contains(settings.GetAudiences(), ""), "settings.audiences", "must not contain empty values")
+	v.Check(!slices.ContainsFunc(settings.GetRedirectUris(), func(uri string) bool { return !isRedirectURI(uri) }),
+		"settings.redirect_uris", "must contain absolute URIs without a fragment")
+}
+
+// isRedirectURI reports whether uri is an absolute URI without a fragment, as
+// OAuth 2.0 requires of redirection endpoints.
+func isRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && u.IsAbs() && !strings.Contains(uri, "#")
+}
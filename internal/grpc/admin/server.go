@@ -0,0 +1,253 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/grpc/bearer"
+	"github.com/qu0ta/go-grpc-auth/internal/grpc/grpcerr"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"slices"
+	"time"
+)
+
+type Admin interface {
+	ValidateToken(ctx context.Context, token string) (claims models.Claims, err error)
+	CanAdminister(ctx context.Context, userID int64, appID int32) (ok bool, err error)
+	UserAppID(ctx context.Context, userID int64) (appID int32, err error)
+	RotateSigningKey(
+		ctx context.Context,
+		actorID int64,
+		appID int32,
+		overlap time.Duration,
+		revokePrevious bool,
+	) (key models.SigningKey, err error)
+	CreateRole(ctx context.Context, actorID int64, name string, permissions []string) (roleID int64, err error)
+	AssignRole(ctx context.Context, actorID int64, userID int64, appID int32, role string) error
+	UnassignRole(ctx context.Context, actorID int64, userID int64, appID int32, role string) error
+	SetAdmin(ctx context.Context, actorID int64, userID int64, appID int32, isAdmin bool) error
+	UnlockAccount(ctx context.Context, actorID int64, userID int64) error
+	CreateApp(ctx context.Context, actorID int64, app models.App) (models.App, error)
+	App(ctx context.Context, appID int32) (models.App, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	UpdateApp(ctx context.Context, actorID int64, app models.App) (models.App, error)
+	RotateAppSecret(ctx context.Context, actorID int64, appID int32) (secret string, err error)
+	SetAppDisabled(ctx context.Context, actorID int64, appID int32, disabled bool) error
+	DeleteApp(ctx context.Context, actorID int64, appID int32) error
+}
+type serverAPI struct {
+	authv1.UnimplementedAdminServer
+	admin Admin
+}
+
+// Register registers the Admin and AppService servers.
+func Register(gRPC *grpc.Server, admin Admin) {
+	authv1.RegisterAdminServer(gRPC, &serverAPI{admin: admin})
+	authv1.RegisterAppServiceServer(gRPC, &appServerAPI{admin: admin})
+}
+
+func (s *serverAPI) RotateSigningKey(ctx context.Context, req *authv1.RotateSigningKeyRequest) (*authv1.RotateSigningKeyResponse, error) {
+	if err := validateRotateSigningKey(req); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.admin.RotateSigningKey(
+		ctx,
+		actorID,
+		req.GetAppId(),
+		time.Duration(req.GetOverlapSeconds())*time.Second,
+		req.GetRevokePrevious(),
+	)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.RotateSigningKeyResponse{Kid: key.ID, Algorithm: key.Algorithm}, nil
+}
+
+func (s *serverAPI) CreateRole(ctx context.Context, req *authv1.CreateRoleRequest) (*authv1.CreateRoleResponse, error) {
+	if err := validateCreateRole(req); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.admin.CreateRole(ctx, actorID, req.GetName(), req.GetPermissions())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.CreateRoleResponse{RoleId: id}, nil
+}
+
+func (s *serverAPI) AssignRole(ctx context.Context, req *authv1.AssignRoleRequest) (*authv1.AssignRoleResponse, error) {
+	if err := validateRoleAssignment(req.GetUserId(), req.GetAppId(), req.GetRole()); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.AssignRole(ctx, actorID, req.GetUserId(), req.GetAppId(), req.GetRole()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.AssignRoleResponse{}, nil
+}
+
+func (s *serverAPI) UnassignRole(ctx context.Context, req *authv1.UnassignRoleRequest) (*authv1.UnassignRoleResponse, error) {
+	if err := validateRoleAssignment(req.GetUserId(), req.GetAppId(), req.GetRole()); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.UnassignRole(ctx, actorID, req.GetUserId(), req.GetAppId(), req.GetRole()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.UnassignRoleResponse{}, nil
+}
+
+func (s *serverAPI) SetAdmin(ctx context.Context, req *authv1.SetAdminRequest) (*authv1.SetAdminResponse, error) {
+	if err := validateSetAdmin(req); err != nil {
+		return nil, err
+	}
+
+	actorID, err := authorize(ctx, s.admin, req.GetAppId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.SetAdmin(ctx, actorID, req.GetUserId(), req.GetAppId(), req.GetIsAdmin()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.SetAdminResponse{}, nil
+}
+
+func (s *serverAPI) UnlockAccount(ctx context.Context, req *authv1.UnlockAccountRequest) (*authv1.UnlockAccountResponse, error) {
+	if err := validateUnlockAccount(req); err != nil {
+		return nil, err
+	}
+
+	claims, err := authenticate(ctx, s.admin)
+	if err != nil {
+		return nil, err
+	}
+	// An unknown user belongs to no app, so that only the admins of the whole
+	// service learn that it does not exist.
+	appID, err := s.admin.UserAppID(ctx, req.GetUserId())
+	if err != nil && !errors.Is(err, storage.ErrUserNotFound) {
+		return nil, grpcerr.FromError(err)
+	}
+	if err := requireAdmin(ctx, s.admin, claims.UserID, appID); err != nil {
+		return nil, err
+	}
+
+	if err := s.admin.UnlockAccount(ctx, claims.UserID, req.GetUserId()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.UnlockAccountResponse{}, nil
+}
+
+// authorize checks that the caller presents a valid token of a user who
+// administers the app, or the whole service if appID is 0, and returns that
+// user's ID.
+func authorize(ctx context.Context, admin Admin, appID int32) (int64, error) {
+	claims, err := authenticate(ctx, admin)
+	if err != nil {
+		return 0, err
+	}
+	if err := requireAdmin(ctx, admin, claims.UserID, appID); err != nil {
+		return 0, err
+	}
+
+	return claims.UserID, nil
+}
+
+// authenticate checks that the caller presents a valid token and returns its claims.
+func authenticate(ctx context.Context, admin Admin) (models.Claims, error) {
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return models.Claims{}, grpcerr.FromError(err)
+	}
+
+	claims, err := admin.ValidateToken(ctx, token)
+	if err != nil {
+		return models.Claims{}, grpcerr.FromError(err)
+	}
+
+	return claims, nil
+}
+
+// requireAdmin checks that the user administers the app, or the whole
+// service if appID is 0.
+func requireAdmin(ctx context.Context, admin Admin, userID int64, appID int32) error {
+	ok, err := admin.CanAdminister(ctx, userID, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			// The token outlived its user, which is no different from a forged one.
+			return grpcerr.FromError(auth.ErrInvalidToken)
+		}
+		return grpcerr.FromError(err)
+	}
+	if !ok {
+		return grpcerr.New(codes.PermissionDenied, grpcerr.ReasonAdminRequired, "Admin rights required")
+	}
+
+	return nil
+}
+
+func validateRotateSigningKey(req *authv1.RotateSigningKeyRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	v.Check(req.GetOverlapSeconds() >= 0, "overlap_seconds", "must not be negative")
+	return v.Err()
+}
+
+func validateCreateRole(req *authv1.CreateRoleRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetName() != "", "name", "is required")
+	v.Check(!slices.Contains(req.GetPermissions(), ""), "permissions", "must not contain empty values")
+	return v.Err()
+}
+
+func validateRoleAssignment(userID int64, appID int32, role string) error {
+	var v grpcerr.Validation
+	v.Check(userID != 0, "user_id", "is required")
+	v.Check(appID != 0, "app_id", "is required")
+	v.Check(role != "", "role", "is required")
+	return v.Err()
+}
+
+func validateSetAdmin(req *authv1.SetAdminRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetUserId() != 0, "user_id", "is required")
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	return v.Err()
+}
+
+func validateUnlockAccount(req *authv1.UnlockAccountRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetUserId() != 0, "user_id", "is required")
+	return v.Err()
+}
@@ -3,18 +3,37 @@ package auth
 import (
 	"context"
 	"errors"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/grpc/bearer"
+	"github.com/qu0ta/go-grpc-auth/internal/grpc/grpcerr"
 	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
-	"github.com/qu0ta/go-grpc-auth/internal/storage"
-	authv1 "github.com/qu0ta/pet-proto/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/peer"
+	"net"
 )
 
 type Auth interface {
-	Login(ctx context.Context, email string, password string) (token string, err error)
+	Login(ctx context.Context, email string, password string, appID int32, ip string) (tokens models.TokenPair, err error)
 	RegisterUser(ctx context.Context, email string, password string, appId int32) (userID int64, err error)
 	IsAdmin(ctx context.Context, userID int64) (isAdmin bool, err error)
+	HasPermission(ctx context.Context, userID int64, appID int32, permission string) (has bool, err error)
+	Refresh(ctx context.Context, refreshToken string) (tokens models.TokenPair, err error)
+	ValidateToken(ctx context.Context, token string) (claims models.Claims, err error)
+	JWKS(ctx context.Context, appID int32) (keys []jwt.JWK, err error)
+	Logout(ctx context.Context, token string) error
+	LogoutAll(ctx context.Context, token string) error
+	EnrollMFA(ctx context.Context, token string) (enrollment models.MFAEnrollment, err error)
+	ConfirmMFA(ctx context.Context, token string, code string) (recoveryCodes []string, err error)
+	VerifyMFA(ctx context.Context, mfaToken string, code string, recoveryCode string) (tokens models.TokenPair, err error)
+	RequestPasswordReset(ctx context.Context, email string, appID int32) error
+	ResetPassword(ctx context.Context, token string, newPassword string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ChangePassword(ctx context.Context, token string, currentPassword string, newPassword string, ip string) error
+	ChangeEmail(ctx context.Context, token string, password string, newEmail string, ip string) error
+	DeleteAccount(ctx context.Context, token string, password string, ip string) error
+	ExportMyData(ctx context.Context, token string) (data []byte, err error)
 }
 type serverAPI struct {
 	authv1.UnimplementedAuthServer
@@ -31,15 +50,31 @@ func (s *serverAPI) Login(ctx context.Context, req *authv1.LoginRequest) (*authv
 
 	}
 
-	token, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword())
+	tokens, err := s.auth.Login(ctx, req.GetEmail(), req.GetPassword(), req.GetAppId(), clientIP(ctx))
 	if err != nil {
-		if errors.Is(err, auth.ErrInvalidCredentials) {
-			return nil, status.Error(codes.InvalidArgument, "Invalid credentials")
-		}
-		return nil, status.Error(codes.Internal, "Internal error")
+		return nil, grpcerr.FromError(err)
+	}
+
+	if tokens.MFAToken != "" {
+		return &authv1.LoginResponse{MfaRequired: true, MfaToken: tokens.MFAToken}, nil
+	}
+
+	return &authv1.LoginResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+// clientIP returns the address of the client without the port, or an empty
+// string if it is unknown.
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
 	}
 
-	return &authv1.LoginResponse{Token: token}, nil
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
 }
 
 func (s *serverAPI) Register(ctx context.Context, req *authv1.RegisterRequest) (*authv1.RegisterResponse, error) {
@@ -49,10 +84,7 @@ func (s *serverAPI) Register(ctx context.Context, req *authv1.RegisterRequest) (
 
 	userId, err := s.auth.RegisterUser(ctx, req.GetEmail(), req.GetPassword(), req.GetAppId())
 	if err != nil {
-		if errors.Is(err, storage.ErrUserExists) {
-			return nil, status.Error(codes.AlreadyExists, "User already exists")
-		}
-		return nil, status.Error(codes.Internal, "Internal error")
+		return nil, grpcerr.FromError(err)
 	}
 
 	return &authv1.RegisterResponse{UserId: userId}, nil
@@ -65,28 +97,342 @@ func (s *serverAPI) IsAdmin(ctx context.Context, req *authv1.IsAdminRequest) (*a
 
 	isAdmin, err := s.auth.IsAdmin(ctx, req.UserId)
 	if err != nil {
-		if errors.Is(err, storage.ErrUserNotFound) {
-			return nil, status.Error(codes.NotFound, "User not found")
-		}
-		return nil, status.Error(codes.Internal, "Internal error")
+		return nil, grpcerr.FromError(err)
 	}
 	return &authv1.IsAdminResponse{IsAdmin: isAdmin}, nil
 }
-func validateLogin(req *authv1.LoginRequest) error {
-	if req.GetEmail() == "" || req.GetPassword() == "" {
-		return status.Error(codes.InvalidArgument, "Invalid argument")
+
+func (s *serverAPI) HasPermission(ctx context.Context, req *authv1.HasPermissionRequest) (*authv1.HasPermissionResponse, error) {
+	if err := validateHasPermission(req); err != nil {
+		return nil, err
+	}
+
+	has, err := s.auth.HasPermission(ctx, req.GetUserId(), req.GetAppId(), req.GetPermission())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
 	}
-	return nil
+	return &authv1.HasPermissionResponse{HasPermission: has}, nil
 }
-func validateRegister(req *authv1.RegisterRequest) error {
-	if req.GetEmail() == "" || req.GetPassword() == "" {
-		return status.Error(codes.InvalidArgument, "Invalid argument")
+
+func (s *serverAPI) Refresh(ctx context.Context, req *authv1.RefreshRequest) (*authv1.RefreshResponse, error) {
+	if err := validateRefresh(req); err != nil {
+		return nil, err
 	}
-	return nil
+
+	tokens, err := s.auth.Refresh(ctx, req.GetRefreshToken())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.RefreshResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
 }
-func validateIsAdmin(req *authv1.IsAdminRequest) error {
-	if req.UserId == 0 {
-		return status.Error(codes.InvalidArgument, "Invalid argument")
+
+// ValidateToken reports an invalid, expired or revoked token as valid=false
+// rather than as an error, in the spirit of RFC 7662 introspection.
+func (s *serverAPI) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	if err := validateValidateToken(req); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.auth.ValidateToken(ctx, req.GetToken())
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			return &authv1.ValidateTokenResponse{Valid: false}, nil
+		}
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.ValidateTokenResponse{
+		Valid: true,
+		Claims: &authv1.Claims{
+			UserId:        claims.UserID,
+			Email:         claims.Email,
+			AppId:         claims.AppID,
+			SessionId:     claims.SessionID,
+			ExpiresAt:     claims.ExpiresAt.Unix(),
+			TokenId:       claims.TokenID,
+			Roles:         claims.Roles,
+			EmailVerified: claims.EmailVerified,
+		},
+	}, nil
+}
+
+func (s *serverAPI) GetJWKS(ctx context.Context, req *authv1.GetJWKSRequest) (*authv1.GetJWKSResponse, error) {
+	keys, err := s.auth.JWKS(ctx, req.GetAppId())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	resp := &authv1.GetJWKSResponse{Keys: make([]*authv1.JWK, 0, len(keys))}
+	for _, key := range keys {
+		resp.Keys = append(resp.Keys, &authv1.JWK{
+			Kty: key.Kty,
+			Kid: key.Kid,
+			Use: key.Use,
+			Alg: key.Alg,
+			N:   key.N,
+			E:   key.E,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+		})
+	}
+	return resp, nil
+}
+
+func (s *serverAPI) Logout(ctx context.Context, req *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	if err := s.auth.Logout(ctx, token); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.LogoutResponse{}, nil
+}
+
+func (s *serverAPI) LogoutAll(ctx context.Context, req *authv1.LogoutAllRequest) (*authv1.LogoutAllResponse, error) {
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	if err := s.auth.LogoutAll(ctx, token); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.LogoutAllResponse{}, nil
+}
+
+func (s *serverAPI) EnrollMFA(ctx context.Context, req *authv1.EnrollMFARequest) (*authv1.EnrollMFAResponse, error) {
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	enrollment, err := s.auth.EnrollMFA(ctx, token)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.EnrollMFAResponse{Secret: enrollment.Secret, Uri: enrollment.URI}, nil
+}
+
+func (s *serverAPI) ConfirmMFA(ctx context.Context, req *authv1.ConfirmMFARequest) (*authv1.ConfirmMFAResponse, error) {
+	if err := validateConfirmMFA(req); err != nil {
+		return nil, err
+	}
+
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	recoveryCodes, err := s.auth.ConfirmMFA(ctx, token, req.GetCode())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.ConfirmMFAResponse{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *serverAPI) VerifyMFA(ctx context.Context, req *authv1.VerifyMFARequest) (*authv1.VerifyMFAResponse, error) {
+	if err := validateVerifyMFA(req); err != nil {
+		return nil, err
+	}
+
+	tokens, err := s.auth.VerifyMFA(ctx, req.GetMfaToken(), req.GetCode(), req.GetRecoveryCode())
+	if err != nil {
+		return nil, grpcerr.FromError(err)
 	}
-	return nil
+
+	return &authv1.VerifyMFAResponse{Token: tokens.AccessToken, RefreshToken: tokens.RefreshToken}, nil
+}
+
+func (s *serverAPI) RequestPasswordReset(
+	ctx context.Context,
+	req *authv1.RequestPasswordResetRequest,
+) (*authv1.RequestPasswordResetResponse, error) {
+	if err := validateRequestPasswordReset(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.RequestPasswordReset(ctx, req.GetEmail(), req.GetAppId()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.RequestPasswordResetResponse{}, nil
+}
+
+func (s *serverAPI) ResetPassword(ctx context.Context, req *authv1.ResetPasswordRequest) (*authv1.ResetPasswordResponse, error) {
+	if err := validateResetPassword(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.ResetPassword(ctx, req.GetToken(), req.GetNewPassword()); err != nil {
+		return nil, grpcerr.FromError(err, grpcerr.PasswordField("new_password"))
+	}
+
+	return &authv1.ResetPasswordResponse{}, nil
+}
+
+func (s *serverAPI) VerifyEmail(ctx context.Context, req *authv1.VerifyEmailRequest) (*authv1.VerifyEmailResponse, error) {
+	if err := validateVerifyEmail(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.auth.VerifyEmail(ctx, req.GetToken()); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.VerifyEmailResponse{}, nil
+}
+
+func (s *serverAPI) ChangePassword(ctx context.Context, req *authv1.ChangePasswordRequest) (*authv1.ChangePasswordResponse, error) {
+	if err := validateChangePassword(req); err != nil {
+		return nil, err
+	}
+
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	if err := s.auth.ChangePassword(ctx, token, req.GetCurrentPassword(), req.GetNewPassword(), clientIP(ctx)); err != nil {
+		return nil, grpcerr.FromError(err, grpcerr.PasswordField("new_password"))
+	}
+
+	return &authv1.ChangePasswordResponse{}, nil
+}
+
+func (s *serverAPI) ChangeEmail(ctx context.Context, req *authv1.ChangeEmailRequest) (*authv1.ChangeEmailResponse, error) {
+	if err := validateChangeEmail(req); err != nil {
+		return nil, err
+	}
+
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	if err := s.auth.ChangeEmail(ctx, token, req.GetPassword(), req.GetNewEmail(), clientIP(ctx)); err != nil {
+		return nil, grpcerr.FromError(err, grpcerr.EmailField("new_email"))
+	}
+
+	return &authv1.ChangeEmailResponse{}, nil
+}
+
+func (s *serverAPI) DeleteAccount(ctx context.Context, req *authv1.DeleteAccountRequest) (*authv1.DeleteAccountResponse, error) {
+	if err := validateDeleteAccount(req); err != nil {
+		return nil, err
+	}
+
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	if err := s.auth.DeleteAccount(ctx, token, req.GetPassword(), clientIP(ctx)); err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.DeleteAccountResponse{}, nil
+}
+
+func (s *serverAPI) ExportMyData(ctx context.Context, req *authv1.ExportMyDataRequest) (*authv1.ExportMyDataResponse, error) {
+	token, err := bearer.Token(ctx)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	data, err := s.auth.ExportMyData(ctx, token)
+	if err != nil {
+		return nil, grpcerr.FromError(err)
+	}
+
+	return &authv1.ExportMyDataResponse{Data: data}, nil
+}
+
+func validateLogin(req *authv1.LoginRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetEmail() != "", "email", "is required")
+	v.Check(req.GetPassword() != "", "password", "is required")
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	return v.Err()
+}
+func validateRegister(req *authv1.RegisterRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetEmail() != "", "email", "is required")
+	v.Check(req.GetPassword() != "", "password", "is required")
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	return v.Err()
+}
+func validateIsAdmin(req *authv1.IsAdminRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetUserId() != 0, "user_id", "is required")
+	return v.Err()
+}
+func validateHasPermission(req *authv1.HasPermissionRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetUserId() != 0, "user_id", "is required")
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	v.Check(req.GetPermission() != "", "permission", "is required")
+	return v.Err()
+}
+func validateRefresh(req *authv1.RefreshRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetRefreshToken() != "", "refresh_token", "is required")
+	return v.Err()
+}
+func validateValidateToken(req *authv1.ValidateTokenRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetToken() != "", "token", "is required")
+	return v.Err()
+}
+func validateConfirmMFA(req *authv1.ConfirmMFARequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetCode() != "", "code", "is required")
+	return v.Err()
+}
+func validateVerifyMFA(req *authv1.VerifyMFARequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetMfaToken() != "", "mfa_token", "is required")
+	v.Check((req.GetCode() == "") != (req.GetRecoveryCode() == ""), "code", "exactly one of code and recovery_code is required")
+	return v.Err()
+}
+func validateRequestPasswordReset(req *authv1.RequestPasswordResetRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetEmail() != "", "email", "is required")
+	v.Check(req.GetAppId() != 0, "app_id", "is required")
+	return v.Err()
+}
+func validateResetPassword(req *authv1.ResetPasswordRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetToken() != "", "token", "is required")
+	v.Check(req.GetNewPassword() != "", "new_password", "is required")
+	return v.Err()
+}
+func validateVerifyEmail(req *authv1.VerifyEmailRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetToken() != "", "token", "is required")
+	return v.Err()
+}
+func validateChangePassword(req *authv1.ChangePasswordRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetCurrentPassword() != "", "current_password", "is required")
+	v.Check(req.GetNewPassword() != "", "new_password", "is required")
+	return v.Err()
+}
+func validateChangeEmail(req *authv1.ChangeEmailRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetPassword() != "", "password", "is required")
+	v.Check(req.GetNewEmail() != "", "new_email", "is required")
+	return v.Err()
+}
+func validateDeleteAccount(req *authv1.DeleteAccountRequest) error {
+	var v grpcerr.Validation
+	v.Check(req.GetPassword() != "", "password", "is required")
+	return v.Err()
 }
@@ -0,0 +1,30 @@
+package bearer
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc/metadata"
+	"strings"
+)
+
+const scheme = "bearer "
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+)
+
+// Token returns the bearer token from the authorization metadata of an incoming call.
+func Token(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", ErrMissingToken
+	}
+
+	for _, value := range md.Get("authorization") {
+		if len(value) > len(scheme) && strings.EqualFold(value[:len(scheme)], scheme) {
+			return strings.TrimSpace(value[len(scheme):]), nil
+		}
+	}
+
+	return "", ErrMissingToken
+}
@@ -0,0 +1,207 @@
+// Package grpcerr turns the errors of the service into gRPC statuses with
+// structured details, so that clients can tell errors apart without parsing
+// their messages. Every status carries a google.rpc.ErrorInfo with one of the
+// stable Reason codes below; validation errors add a google.rpc.BadRequest and
+// throttled logins a google.rpc.RetryInfo.
+package grpcerr
+
+import (
+	"context"
+	"errors"
+	"github.com/qu0ta/go-grpc-auth/internal/grpc/bearer"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"time"
+)
+
+// Domain is the domain of the ErrorInfo details.
+const Domain = "go-grpc-auth"
+
+// Reasons of the ErrorInfo details.
+const (
+	ReasonInvalidArgument          = "INVALID_ARGUMENT"
+	ReasonInvalidCredentials       = "INVALID_CREDENTIALS"
+	ReasonInvalidEmail             = "INVALID_EMAIL"
+	ReasonEmailNotVerified         = "EMAIL_NOT_VERIFIED"
+	ReasonTooManyAttempts          = "TOO_MANY_ATTEMPTS"
+	ReasonWeakPassword             = "WEAK_PASSWORD"
+	ReasonUserExists               = "USER_EXISTS"
+	ReasonUserNotFound             = "USER_NOT_FOUND"
+	ReasonAppNotFound              = "APP_NOT_FOUND"
+	ReasonAppExists                = "APP_EXISTS"
+	ReasonAppInUse                 = "APP_IN_USE"
+	ReasonAppDisabled              = "APP_DISABLED"
+	ReasonAppSecretInUse           = "APP_SECRET_IN_USE"
+	ReasonRoleExists               = "ROLE_EXISTS"
+	ReasonRoleNotFound             = "ROLE_NOT_FOUND"
+	ReasonLastAdmin                = "LAST_ADMIN"
+	ReasonMissingToken             = "MISSING_TOKEN"
+	ReasonInvalidToken             = "INVALID_TOKEN"
+	ReasonInvalidRefreshToken      = "INVALID_REFRESH_TOKEN"
+	ReasonAdminRequired            = "ADMIN_REQUIRED"
+	ReasonMFAAlreadyEnabled        = "MFA_ALREADY_ENABLED"
+	ReasonMFANotEnrolled           = "MFA_NOT_ENROLLED"
+	ReasonInvalidMFACode           = "INVALID_MFA_CODE"
+	ReasonInvalidMFAToken          = "INVALID_MFA_TOKEN"
+	ReasonInvalidResetToken        = "INVALID_RESET_TOKEN"
+	ReasonInvalidVerificationToken = "INVALID_VERIFICATION_TOKEN"
+	ReasonKeysFromFile             = "KEYS_FROM_FILE"
+	ReasonDeadlineExceeded         = "DEADLINE_EXCEEDED"
+	ReasonCanceled                 = "CANCELED"
+	ReasonInternal                 = "INTERNAL"
+)
+
+// mapping is the status an error of the service is reported as.
+type mapping struct {
+	err     error
+	code    codes.Code
+	reason  string
+	message string
+}
+
+// mappings are tried in order, the first one matching the error wins.
+var mappings = []mapping{
+	{auth.ErrInvalidCredentials, codes.InvalidArgument, ReasonInvalidCredentials, "Invalid credentials"},
+	{auth.ErrEmailNotVerified, codes.FailedPrecondition, ReasonEmailNotVerified, "Email not verified"},
+	{storage.ErrUserExists, codes.AlreadyExists, ReasonUserExists, "User already exists"},
+	{storage.ErrUserNotFound, codes.NotFound, ReasonUserNotFound, "User not found"},
+	{storage.ErrAppNotFound, codes.NotFound, ReasonAppNotFound, "App not found"},
+	{storage.ErrAppExists, codes.AlreadyExists, ReasonAppExists, "App already exists"},
+	{storage.ErrAppInUse, codes.FailedPrecondition, ReasonAppInUse, "App still has users"},
+	{auth.ErrAppDisabled, codes.FailedPrecondition, ReasonAppDisabled, "App is disabled"},
+	{auth.ErrAppSecretInUse, codes.FailedPrecondition, ReasonAppSecretInUse, "App secret still signs tokens"},
+	{storage.ErrRoleExists, codes.AlreadyExists, ReasonRoleExists, "Role already exists"},
+	{storage.ErrRoleNotFound, codes.NotFound, ReasonRoleNotFound, "Role not found"},
+	{storage.ErrLastAdmin, codes.FailedPrecondition, ReasonLastAdmin, "Cannot remove the last admin"},
+	{bearer.ErrMissingToken, codes.Unauthenticated, ReasonMissingToken, "Missing bearer token"},
+	{auth.ErrInvalidToken, codes.Unauthenticated, ReasonInvalidToken, "Invalid token"},
+	{auth.ErrInvalidRefreshToken, codes.Unauthenticated, ReasonInvalidRefreshToken, "Invalid refresh token"},
+	{auth.ErrMFAAlreadyEnabled, codes.FailedPrecondition, ReasonMFAAlreadyEnabled, "MFA already enabled"},
+	{auth.ErrMFANotEnrolled, codes.FailedPrecondition, ReasonMFANotEnrolled, "MFA not enrolled"},
+	{auth.ErrInvalidMFACode, codes.InvalidArgument, ReasonInvalidMFACode, "Invalid code"},
+	{auth.ErrInvalidMFAToken, codes.Unauthenticated, ReasonInvalidMFAToken, "Invalid MFA token"},
+	{auth.ErrInvalidResetToken, codes.InvalidArgument, ReasonInvalidResetToken, "Invalid reset token"},
+	{auth.ErrInvalidVerificationToken, codes.InvalidArgument, ReasonInvalidVerificationToken, "Invalid verification token"},
+	{auth.ErrKeysFromFile, codes.FailedPrecondition, ReasonKeysFromFile, "Signing keys are loaded from a file"},
+	{context.DeadlineExceeded, codes.DeadlineExceeded, ReasonDeadlineExceeded, "Deadline exceeded"},
+	{context.Canceled, codes.Canceled, ReasonCanceled, "Canceled"},
+}
+
+type options struct {
+	emailField    string
+	passwordField string
+}
+
+// Option adjusts how FromError reports an error.
+type Option func(*options)
+
+// EmailField names the request field a rejected email was passed in.
+// It defaults to "email".
+func EmailField(field string) Option {
+	return func(o *options) {
+		o.emailField = field
+	}
+}
+
+// PasswordField names the request field a rejected password was passed in.
+// It defaults to "password".
+func PasswordField(field string) Option {
+	return func(o *options) {
+		o.passwordField = field
+	}
+}
+
+// FromError returns the status error the error of the service is reported as.
+// Status errors are returned as they are. Errors that are not part of the API
+// are reported as Internal without revealing their text.
+func FromError(err error, opts ...Option) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	o := options{emailField: "email", passwordField: "password"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var tooMany *auth.TooManyAttemptsError
+	if errors.As(err, &tooMany) {
+		// Round up so that a client retrying after the delay is not rejected again.
+		retryAfter := (tooMany.RetryAfter + time.Second - 1).Truncate(time.Second)
+
+		return New(codes.ResourceExhausted, ReasonTooManyAttempts, "Too many login attempts",
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	}
+
+	if errors.Is(err, auth.ErrInvalidEmail) {
+		return New(codes.InvalidArgument, ReasonInvalidEmail, "Invalid email", &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: o.emailField, Description: "is not a valid email address"},
+			},
+		})
+	}
+
+	var weak *auth.PasswordPolicyError
+	if errors.As(err, &weak) {
+		badRequest := &errdetails.BadRequest{}
+		for _, violation := range weak.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       o.passwordField,
+				Description: violation,
+			})
+		}
+
+		return New(codes.InvalidArgument, ReasonWeakPassword, "Password does not satisfy the password policy", badRequest)
+	}
+
+	for _, m := range mappings {
+		if errors.Is(err, m.err) {
+			return New(m.code, m.reason, m.message)
+		}
+	}
+
+	return New(codes.Internal, ReasonInternal, "Internal error")
+}
+
+// New returns a status error with an ErrorInfo detail of the reason followed
+// by the given details.
+func New(code codes.Code, reason string, message string, details ...protoadapt.MessageV1) error {
+	st := status.New(code, message)
+
+	details = append([]protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: reason, Domain: Domain}}, details...)
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// Validation collects the violations of the fields of a request.
+type Validation struct {
+	violations []*errdetails.BadRequest_FieldViolation
+}
+
+// Check records a violation of the field unless ok holds.
+func (v *Validation) Check(ok bool, field string, description string) {
+	if !ok {
+		v.violations = append(v.violations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: description,
+		})
+	}
+}
+
+// Err returns an InvalidArgument status error listing the violations, or nil
+// if there are none.
+func (v *Validation) Err() error {
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return New(codes.InvalidArgument, ReasonInvalidArgument, "Invalid argument",
+		&errdetails.BadRequest{FieldViolations: v.violations})
+}
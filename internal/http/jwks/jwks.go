@@ -0,0 +1,54 @@
+package jwks
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Path is where the JWKS document is served, as in OpenID Connect discovery.
+const Path = "/.well-known/jwks.json"
+
+type Provider interface {
+	JWKS(ctx context.Context, appID int32) (keys []jwt.JWK, err error)
+}
+
+type document struct {
+	Keys []jwt.JWK `json:"keys"`
+}
+
+// Register serves the public signing keys at Path. An optional app_id query
+// parameter limits the document to the keys of a single app.
+func Register(mux *http.ServeMux, log *slog.Logger, provider Provider) {
+	mux.HandleFunc("GET "+Path, func(w http.ResponseWriter, r *http.Request) {
+		const op = "http.jwks"
+
+		var appID int64
+		if raw := r.URL.Query().Get("app_id"); raw != "" {
+			var err error
+			appID, err = strconv.ParseInt(raw, 10, 32)
+			if err != nil {
+				http.Error(w, "Invalid app_id", http.StatusBadRequest)
+				return
+			}
+		}
+
+		keys, err := provider.JWKS(r.Context(), int32(appID))
+		if err != nil {
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		if keys == nil {
+			keys = []jwt.JWK{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := json.NewEncoder(w).Encode(document{Keys: keys}); err != nil {
+			log.With(slog.String("op", op)).Error("failed to write response", slog.String("error", err.Error()))
+		}
+	})
+}
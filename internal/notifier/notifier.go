@@ -0,0 +1,36 @@
+// Package notifier delivers the messages of the auth service to its users.
+package notifier
+
+import (
+	"context"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"log/slog"
+)
+
+// Log writes the messages to the log instead of delivering them. It is meant
+// for local development only, as the log then contains the secret tokens.
+type Log struct {
+	log *slog.Logger
+}
+
+func NewLog(log *slog.Logger) *Log {
+	return &Log{log: log}
+}
+
+func (n *Log) PasswordReset(ctx context.Context, user models.User, token string) error {
+	n.log.Info("password reset requested",
+		slog.Int64("uid", user.ID),
+		slog.String("email", user.Email),
+		slog.String("token", token),
+	)
+	return nil
+}
+
+func (n *Log) EmailVerification(ctx context.Context, user models.User, token string) error {
+	n.log.Info("email verification requested",
+		slog.Int64("uid", user.ID),
+		slog.String("email", user.Email),
+		slog.String("token", token),
+	)
+	return nil
+}
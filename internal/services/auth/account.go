@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// ChangePassword sets a new password for the owner of the access token if the
+// current password matches, and revokes all of their other sessions. ip is the
+// address of the client, if known; wrong passwords are throttled as on Login.
+func (a *Auth) ChangePassword(ctx context.Context, token string, currentPassword string, newPassword string, ip string) error {
+	const op = "auth.ChangePassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	user, claims, err := a.checkPassword(ctx, token, currentPassword, ip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", user.ID))
+	log.Info("changing password")
+
+	if err := a.checkUserPasswordPolicy(ctx, user, newPassword); err != nil {
+		if errors.Is(err, ErrWeakPassword) {
+			log.Info("password rejected", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check the password policy", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passwordHash, err := a.hasher.Hash([]byte(newPassword))
+	if err != nil {
+		log.Error("failed to hash password", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	families, err := a.storage.ChangePassword(ctx, user.ID, passwordHash, claims.SessionID)
+	if err != nil {
+		log.Error("failed to change password", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, family := range families {
+		a.denylist.set(family, true)
+	}
+
+	log.Info("password changed", slog.Int("revoked_sessions", len(families)))
+
+	return nil
+}
+
+// ChangeEmail sets a new email for the owner of the access token if the
+// password matches. The new email is unverified until the user completes the
+// verification sent to it. Wrong passwords are throttled as on Login.
+func (a *Auth) ChangeEmail(ctx context.Context, token string, password string, newEmail string, ip string) error {
+	const op = "auth.ChangeEmail"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	user, _, err := a.checkPassword(ctx, token, password, ip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", user.ID))
+	log.Info("changing email")
+
+	address, err := a.emails.Normalize(newEmail)
+	if err != nil {
+		log.Info("invalid email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	if err := a.storage.ChangeEmail(ctx, user.ID, address.Email, address.Normalized); err != nil {
+		if errors.Is(err, storage.ErrUserExists) {
+			log.Warn("email already taken")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to change email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	user.Email = address.Email
+	user.EmailVerified = false
+	if err := a.sendEmailVerification(ctx, user); err != nil {
+		log.Error("failed to send email verification", slog.String("error", err.Error()))
+	}
+
+	log.Info("email changed")
+
+	return nil
+}
+
+// DeleteAccount deletes the owner of the access token if the password
+// matches and revokes all of their sessions. The account is purged for good
+// by PurgeDeletedAccounts once the grace period has passed. Wrong passwords
+// are throttled as on Login.
+func (a *Auth) DeleteAccount(ctx context.Context, token string, password string, ip string) error {
+	const op = "auth.DeleteAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	user, _, err := a.checkPassword(ctx, token, password, ip)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", user.ID))
+	log.Info("deleting account")
+
+	var families []string
+	err = a.storage.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.MarkUserDeleted(ctx, user.ID); err != nil {
+			return err
+		}
+		if err := tx.UnassignUserRoles(ctx, user.ID, a.admin.AppID); err != nil {
+			return err
+		}
+
+		var err error
+		families, err = tx.RevokeUserSessions(ctx, user.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrLastAdmin) {
+			log.Warn("refused to delete the last admin")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to delete account", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, family := range families {
+		a.denylist.set(family, true)
+	}
+
+	log.Info("account deleted", slog.Time("purge_after", time.Now().Add(a.deletion.GracePeriod)))
+
+	return nil
+}
+
+// PurgeDeletedAccounts removes the accounts deleted more than the grace period ago.
+func (a *Auth) PurgeDeletedAccounts(ctx context.Context) (int64, error) {
+	const op = "auth.PurgeDeletedAccounts"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	purged, err := a.storage.PurgeDeletedUsers(ctx, time.Now().Add(-a.deletion.GracePeriod))
+	if err != nil {
+		log.Error("failed to purge deleted accounts", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if purged > 0 {
+		log.Info("purged deleted accounts", slog.Int64("count", purged))
+	}
+
+	return purged, nil
+}
+
+// checkPassword returns the owner of the access token and its claims if the
+// password is theirs. The check counts as a login attempt of the account and
+// of the client address ip, so that a stolen access token cannot be used to
+// guess the password without being throttled.
+func (a *Auth) checkPassword(ctx context.Context, token string, password string, ip string) (models.User, models.Claims, error) {
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.User{}, models.Claims{}, err
+	}
+
+	user, err := a.storage.UserByID(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return models.User{}, models.Claims{}, ErrInvalidToken
+		}
+		return models.User{}, models.Claims{}, err
+	}
+
+	log := a.log.With(slog.Int64("uid", user.ID), slog.String("ip", ip))
+
+	throttleKeys := a.throttleKeys(user.NormalizedEmail, user.AppID, ip)
+	attempts, err := a.reserveAttempt(ctx, throttleKeys)
+	if err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			log.Warn("password check blocked", slog.String("error", err.Error()))
+		}
+		return models.User{}, models.Claims{}, err
+	}
+
+	if err := a.hasher.Compare(user.PasswordHash, []byte(password)); err != nil {
+		log.Info("invalid credentials")
+		return models.User{}, models.Claims{}, a.loginFailed(ctx, log, throttleKeys, attempts)
+	}
+
+	if err := a.attemptSucceeded(ctx, throttleKeys); err != nil {
+		return models.User{}, models.Claims{}, err
+	}
+
+	return user, claims, nil
+}
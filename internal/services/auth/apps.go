@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"log/slog"
+)
+
+var (
+	ErrAppDisabled    = errors.New("app disabled")
+	ErrAppSecretInUse = errors.New("app secret still signs or verifies tokens")
+)
+
+// CreateApp creates an app with the name, settings and metadata of app and a
+// newly generated secret. The returned app holds the secret.
+func (a *Auth) CreateApp(ctx context.Context, actorID int64, app models.App) (models.App, error) {
+	const op = "auth.CreateApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("app", app.Name),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("creating app")
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		log.Error("failed to create app secret", slog.String("error", err.Error()))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	app.Secret = secret
+	app.Disabled = false
+
+	details, err := appDetails(app)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app.ID, err = a.storage.CreateApp(ctx, app, models.AuditEvent{
+		UserID:  actorID,
+		Action:  models.AuditAppCreated,
+		Details: details,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAppExists) {
+			log.Warn("app already exists")
+			return models.App{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to create app", slog.String("error", err.Error()))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app created", slog.Int64("app_id", app.ID))
+
+	return app, nil
+}
+
+// App returns the app with the given id.
+func (a *Auth) App(ctx context.Context, appID int32) (models.App, error) {
+	const op = "auth.App"
+
+	app, err := a.storage.App(ctx, appID)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return app, nil
+}
+
+// Apps returns every app.
+func (a *Auth) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "auth.Apps"
+
+	apps, err := a.storage.Apps(ctx)
+	if err != nil {
+		a.log.Error("failed to list apps", slog.String("op", op), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+// UpdateApp replaces the name, settings and metadata of the app app.ID with
+// those of app. The secret and whether the app is disabled are kept.
+func (a *Auth) UpdateApp(ctx context.Context, actorID int64, app models.App) (models.App, error) {
+	const op = "auth.UpdateApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("app_id", app.ID),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("updating app")
+
+	details, err := appDetails(app)
+	if err != nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.storage.UpdateApp(ctx, app, models.AuditEvent{
+		UserID:  actorID,
+		AppID:   int32(app.ID),
+		Action:  models.AuditAppUpdated,
+		Details: details,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) || errors.Is(err, storage.ErrAppExists) {
+			log.Warn("failed to update app", slog.String("error", err.Error()))
+			return models.App{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to update app", slog.String("error", err.Error()))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	updated, err := a.storage.App(ctx, int32(app.ID))
+	if err != nil {
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return models.App{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app updated")
+
+	return updated, nil
+}
+
+// RotateAppSecret replaces the secret of the app with a newly generated one
+// and returns it. Under HS256 the secret signs the tokens of an app without
+// stored keys and verifies them until the overlap of the first signing key
+// rotation is over; replacing it then would invalidate them at once, so the
+// rotation fails with ErrAppSecretInUse.
+func (a *Auth) RotateAppSecret(ctx context.Context, actorID int64, appID int32) (string, error) {
+	const op = "auth.RotateAppSecret"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", int(appID)),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("rotating app secret")
+
+	inUse, err := a.keys.secretInUse(ctx, appID)
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found")
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check the app secret", slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	if inUse {
+		log.Warn("app secret is still in use")
+		return "", fmt.Errorf("%s: %w", op, ErrAppSecretInUse)
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		log.Error("failed to create app secret", slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.storage.UpdateAppSecret(ctx, appID, secret, models.AuditEvent{
+		UserID: actorID,
+		AppID:  appID,
+		Action: models.AuditAppSecretRotated,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found")
+			return "", fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to rotate app secret", slog.String("error", err.Error()))
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app secret rotated")
+
+	return secret, nil
+}
+
+// SetAppDisabled disables or enables the app. Disabling revokes every session
+// of the app, so that its tokens stop validating.
+func (a *Auth) SetAppDisabled(ctx context.Context, actorID int64, appID int32, disabled bool) error {
+	const op = "auth.SetAppDisabled"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", int(appID)),
+		slog.Bool("disabled", disabled),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("setting app disabled")
+
+	action := models.AuditAppEnabled
+	if disabled {
+		action = models.AuditAppDisabled
+	}
+
+	families, err := a.storage.SetAppDisabled(ctx, appID, disabled, models.AuditEvent{
+		UserID: actorID,
+		AppID:  appID,
+		Action: action,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to set app disabled", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, family := range families {
+		a.denylist.set(family, true)
+	}
+
+	log.Info("app disabled set", slog.Int("revoked_sessions", len(families)))
+
+	return nil
+}
+
+// DeleteApp deletes the app. Apps with users fail with storage.ErrAppInUse
+// and can only be disabled. Deleting the only admin assignments fails with
+// storage.ErrLastAdmin.
+func (a *Auth) DeleteApp(ctx context.Context, actorID int64, appID int32) error {
+	const op = "auth.DeleteApp"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", int(appID)),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("deleting app")
+
+	err := a.storage.DeleteApp(ctx, appID, models.AuditEvent{
+		UserID: actorID,
+		AppID:  appID,
+		Action: models.AuditAppDeleted,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) || errors.Is(err, storage.ErrAppInUse) ||
+			errors.Is(err, storage.ErrLastAdmin) {
+			log.Warn("failed to delete app", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to delete app", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("app deleted")
+
+	return nil
+}
+
+// appDetails describes the app for the audit log, leaving out its secret.
+func appDetails(app models.App) (string, error) {
+	details, err := json.Marshal(map[string]any{
+		"name":                   app.Name,
+		"require_verified_email": app.RequireVerifiedEmail,
+		"password_policy":        app.PasswordPolicy,
+		"audiences":              app.Audiences,
+		"redirect_uris":          app.RedirectURIs,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(details), nil
+}
@@ -4,84 +4,296 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
 	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
 	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"github.com/qu0ta/go-grpc-auth/pkg/emailaddr"
 	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
-	"golang.org/x/crypto/bcrypt"
 	"log/slog"
+	"sync"
 	"time"
 )
 
 var (
 	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrEmailNotVerified   = errors.New("email not verified")
+	ErrInvalidEmail       = errors.New("invalid email")
 )
 
 type Auth struct {
 	log      *slog.Logger
 	storage  Storage
 	tokenTTL time.Duration
+	refresh  config.RefreshConfig
+	signing  config.SigningConfig
+	mfa      config.MFAConfig
+	reset    config.PasswordResetConfig
+	verify   config.VerificationConfig
+	deletion config.DeletionConfig
+	throttle config.LoginThrottleConfig
+	admin    config.AdminConfig
+	emails   emailaddr.Normalizer
+	keys     *keyring
+	denylist *denylist
+	hasher   PasswordHasher
+	breached BreachedPasswords
+	notifier Notifier
+	// dummyHash is compared with the passwords of unknown users, so that their
+	// logins take as long as logins of registered users.
+	dummyHash func() []byte
+	// background tracks the deliveries run after their request has returned.
+	background sync.WaitGroup
 }
 
-type Storage interface {
-	SaveUser(ctx context.Context, email string, passwordHash []byte, appId int32) (uid int64, err error)
-	User(ctx context.Context, email string) (models.User, error)
-	IsAdmin(ctx context.Context, userID int64) (isAdmin bool, err error)
-	App(ctx context.Context, id int32) (models.App, error)
+// Storage is the storage of the service. It is declared in the storage
+// package, so that the storages can hand themselves to WithTx callbacks.
+type Storage = storage.Storage
+
+// PasswordHasher hashes passwords and checks them against stored hashes.
+type PasswordHasher interface {
+	Hash(password []byte) ([]byte, error)
+	// Compare returns nil if the password matches the hash.
+	Compare(hash []byte, password []byte) error
+	// NeedsRehash reports whether the hash was made with an outdated algorithm
+	// or outdated parameters.
+	NeedsRehash(hash []byte) bool
+}
+
+// BreachedPasswords is a list of passwords known from data breaches. Contains
+// fails if the list cannot be read.
+type BreachedPasswords interface {
+	Contains(password string) (bool, error)
 }
 
-// New creates a new Auth instance with the given logger, storage, and token TTL.
+// Notifier delivers the messages of the service to users, e.g. by email.
+type Notifier interface {
+	PasswordReset(ctx context.Context, user models.User, token string) error
+	EmailVerification(ctx context.Context, user models.User, token string) error
+}
 
-func New(log *slog.Logger, storage Storage, tokenTTL time.Duration) *Auth {
+// New creates a new Auth instance with the given logger, storage and config.
+//
+// globalKey, if not nil, signs the tokens of every app instead of the per-app
+// key rings. breached, if not nil, is consulted for the apps whose password
+// policy checks for breached passwords.
+
+func New(
+	log *slog.Logger,
+	storage Storage,
+	cfg *config.Config,
+	globalKey *jwt.Key,
+	hasher PasswordHasher,
+	breached BreachedPasswords,
+	notifier Notifier,
+) *Auth {
 	return &Auth{
 		log:      log,
 		storage:  storage,
-		tokenTTL: tokenTTL,
+		tokenTTL: cfg.TokenTTL,
+		refresh:  cfg.Refresh,
+		signing:  cfg.Signing,
+		mfa:      cfg.MFA,
+		reset:    cfg.PasswordReset,
+		verify:   cfg.Verification,
+		deletion: cfg.Deletion,
+		throttle: cfg.LoginThrottle,
+		admin:    cfg.Admin,
+		emails: emailaddr.Normalizer{
+			CaseSensitiveLocalPart: cfg.Email.CaseSensitiveLocalPart,
+			AllowUnicode:           cfg.Email.AllowUnicode,
+		},
+		keys:     newKeyring(storage, cfg.Signing.Algorithm, globalKey),
+		denylist: newDenylist(cfg.Revocation.CacheSize, cfg.Revocation.CacheTTL),
+		hasher:   hasher,
+		breached: breached,
+		notifier: notifier,
+		dummyHash: sync.OnceValue(func() []byte {
+			// A failure leaves the hash empty, which fails to compare at once.
+			hash, _ := hasher.Hash([]byte("dummy password"))
+			return hash
+		}),
 	}
 }
 
-func (a *Auth) Login(ctx context.Context, email string, password string) (string, error) {
+// Login checks the credentials of the user registered in the app and issues
+// an access token together with a refresh token that starts a new session family.
+//
+// Failed logins are counted per account and per client address ip, which may
+// be empty. Once a threshold is reached, logins are rejected with
+// *TooManyAttemptsError until the block expires.
+func (a *Auth) Login(ctx context.Context, email string, password string, appID int32, ip string) (models.TokenPair, error) {
 	const op = "auth.Login"
 
 	log := a.log.With(
 		slog.String("op", op),
 		slog.String("username", email),
+		slog.Int("app_id", int(appID)),
+		slog.String("ip", ip),
 	)
 
 	log.Info("logging in")
-	user, err := a.storage.User(ctx, email)
+
+	normalizedEmail := a.normalizeEmail(email)
+
+	throttleKeys := a.throttleKeys(normalizedEmail, appID, ip)
+	attempts, err := a.reserveAttempt(ctx, throttleKeys)
+	if err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			log.Warn("login blocked", slog.String("error", err.Error()))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to count the login attempt", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.storage.User(ctx, appID, normalizedEmail)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserNotFound) {
-			a.log.Warn("user not found: ", err.Error())
+			log.Warn("user not found", slog.String("error", err.Error()))
+
+			// Spend as long as on a wrong password so that the response time
+			// does not reveal whether the email is registered.
+			_ = a.hasher.Compare(a.dummyHash(), []byte(password))
 
-			return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.loginFailed(ctx, log, throttleKeys, attempts))
 		}
 
-		a.log.Error("failed to get the user: ", err.Error())
+		log.Error("failed to get the user", slog.String("error", err.Error()))
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := a.hasher.Compare(user.PasswordHash, []byte(password)); err != nil {
+		log.Info("invalid credentials")
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.loginFailed(ctx, log, throttleKeys, attempts))
+	}
+
+	if err := a.attemptSucceeded(ctx, throttleKeys); err != nil {
+		log.Error("failed to reset failed logins", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.rehashPassword(ctx, log, user, password)
+
+	app, err := a.storage.App(ctx, appID)
+	if err != nil {
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if app.Disabled {
+		log.Info("app disabled")
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAppDisabled)
+	}
+
+	if app.RequireVerifiedEmail && !user.EmailVerified {
+		log.Info("email not verified")
 
-		return "", fmt.Errorf("%s: %w", op, err)
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrEmailNotVerified)
 	}
-	if err := bcrypt.CompareHashAndPassword(user.PasswordHash, []byte(password)); err != nil {
-		a.log.Info("invalid credentials")
 
-		return "", fmt.Errorf("%s: %w", op, ErrInvalidCredentials)
+	mfaEnabled, err := a.mfaEnabled(ctx, user.ID)
+	if err != nil {
+		log.Error("failed to check mfa enrollment", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
+	if mfaEnabled {
+		mfaToken, err := a.newMFAChallenge(ctx, user, appID)
+		if err != nil {
+			log.Error("failed to create mfa challenge", slog.String("error", err.Error()))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
 
-	app, err := a.storage.App(ctx, user.AppID)
+		log.Info("second factor required")
+
+		return models.TokenPair{MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := a.issueTokens(ctx, user, app)
 	if err != nil {
-		a.log.Error("failed to get the app: ", err.Error())
-		return "", fmt.Errorf("%s: %w", op, err)
+		log.Error("failed to issue tokens", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
 	}
 
 	log.Info("logged in successfully")
 
-	token, err := jwt.NewToken(user, app, a.tokenTTL)
+	return tokens, nil
+
+}
+
+// normalizeEmail returns the normalized form of the email. An invalid email
+// cannot belong to any user, so it is returned as it is and fails to match
+// like an unknown one.
+func (a *Auth) normalizeEmail(email string) string {
+	address, err := a.emails.Normalize(email)
 	if err != nil {
-		a.log.Error("failed to create token: ", err.Error())
-		return "", fmt.Errorf("%s: %w", op, err)
+		return email
 	}
+	return address.Normalized
+}
 
-	return token, nil
+// rehashPassword replaces the password hash of the user if it was made with
+// an outdated algorithm or outdated parameters. Failures are only logged, as
+// the current hash keeps working.
+func (a *Auth) rehashPassword(ctx context.Context, log *slog.Logger, user models.User, password string) {
+	if !a.hasher.NeedsRehash(user.PasswordHash) {
+		return
+	}
 
+	passwordHash, err := a.hasher.Hash([]byte(password))
+	if err != nil {
+		log.Error("failed to rehash password", slog.String("error", err.Error()))
+		return
+	}
+
+	if err := a.storage.UpdatePasswordHash(ctx, user.ID, user.PasswordHash, passwordHash); err != nil {
+		log.Error("failed to update password hash", slog.String("error", err.Error()))
+		return
+	}
+
+	log.Info("password rehashed")
+}
+
+// loginFailed blocks the keys the failed login has taken over a threshold and
+// returns ErrInvalidCredentials.
+func (a *Auth) loginFailed(ctx context.Context, log *slog.Logger, keys []throttleKey, attempts []int) error {
+	if err := a.attemptFailed(ctx, keys, attempts); err != nil {
+		log.Error("failed to count failed login", slog.String("error", err.Error()))
+	}
+	return ErrInvalidCredentials
+}
+
+// issueTokens starts a new session family of the user in the app and returns
+// its access and refresh tokens.
+func (a *Auth) issueTokens(ctx context.Context, user models.User, app models.App) (models.TokenPair, error) {
+	family, err := newOpaqueToken()
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("create session family: %w", err)
+	}
+
+	key, err := a.keys.signingKey(ctx, app)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("get the signing key: %w", err)
+	}
+
+	roles, err := a.storage.UserRoles(ctx, user.ID, int32(app.ID))
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("get the user roles: %w", err)
+	}
+
+	token, err := jwt.NewToken(user, app, family, roles, a.tokenTTL, key)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("create token: %w", err)
+	}
+
+	refreshToken, err := a.newRefreshToken(ctx, a.storage, user, app, family)
+	if err != nil {
+		return models.TokenPair{}, fmt.Errorf("create refresh token: %w", err)
+	}
+
+	return models.TokenPair{AccessToken: token, RefreshToken: refreshToken}, nil
 }
 func (a *Auth) RegisterUser(ctx context.Context, email string, password string, appId int32) (int64, error) {
 	const op = "auth.RegisterUser"
@@ -93,32 +305,71 @@ func (a *Auth) RegisterUser(ctx context.Context, email string, password string,
 
 	log.Info("registering new user")
 
-	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	address, err := a.emails.Normalize(email)
+	if err != nil {
+		log.Info("invalid email", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	app, err := a.storage.App(ctx, appId)
 	if err != nil {
-		log.Error("failed to hash password: ", err.Error())
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found", slog.String("error", err.Error()))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	if app.Disabled {
+		log.Info("app disabled")
+		return 0, fmt.Errorf("%s: %w", op, ErrAppDisabled)
+	}
+
+	if err := a.checkPasswordPolicy(app.PasswordPolicy, address.Email, password); err != nil {
+		if errors.Is(err, ErrWeakPassword) {
+			log.Info("password rejected", slog.String("error", err.Error()))
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check the password policy", slog.String("error", err.Error()))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
-	id, err := a.storage.SaveUser(ctx, email, passwordHash, appId)
+	// The password is hashed before the email is looked up, so that
+	// registering a taken email takes as long as registering a new one.
+	passwordHash, err := a.hasher.Hash([]byte(password))
+	if err != nil {
+		log.Error("failed to hash password", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.storage.SaveUser(ctx, address.Email, address.Normalized, passwordHash, appId)
 	if err != nil {
 		if errors.Is(err, storage.ErrUserExists) {
-			log.Error("user already exists: ", err.Error())
+			log.Error("user already exists", slog.String("error", err.Error()))
 			return 0, fmt.Errorf("%s: %w", op, err)
 		}
 
-		log.Error("failed to save user: ", err.Error())
+		log.Error("failed to save user", slog.String("error", err.Error()))
 		return 0, fmt.Errorf("%s: %w", op, err)
 	}
 
+	// The account exists even if the verification cannot be sent.
+	if err := a.sendEmailVerification(ctx, models.User{ID: id, Email: address.Email, AppID: appId}); err != nil {
+		log.Error("failed to send email verification", slog.String("error", err.Error()))
+	}
+
 	log.Info("User registered")
 
 	return id, nil
 
 }
 
-// IsAdmin checks if the user with the given userID is an admin.
+// IsAdmin checks if the user with the given userID holds the built-in admin
+// role in the app the user is registered in.
 func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
-	const op = "auth.RegisterUser"
+	const op = "auth.IsAdmin"
 
 	log := a.log.With(
 		slog.String("op", op),
@@ -127,11 +378,64 @@ func (a *Auth) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 
 	log.Info("check if user is admin")
 
-	isAdmin, err := a.IsAdmin(ctx, userID)
+	user, err := a.storage.UserByID(ctx, userID)
 	if err != nil {
-		log.Error("failed to check if user is admin: ", err.Error())
+		log.Warn("failed to get the user", slog.String("error", err.Error()))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	isAdmin, err := a.storage.IsAdmin(ctx, userID, user.AppID)
+	if err != nil {
+		log.Error("failed to check if user is admin", slog.String("error", err.Error()))
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
 
 	return isAdmin, nil
 }
+
+// CanAdminister reports whether the user administers the app: holds the
+// admin role in it or in admin.app_id, whose admins administer every app. An
+// appID of 0 stands for the service as a whole, which only the latter do.
+func (a *Auth) CanAdminister(ctx context.Context, userID int64, appID int32) (bool, error) {
+	const op = "auth.CanAdminister"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", int(appID)),
+	)
+
+	for _, id := range []int32{appID, a.admin.AppID} {
+		if id == 0 {
+			continue
+		}
+
+		isAdmin, err := a.storage.IsAdmin(ctx, userID, id)
+		if err != nil {
+			if errors.Is(err, storage.ErrUserNotFound) {
+				log.Warn("user not found")
+				return false, fmt.Errorf("%s: %w", op, err)
+			}
+
+			log.Error("failed to check if user is admin", slog.String("error", err.Error()))
+			return false, fmt.Errorf("%s: %w", op, err)
+		}
+		if isAdmin {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UserAppID returns the id of the app the user is registered in.
+func (a *Auth) UserAppID(ctx context.Context, userID int64) (int32, error) {
+	const op = "auth.UserAppID"
+
+	user, err := a.storage.UserByID(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return user.AppID, nil
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// denylist caches which sessions have been revoked, so validating a token
+// does not query the storage every time.
+//
+// It holds at most size sessions and evicts the least recently used one when
+// full. Revocations are remembered until evicted, while "not revoked" answers
+// expire after ttl so that revocations made by other replicas are picked up.
+type denylist struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type denylistEntry struct {
+	sessionID string
+	revoked   bool
+	checkedAt time.Time
+}
+
+func newDenylist(size int, ttl time.Duration) *denylist {
+	return &denylist{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// revoked returns the cached state of the session and whether it was cached.
+func (d *denylist) revoked(sessionID string) (revoked bool, ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	el, ok := d.entries[sessionID]
+	if !ok {
+		return false, false
+	}
+
+	entry := el.Value.(*denylistEntry)
+	if !entry.revoked && time.Since(entry.checkedAt) > d.ttl {
+		d.order.Remove(el)
+		delete(d.entries, sessionID)
+		return false, false
+	}
+
+	d.order.MoveToFront(el)
+	return entry.revoked, true
+}
+
+// set records the state of the session.
+func (d *denylist) set(sessionID string, revoked bool) {
+	if d.size <= 0 {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if el, ok := d.entries[sessionID]; ok {
+		entry := el.Value.(*denylistEntry)
+		entry.revoked = entry.revoked || revoked
+		entry.checkedAt = time.Now()
+		d.order.MoveToFront(el)
+		return
+	}
+
+	d.entries[sessionID] = d.order.PushFront(&denylistEntry{
+		sessionID: sessionID,
+		revoked:   revoked,
+		checkedAt: time.Now(),
+	})
+
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*denylistEntry).sessionID)
+	}
+}
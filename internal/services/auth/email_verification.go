@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid email verification token")
+)
+
+// VerifyEmail marks the email the verification token was sent to as verified.
+func (a *Auth) VerifyEmail(ctx context.Context, token string) error {
+	const op = "auth.VerifyEmail"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("verifying email")
+
+	verification, err := a.storage.EmailVerification(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrVerificationNotFound) {
+			log.Info("unknown verification token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+		}
+
+		log.Error("failed to get the verification", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", verification.UserID))
+
+	if verification.Used || time.Now().After(verification.ExpiresAt) {
+		log.Info("verification token is no longer valid")
+		return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+	}
+
+	if err := a.storage.VerifyEmail(ctx, verification); err != nil {
+		if errors.Is(err, storage.ErrVerificationUsed) || errors.Is(err, storage.ErrVerificationNotFound) {
+			log.Info("verification token is no longer valid")
+			return fmt.Errorf("%s: %w", op, ErrInvalidVerificationToken)
+		}
+
+		log.Error("failed to verify email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("email verified")
+
+	return nil
+}
+
+// sendEmailVerification stores a verification of the current email of the
+// user and sends its token to the user.
+func (a *Auth) sendEmailVerification(ctx context.Context, user models.User) error {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+
+	err = a.storage.SaveEmailVerification(ctx, models.EmailVerification{
+		UserID:    user.ID,
+		Email:     user.Email,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(a.verify.TokenTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.notifier.EmailVerification(ctx, user, token)
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"log/slog"
+	"time"
+)
+
+// userExport is the JSON document returned by ExportMyData. Secrets such as
+// password hashes, token hashes and the TOTP secret are left out.
+type userExport struct {
+	Profile  exportProfile     `json:"profile"`
+	Roles    []exportRole      `json:"roles"`
+	Sessions []exportSession   `json:"sessions"`
+	AuditLog []exportAuditItem `json:"audit_log"`
+	MFA      *exportMFA        `json:"mfa"`
+}
+
+type exportProfile struct {
+	ID            int64  `json:"id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	AppID         int32  `json:"app_id"`
+}
+
+type exportRole struct {
+	AppID int32  `json:"app_id"`
+	Role  string `json:"role"`
+}
+
+type exportSession struct {
+	ID        int64     `json:"id"`
+	AppID     int32     `json:"app_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Revoked   bool      `json:"revoked"`
+}
+
+type exportAuditItem struct {
+	ID        int64           `json:"id"`
+	AppID     int32           `json:"app_id,omitempty"`
+	Action    string          `json:"action"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type exportMFA struct {
+	Confirmed         bool      `json:"confirmed"`
+	EnrolledAt        time.Time `json:"enrolled_at"`
+	RecoveryCodesLeft int       `json:"recovery_codes_left"`
+}
+
+// ExportMyData returns everything stored about the owner of the access token as JSON.
+func (a *Auth) ExportMyData(ctx context.Context, token string) ([]byte, error) {
+	const op = "auth.ExportMyData"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", claims.UserID))
+	log.Info("exporting user data")
+
+	export, err := a.userExport(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to collect user data", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		log.Error("failed to encode user data", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return data, nil
+}
+
+func (a *Auth) userExport(ctx context.Context, userID int64) (userExport, error) {
+	user, err := a.storage.UserByID(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+
+	export := userExport{
+		Profile: exportProfile{
+			ID:            user.ID,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			AppID:         user.AppID,
+		},
+		Roles:    []exportRole{},
+		Sessions: []exportSession{},
+		AuditLog: []exportAuditItem{},
+	}
+
+	assignments, err := a.storage.UserRoleAssignments(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+	for _, assignment := range assignments {
+		export.Roles = append(export.Roles, exportRole{AppID: assignment.AppID, Role: assignment.Role})
+	}
+
+	sessions, err := a.storage.UserSessions(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, exportSession{
+			ID:        session.ID,
+			AppID:     session.AppID,
+			CreatedAt: session.CreatedAt,
+			ExpiresAt: session.ExpiresAt,
+			Revoked:   session.Revoked,
+		})
+	}
+
+	events, err := a.storage.UserAuditEvents(ctx, userID)
+	if err != nil {
+		return userExport{}, err
+	}
+	for _, event := range events {
+		export.AuditLog = append(export.AuditLog, exportAuditItem{
+			ID:        event.ID,
+			AppID:     event.AppID,
+			Action:    event.Action,
+			Details:   json.RawMessage(event.Details),
+			CreatedAt: event.CreatedAt,
+		})
+	}
+
+	mfa, err := a.storage.MFA(ctx, userID)
+	if err != nil && !errors.Is(err, storage.ErrMFANotFound) {
+		return userExport{}, err
+	}
+	if err == nil {
+		left, err := a.storage.RecoveryCodesLeft(ctx, userID)
+		if err != nil {
+			return userExport{}, err
+		}
+		export.MFA = &exportMFA{
+			Confirmed:         mfa.Confirmed,
+			EnrolledAt:        mfa.CreatedAt,
+			RecoveryCodesLeft: left,
+		}
+	}
+
+	return export, nil
+}
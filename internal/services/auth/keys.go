@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+var (
+	ErrKeysFromFile = errors.New("signing keys are loaded from a file and cannot be rotated")
+
+	errKeyRetired = errors.New("signing key retired")
+	errNoKeyID    = errors.New("token has no key id")
+)
+
+// keyring resolves the keys tokens are signed and verified with.
+//
+// Each app has a ring of stored keys: one active key signing new tokens and
+// verifying keys that still check tokens issued before a rotation. Apps
+// without stored keys sign with their secret under HS256, or get a key pair
+// generated on first use under an asymmetric algorithm. A global key loaded
+// from a PEM file replaces the per-app rings altogether.
+//
+// Tokens signed with the app secret carry no kid. The first rotation of an
+// app puts the secret on the ring under appSecretKeyID, so that it stops
+// verifying them once the overlap of that rotation is over.
+type keyring struct {
+	storage   Storage
+	algorithm string
+	global    *jwt.Key
+
+	mu     sync.RWMutex
+	parsed map[string]jwt.Key
+}
+
+func newKeyring(storage Storage, algorithm string, global *jwt.Key) *keyring {
+	return &keyring{
+		storage:   storage,
+		algorithm: algorithm,
+		global:    global,
+		parsed:    make(map[string]jwt.Key),
+	}
+}
+
+// signingKey returns the key new tokens of the app are signed with.
+func (k *keyring) signingKey(ctx context.Context, app models.App) (jwt.Key, error) {
+	if k.global != nil {
+		return *k.global, nil
+	}
+
+	stored, err := k.storage.AppSigningKey(ctx, int32(app.ID))
+	if err == nil {
+		return k.parse(stored)
+	}
+	if !errors.Is(err, storage.ErrKeyNotFound) {
+		return jwt.Key{}, err
+	}
+
+	if !jwt.IsAsymmetric(k.algorithm) {
+		return jwt.HMACKey(app.Secret), nil
+	}
+
+	key, stored, err := k.generate(int32(app.ID))
+	if err != nil {
+		return jwt.Key{}, err
+	}
+
+	err = k.storage.SaveSigningKey(ctx, stored)
+	if errors.Is(err, storage.ErrKeyExists) {
+		// Another request generated the key first.
+		stored, err := k.storage.AppSigningKey(ctx, int32(app.ID))
+		if err != nil {
+			return jwt.Key{}, err
+		}
+		return k.parse(stored)
+	}
+	if err != nil {
+		return jwt.Key{}, err
+	}
+
+	return key, nil
+}
+
+// verificationKey returns the key a token with the given kid header and
+// app_id claim must have been signed with.
+func (k *keyring) verificationKey(ctx context.Context, kid string, appID int32) (jwt.Key, error) {
+	if kid == "" {
+		return k.appSecretKey(ctx, appID)
+	}
+	if k.global != nil && kid == k.global.ID {
+		return *k.global, nil
+	}
+	if kid == appSecretKeyID(appID) {
+		// The secret is never signed with under its ring id.
+		return jwt.Key{}, storage.ErrKeyNotFound
+	}
+
+	stored, err := k.storage.SigningKey(ctx, kid)
+	if err != nil {
+		return jwt.Key{}, err
+	}
+	if stored.AppID != appID {
+		return jwt.Key{}, storage.ErrKeyNotFound
+	}
+	if !stored.CanVerify(time.Now()) {
+		return jwt.Key{}, errKeyRetired
+	}
+
+	return k.parse(stored)
+}
+
+// appSecretKey returns the key of tokens without a kid, the app secret. It
+// only verifies them under HS256 without a global key, while the app has no
+// stored keys or until the secret put on the ring by the first rotation is
+// retired.
+func (k *keyring) appSecretKey(ctx context.Context, appID int32) (jwt.Key, error) {
+	if k.global != nil || k.algorithm != jwt.AlgHS256 {
+		return jwt.Key{}, errNoKeyID
+	}
+
+	member, err := k.storage.SigningKey(ctx, appSecretKeyID(appID))
+	switch {
+	case err == nil:
+		if !member.CanVerify(time.Now()) {
+			return jwt.Key{}, errKeyRetired
+		}
+	case errors.Is(err, storage.ErrKeyNotFound):
+		stored, err := k.storage.SigningKeys(ctx, appID)
+		if err != nil {
+			return jwt.Key{}, err
+		}
+		if len(stored) > 0 {
+			return jwt.Key{}, errNoKeyID
+		}
+	default:
+		return jwt.Key{}, err
+	}
+
+	app, err := k.storage.App(ctx, appID)
+	if err != nil {
+		return jwt.Key{}, err
+	}
+	return jwt.HMACKey(app.Secret), nil
+}
+
+// secretInUse reports whether the app secret still signs or verifies tokens.
+func (k *keyring) secretInUse(ctx context.Context, appID int32) (bool, error) {
+	_, err := k.appSecretKey(ctx, appID)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, errNoKeyID), errors.Is(err, errKeyRetired):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// appSecretKeyID is the id the app secret is kept under on the ring of the
+// app. It holds no key material: the current secret is used while it lasts.
+func appSecretKeyID(appID int32) string {
+	return fmt.Sprintf("app-secret-%d", appID)
+}
+
+// publicKeys returns the public keys of the app, or of every app if appID is 0.
+func (k *keyring) publicKeys(ctx context.Context, appID int32) ([]jwt.JWK, error) {
+	var keys []jwt.JWK
+
+	if k.global != nil {
+		jwk, err := k.global.JWK()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwk)
+	}
+
+	stored, err := k.storage.SigningKeys(ctx, appID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, s := range stored {
+		if !jwt.IsAsymmetric(s.Algorithm) || !s.CanVerify(now) {
+			continue
+		}
+
+		key, err := k.parse(s)
+		if err != nil {
+			return nil, err
+		}
+		jwk, err := key.JWK()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, jwk)
+	}
+
+	return keys, nil
+}
+
+// generate creates a new key of the configured algorithm in its stored form.
+func (k *keyring) generate(appID int32) (jwt.Key, models.SigningKey, error) {
+	key, err := jwt.GenerateKey(k.algorithm)
+	if err != nil {
+		return jwt.Key{}, models.SigningKey{}, err
+	}
+
+	private := key.Secret()
+	if jwt.IsAsymmetric(key.Algorithm) {
+		private, err = key.MarshalPrivateKeyPEM()
+		if err != nil {
+			return jwt.Key{}, models.SigningKey{}, err
+		}
+	}
+
+	return key, models.SigningKey{
+		ID:         key.ID,
+		AppID:      appID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: private,
+		Status:     models.KeyStatusActive,
+	}, nil
+}
+
+func (k *keyring) parse(stored models.SigningKey) (jwt.Key, error) {
+	if stored.Algorithm == jwt.AlgHS256 {
+		return jwt.NewHMACKey(stored.ID, stored.PrivateKey), nil
+	}
+
+	k.mu.RLock()
+	key, ok := k.parsed[stored.ID]
+	k.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	key, err := jwt.ParsePrivateKeyPEM(stored.PrivateKey)
+	if err != nil {
+		return jwt.Key{}, fmt.Errorf("parse signing key %s: %w", stored.ID, err)
+	}
+
+	k.mu.Lock()
+	k.parsed[stored.ID] = key
+	k.mu.Unlock()
+
+	return key, nil
+}
+
+// JWKS returns the public signing keys of the app, or of every app if appID is 0.
+func (a *Auth) JWKS(ctx context.Context, appID int32) ([]jwt.JWK, error) {
+	const op = "auth.JWKS"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", int(appID)),
+	)
+
+	keys, err := a.keys.publicKeys(ctx, appID)
+	if err != nil {
+		log.Error("failed to list signing keys", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RotateSigningKey generates a new active signing key for the app using the
+// configured algorithm. The previous key keeps verifying tokens for overlap
+// (the configured default if zero), or is retired at once if revokePrevious
+// is set. actorID is the admin performing the rotation, or 0 for the CLI.
+func (a *Auth) RotateSigningKey(
+	ctx context.Context,
+	actorID int64,
+	appID int32,
+	overlap time.Duration,
+	revokePrevious bool,
+) (models.SigningKey, error) {
+	const op = "auth.RotateSigningKey"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int("app_id", int(appID)),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("rotating signing key")
+
+	if a.keys.global != nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, ErrKeysFromFile)
+	}
+
+	if _, err := a.storage.App(ctx, appID); err != nil {
+		if errors.Is(err, storage.ErrAppNotFound) {
+			log.Warn("app not found")
+			return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var previousID string
+	previous, err := a.storage.AppSigningKey(ctx, appID)
+	if err == nil {
+		previousID = previous.ID
+	} else if !errors.Is(err, storage.ErrKeyNotFound) {
+		log.Error("failed to get the active key", slog.String("error", err.Error()))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	// Under HS256 an app without stored keys signs with its secret, which is
+	// retired by the rotation like any previous key.
+	retireSecret := previousID == "" && a.keys.algorithm == jwt.AlgHS256
+	if retireSecret {
+		previousID = appSecretKeyID(appID)
+	}
+
+	if overlap == 0 {
+		overlap = a.signing.RotationOverlap
+	}
+	retiresAt := time.Now().Add(overlap)
+	if revokePrevious {
+		retiresAt = time.Now()
+	}
+
+	_, key, err := a.keys.generate(appID)
+	if err != nil {
+		log.Error("failed to generate key", slog.String("error", err.Error()))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"kid":          key.ID,
+		"algorithm":    key.Algorithm,
+		"previous_kid": previousID,
+		"retires_at":   retiresAt.UTC(),
+	})
+	if err != nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.storage.WithTx(ctx, func(tx Storage) error {
+		if retireSecret {
+			err := tx.SaveSigningKey(ctx, models.SigningKey{
+				ID:         appSecretKeyID(appID),
+				AppID:      appID,
+				Algorithm:  jwt.AlgHS256,
+				PrivateKey: []byte{},
+				Status:     models.KeyStatusActive,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.RotateSigningKey(ctx, key, retiresAt, models.AuditEvent{
+			UserID:  actorID,
+			AppID:   appID,
+			Action:  models.AuditSigningKeyRotated,
+			Details: string(details),
+		})
+	})
+	if err != nil {
+		log.Error("failed to rotate signing key", slog.String("error", err.Error()))
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("signing key rotated", slog.String("kid", key.ID), slog.String("previous_kid", previousID))
+
+	return key, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Logout revokes the session the access token belongs to: its refresh tokens
+// stop working and every access token issued in it fails validation.
+func (a *Auth) Logout(ctx context.Context, token string) error {
+	const op = "auth.Logout"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", claims.UserID), slog.String("jti", claims.TokenID))
+
+	log.Info("logging out")
+
+	if err := a.storage.RevokeSessionFamily(ctx, claims.SessionID); err != nil {
+		log.Error("failed to revoke the session", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	a.denylist.set(claims.SessionID, true)
+
+	log.Info("logged out")
+
+	return nil
+}
+
+// LogoutAll revokes every session of the user the access token belongs to.
+func (a *Auth) LogoutAll(ctx context.Context, token string) error {
+	const op = "auth.LogoutAll"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", claims.UserID), slog.String("jti", claims.TokenID))
+
+	log.Info("logging out of all sessions")
+
+	if err := a.revokeUserSessions(ctx, claims.UserID); err != nil {
+		log.Error("failed to revoke sessions", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("logged out of all sessions")
+
+	return nil
+}
+
+func (a *Auth) revokeUserSessions(ctx context.Context, userID int64) error {
+	families, err := a.storage.RevokeUserSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		a.denylist.set(family, true)
+	}
+	return nil
+}
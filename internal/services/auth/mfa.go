@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"github.com/qu0ta/go-grpc-auth/pkg/totp"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("mfa already enabled")
+	ErrMFANotEnrolled    = errors.New("mfa not enrolled")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrInvalidMFAToken   = errors.New("invalid mfa token")
+)
+
+// recoveryCodeLen is the number of random bytes of a recovery code, which is
+// shown to the user as two groups of base32 characters.
+const recoveryCodeLen = 10
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollMFA generates a new TOTP secret for the owner of the access token.
+// The secret only protects logins once ConfirmMFA has been called with a code from it.
+func (a *Auth) EnrollMFA(ctx context.Context, token string) (models.MFAEnrollment, error) {
+	const op = "auth.EnrollMFA"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", claims.UserID))
+	log.Info("enrolling mfa")
+
+	app, err := a.storage.App(ctx, claims.AppID)
+	if err != nil {
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Error("failed to generate secret", slog.String("error", err.Error()))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.SaveMFASecret(ctx, claims.UserID, secret); err != nil {
+		if errors.Is(err, storage.ErrMFAConfirmed) {
+			log.Warn("mfa already enabled")
+			return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+		}
+
+		log.Error("failed to save secret", slog.String("error", err.Error()))
+		return models.MFAEnrollment{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return models.MFAEnrollment{
+		Secret: secret,
+		URI:    totp.URI(app.Name, claims.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA enables the pending enrollment of the owner of the access token
+// if the code matches its secret, and returns the recovery codes of the user.
+// The codes are only stored hashed and cannot be shown again.
+func (a *Auth) ConfirmMFA(ctx context.Context, token string, code string) ([]string, error) {
+	const op = "auth.ConfirmMFA"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	claims, err := a.ValidateToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", claims.UserID))
+	log.Info("confirming mfa")
+
+	mfa, err := a.storage.MFA(ctx, claims.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFANotFound) {
+			log.Warn("mfa not enrolled")
+			return nil, fmt.Errorf("%s: %w", op, ErrMFANotEnrolled)
+		}
+
+		log.Error("failed to get mfa", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if mfa.Confirmed {
+		log.Warn("mfa already enabled")
+		return nil, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok {
+		log.Info("invalid code")
+		return nil, fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+	}
+
+	codes := make([]string, a.mfa.RecoveryCodes)
+	hashes := make([][]byte, a.mfa.RecoveryCodes)
+	for i := range codes {
+		codes[i], err = newRecoveryCode()
+		if err != nil {
+			log.Error("failed to generate recovery code", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if err := a.storage.ConfirmMFA(ctx, claims.UserID, step, hashes); err != nil {
+		if errors.Is(err, storage.ErrMFAConfirmed) {
+			log.Warn("mfa already enabled")
+			return nil, fmt.Errorf("%s: %w", op, ErrMFAAlreadyEnabled)
+		}
+
+		log.Error("failed to confirm mfa", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("mfa enabled")
+
+	return codes, nil
+}
+
+// VerifyMFA completes a login started by Login. Either a TOTP code or one of
+// the recovery codes of the user is accepted, each of them only once.
+func (a *Auth) VerifyMFA(ctx context.Context, mfaToken string, code string, recoveryCode string) (models.TokenPair, error) {
+	const op = "auth.VerifyMFA"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("verifying second factor")
+
+	challenge, err := a.storage.MFAChallenge(ctx, hashToken(mfaToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrChallengeNotFound) {
+			log.Info("unknown mfa token")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMFAToken)
+		}
+
+		log.Error("failed to get the challenge", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", challenge.UserID))
+
+	if challenge.Used || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= a.mfa.MaxAttempts {
+		log.Info("mfa token is no longer valid")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMFAToken)
+	}
+
+	if err := a.checkSecondFactor(ctx, challenge.UserID, code, recoveryCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			log.Info("invalid code")
+
+			if err := a.storage.FailMFAChallenge(ctx, challenge.ID); err != nil {
+				log.Error("failed to count the attempt", slog.String("error", err.Error()))
+				return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+			}
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMFACode)
+		}
+
+		log.Error("failed to check the code", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.UseMFAChallenge(ctx, challenge.ID); err != nil {
+		if errors.Is(err, storage.ErrChallengeUsed) {
+			log.Info("mfa token already used")
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidMFAToken)
+		}
+
+		log.Error("failed to complete the challenge", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	user, err := a.storage.UserByID(ctx, challenge.UserID)
+	if err != nil {
+		log.Error("failed to get the user", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.storage.App(ctx, challenge.AppID)
+	if err != nil {
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if app.Disabled {
+		log.Info("app disabled")
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrAppDisabled)
+	}
+
+	tokens, err := a.issueTokens(ctx, user, app)
+	if err != nil {
+		log.Error("failed to issue tokens", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("logged in successfully")
+
+	return tokens, nil
+}
+
+// checkSecondFactor accepts the TOTP code if given and the recovery code otherwise.
+func (a *Auth) checkSecondFactor(ctx context.Context, userID int64, code string, recoveryCode string) error {
+	if code == "" {
+		err := a.storage.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if errors.Is(err, storage.ErrRecoveryCodeNotFound) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+
+	mfa, err := a.storage.MFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFANotFound) {
+			return ErrInvalidMFACode
+		}
+		return err
+	}
+
+	step, ok := totp.Validate(mfa.Secret, code, time.Now())
+	if !ok || !mfa.Confirmed {
+		return ErrInvalidMFACode
+	}
+
+	err = a.storage.UseMFAStep(ctx, userID, step)
+	if errors.Is(err, storage.ErrMFAStepUsed) {
+		return ErrInvalidMFACode
+	}
+	return err
+}
+
+// mfaEnabled reports whether logins of the user require a second factor.
+func (a *Auth) mfaEnabled(ctx context.Context, userID int64) (bool, error) {
+	mfa, err := a.storage.MFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, storage.ErrMFANotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return mfa.Confirmed, nil
+}
+
+// newMFAChallenge stores a challenge for the second step of a login and returns its token.
+func (a *Auth) newMFAChallenge(ctx context.Context, user models.User, appID int32) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = a.storage.SaveMFAChallenge(ctx, models.MFAChallenge{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		AppID:     appID,
+		ExpiresAt: time.Now().Add(a.mfa.ChallengeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func newRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))
+	half := len(code) / 2
+	return code[:half] + "-" + code[half:], nil
+}
+
+// normalizeRecoveryCode makes recovery codes insensitive to case and to the
+// separators users tend to type differently.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
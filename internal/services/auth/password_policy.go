@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrWeakPassword = errors.New("password does not satisfy the password policy")
+
+// minEmailPartLength is the shortest local part of an email that passwords
+// must not contain. Shorter ones would reject too many unrelated passwords.
+const minEmailPartLength = 3
+
+// PasswordPolicyError lists the rules of the password policy of the app that
+// a password breaks. It matches ErrWeakPassword.
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrWeakPassword, strings.Join(e.Violations, "; "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// checkPasswordPolicy returns *PasswordPolicyError if the password of the
+// user with the given email breaks any rule of the policy, or another error if
+// the list of breached passwords cannot be read.
+func (a *Auth) checkPasswordPolicy(policy models.PasswordPolicy, email string, password string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+	if policy.MaxLength > 0 && len(password) > policy.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", policy.MaxLength))
+	}
+	if charClasses(password) < policy.MinClasses {
+		violations = append(violations, fmt.Sprintf(
+			"must mix at least %d of lowercase letters, uppercase letters, digits and symbols", policy.MinClasses))
+	}
+	if policy.ForbidEmail && containsEmail(password, email) {
+		violations = append(violations, "must not contain the email")
+	}
+	if policy.CheckBreached && a.breached != nil {
+		breached, err := a.breached.Contains(password)
+		if err != nil {
+			return fmt.Errorf("check breached passwords: %w", err)
+		}
+		if breached {
+			violations = append(violations, "has appeared in a data breach")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// checkUserPasswordPolicy checks a new password of the user against the
+// password policy of the user's app.
+func (a *Auth) checkUserPasswordPolicy(ctx context.Context, user models.User, password string) error {
+	app, err := a.storage.App(ctx, user.AppID)
+	if err != nil {
+		return fmt.Errorf("get the app: %w", err)
+	}
+	return a.checkPasswordPolicy(app.PasswordPolicy, user.Email, password)
+}
+
+// charClasses returns how many of lowercase letters, uppercase letters,
+// digits and symbols the password contains.
+func charClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			classes++
+		}
+	}
+	return classes
+}
+
+// containsEmail reports whether the password contains the email or its local
+// part, ignoring case.
+func containsEmail(password string, email string) bool {
+	password = strings.ToLower(password)
+	email = strings.ToLower(email)
+
+	if strings.Contains(password, email) {
+		return true
+	}
+
+	local, _, _ := strings.Cut(email, "@")
+	return len(local) >= minEmailPartLength && strings.Contains(password, local)
+}
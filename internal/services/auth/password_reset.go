@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid password reset token")
+)
+
+// RequestPasswordReset sends a single-use password reset token to the user
+// registered in the app with the given email.
+//
+// To not reveal which emails are registered, it succeeds whether or not the
+// user exists and only fails if the user cannot be looked up. The token is
+// saved and delivered in the background, so that neither the time taken nor
+// the failures of the delivery differ between registered and unknown emails.
+func (a *Auth) RequestPasswordReset(ctx context.Context, email string, appID int32) error {
+	const op = "auth.RequestPasswordReset"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("email", email),
+		slog.Int("app_id", int(appID)),
+	)
+
+	log.Info("requesting password reset")
+
+	address, err := a.emails.Normalize(email)
+	if err != nil {
+		log.Info("invalid email", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, ErrInvalidEmail)
+	}
+
+	user, err := a.storage.User(ctx, appID, address.Normalized)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user not found")
+			return nil
+		}
+
+		log.Error("failed to get the user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		a.sendPasswordReset(context.WithoutCancel(ctx), log, user)
+	}()
+
+	log.Info("password reset requested")
+
+	return nil
+}
+
+// Wait waits for the password resets being delivered in the background. It
+// must be called before the storage is closed, as they save their tokens.
+func (a *Auth) Wait() {
+	a.background.Wait()
+}
+
+// sendPasswordReset saves a new reset token of the user and delivers it.
+// Failures are only logged, as reporting them would reveal that the user exists.
+func (a *Auth) sendPasswordReset(ctx context.Context, log *slog.Logger, user models.User) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		log.Error("failed to create reset token", slog.String("error", err.Error()))
+		return
+	}
+
+	err = a.storage.SavePasswordReset(ctx, models.PasswordReset{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(a.reset.TokenTTL),
+	})
+	if err != nil {
+		log.Error("failed to save reset token", slog.String("error", err.Error()))
+		return
+	}
+
+	if err := a.notifier.PasswordReset(ctx, user, token); err != nil {
+		log.Error("failed to send reset token", slog.String("error", err.Error()))
+		return
+	}
+
+	log.Info("reset token sent")
+}
+
+// ResetPassword sets the new password of the user the reset token was issued
+// to and revokes all of their sessions.
+func (a *Auth) ResetPassword(ctx context.Context, token string, newPassword string) error {
+	const op = "auth.ResetPassword"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("resetting password")
+
+	reset, err := a.storage.PasswordReset(ctx, hashToken(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrResetNotFound) {
+			log.Info("unknown reset token")
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		log.Error("failed to get the reset", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("uid", reset.UserID))
+
+	if reset.Used || time.Now().After(reset.ExpiresAt) {
+		log.Info("reset token is no longer valid")
+		return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+	}
+
+	user, err := a.storage.UserByID(ctx, reset.UserID)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			log.Info("user of the reset token not found")
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		log.Error("failed to get the user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.checkUserPasswordPolicy(ctx, user, newPassword); err != nil {
+		if errors.Is(err, ErrWeakPassword) {
+			log.Info("password rejected", slog.String("error", err.Error()))
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to check the password policy", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	passwordHash, err := a.hasher.Hash([]byte(newPassword))
+	if err != nil {
+		log.Error("failed to hash password", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	var families []string
+	err = a.storage.WithTx(ctx, func(tx storage.Storage) error {
+		if err := tx.UsePasswordReset(ctx, reset); err != nil {
+			return err
+		}
+
+		var err error
+		families, err = tx.ChangePassword(ctx, reset.UserID, passwordHash, "")
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrResetUsed) {
+			log.Info("reset token already used")
+			return fmt.Errorf("%s: %w", op, ErrInvalidResetToken)
+		}
+
+		log.Error("failed to reset password", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, family := range families {
+		a.denylist.set(family, true)
+	}
+
+	log.Info("password reset", slog.Int("revoked_sessions", len(families)))
+
+	return nil
+}
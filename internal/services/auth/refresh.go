@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"log/slog"
+	"time"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+)
+
+const opaqueTokenLen = 32
+
+// Refresh exchanges a refresh token for a new access token.
+//
+// Unless rotation is disabled the presented token is invalidated and a new one
+// from the same family is returned. Presenting a token that was already rotated
+// is treated as theft and, with the strict policy, revokes the whole family.
+func (a *Auth) Refresh(ctx context.Context, refreshToken string) (models.TokenPair, error) {
+	const op = "auth.Refresh"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("refreshing token")
+
+	session, err := a.storage.Session(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Warn("session not found")
+
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+		}
+
+		log.Error("failed to get the session", slog.String("error", err.Error()))
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log = log.With(slog.Int64("session_id", session.ID), slog.Int64("uid", session.UserID))
+
+	if session.Revoked {
+		log.Warn("session is revoked")
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+	if session.Rotated {
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.handleReuse(ctx, log, session))
+	}
+	if time.Now().After(session.ExpiresAt) {
+		log.Info("refresh token expired")
+
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, ErrInvalidRefreshToken)
+	}
+
+	user, err := a.storage.UserByID(ctx, session.UserID)
+	if err != nil {
+		log.Error("failed to get the user", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	app, err := a.storage.App(ctx, session.AppID)
+	if err != nil {
+		log.Error("failed to get the app", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if a.refresh.Rotation != config.RotationNone {
+		// The old token is only spent if the new one is saved, so that a
+		// failed refresh can be retried with it.
+		err := a.storage.WithTx(ctx, func(tx storage.Storage) error {
+			if err := tx.RotateSession(ctx, session.ID); err != nil {
+				return err
+			}
+
+			var err error
+			refreshToken, err = a.newRefreshToken(ctx, tx, user, app, session.Family)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, storage.ErrSessionRotated) {
+				return models.TokenPair{}, fmt.Errorf("%s: %w", op, a.handleReuse(ctx, log, session))
+			}
+
+			log.Error("failed to rotate the session", slog.String("error", err.Error()))
+			return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	key, err := a.keys.signingKey(ctx, app)
+	if err != nil {
+		log.Error("failed to get the signing key", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	roles, err := a.storage.UserRoles(ctx, user.ID, session.AppID)
+	if err != nil {
+		log.Error("failed to get the user roles", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	token, err := jwt.NewToken(user, app, session.Family, roles, a.tokenTTL, key)
+	if err != nil {
+		log.Error("failed to create token", slog.String("error", err.Error()))
+		return models.TokenPair{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("token refreshed")
+
+	return models.TokenPair{AccessToken: token, RefreshToken: refreshToken}, nil
+}
+
+// handleReuse reacts to a refresh token that has already been exchanged once.
+func (a *Auth) handleReuse(ctx context.Context, log *slog.Logger, session models.Session) error {
+	log.Warn("refresh token reuse detected", slog.String("family", session.Family))
+
+	if a.refresh.Rotation != config.RotationStrict {
+		return ErrInvalidRefreshToken
+	}
+
+	if err := a.storage.RevokeSessionFamily(ctx, session.Family); err != nil {
+		log.Error("failed to revoke session family", slog.String("error", err.Error()))
+		return err
+	}
+	a.denylist.set(session.Family, true)
+
+	return ErrInvalidRefreshToken
+}
+
+// newRefreshToken generates a refresh token for the given family and stores its hash in s.
+func (a *Auth) newRefreshToken(ctx context.Context, s Storage, user models.User, app models.App, family string) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.SaveSession(ctx, models.Session{
+		UserID:    user.ID,
+		AppID:     int32(app.ID),
+		Family:    family,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(a.refresh.TTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func newOpaqueToken() (string, error) {
+	b := make([]byte, opaqueTokenLen)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
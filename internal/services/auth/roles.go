@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"log/slog"
+)
+
+// CreateRole defines a new role granting the given permissions.
+func (a *Auth) CreateRole(ctx context.Context, actorID int64, name string, permissions []string) (int64, error) {
+	const op = "auth.CreateRole"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.String("role", name),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("creating role")
+
+	details, err := json.Marshal(map[string]any{
+		"role":        name,
+		"permissions": permissions,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	id, err := a.storage.CreateRole(ctx, models.Role{Name: name, Permissions: permissions}, models.AuditEvent{
+		UserID:  actorID,
+		Action:  models.AuditRoleCreated,
+		Details: string(details),
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrRoleExists) {
+			log.Warn("role already exists")
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to create role", slog.String("error", err.Error()))
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role created", slog.Int64("role_id", id))
+
+	return id, nil
+}
+
+// AssignRole gives the user the named role in the app.
+func (a *Auth) AssignRole(ctx context.Context, actorID int64, userID int64, appID int32, roleName string) error {
+	const op = "auth.AssignRole"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", int(appID)),
+		slog.String("role", roleName),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("assigning role")
+
+	role, event, err := a.roleChange(ctx, actorID, userID, appID, roleName, models.AuditRoleAssigned)
+	if err != nil {
+		log.Warn("failed to resolve role assignment", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.AssignRole(ctx, userID, appID, role.ID, event); err != nil {
+		log.Error("failed to assign role", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role assigned")
+
+	return nil
+}
+
+// UnassignRole takes the named role in the app away from the user.
+func (a *Auth) UnassignRole(ctx context.Context, actorID int64, userID int64, appID int32, roleName string) error {
+	const op = "auth.UnassignRole"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", int(appID)),
+		slog.String("role", roleName),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("unassigning role")
+
+	role, event, err := a.roleChange(ctx, actorID, userID, appID, roleName, models.AuditRoleUnassigned)
+	if err != nil {
+		log.Warn("failed to resolve role assignment", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := a.storage.UnassignRole(ctx, userID, appID, role.ID, a.admin.AppID, event); err != nil {
+		if errors.Is(err, storage.ErrLastAdmin) {
+			log.Warn("refused to remove the last admin")
+			return fmt.Errorf("%s: %w", op, err)
+		}
+
+		log.Error("failed to unassign role", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("role unassigned")
+
+	return nil
+}
+
+// SetAdmin grants or revokes the built-in admin role of the user in the app.
+// Revoking the role of the last admin of the app fails with storage.ErrLastAdmin
+// unless the app of the service admins still has one.
+func (a *Auth) SetAdmin(ctx context.Context, actorID int64, userID int64, appID int32, isAdmin bool) error {
+	const op = "auth.SetAdmin"
+
+	var err error
+	if isAdmin {
+		err = a.AssignRole(ctx, actorID, userID, appID, models.RoleAdmin)
+	} else {
+		err = a.UnassignRole(ctx, actorID, userID, appID, models.RoleAdmin)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	return nil
+}
+
+// HasPermission reports whether the user holds a role in the app that grants the permission.
+func (a *Auth) HasPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error) {
+	const op = "auth.HasPermission"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int("app_id", int(appID)),
+		slog.String("permission", permission),
+	)
+
+	log.Info("checking permission")
+
+	has, err := a.storage.HasPermission(ctx, userID, appID, permission)
+	if err != nil {
+		log.Error("failed to check permission", slog.String("error", err.Error()))
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return has, nil
+}
+
+// roleChange looks up the role, the user and the app of an assignment and
+// prepares its audit event. Users only hold roles in the app they are
+// registered in, so a user of another app is not found.
+func (a *Auth) roleChange(
+	ctx context.Context,
+	actorID int64,
+	userID int64,
+	appID int32,
+	roleName string,
+	action string,
+) (models.Role, models.AuditEvent, error) {
+	role, err := a.storage.Role(ctx, roleName)
+	if err != nil {
+		return models.Role{}, models.AuditEvent{}, err
+	}
+	user, err := a.storage.UserByID(ctx, userID)
+	if err != nil {
+		return models.Role{}, models.AuditEvent{}, err
+	}
+	if user.AppID != appID {
+		return models.Role{}, models.AuditEvent{}, storage.ErrUserNotFound
+	}
+	if _, err := a.storage.App(ctx, appID); err != nil {
+		return models.Role{}, models.AuditEvent{}, err
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"role":    role.Name,
+		"user_id": userID,
+	})
+	if err != nil {
+		return models.Role{}, models.AuditEvent{}, err
+	}
+
+	return role, models.AuditEvent{
+		UserID:  actorID,
+		AppID:   appID,
+		Action:  action,
+		Details: string(details),
+	}, nil
+}
@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"log/slog"
+	"time"
+)
+
+var ErrTooManyAttempts = errors.New("too many login attempts")
+
+// TooManyAttemptsError rejects a login while the account or the client
+// address is blocked after too many failures. It matches ErrTooManyAttempts.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("%s: retry after %s", ErrTooManyAttempts, e.RetryAfter)
+}
+
+func (e *TooManyAttemptsError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// throttleKey is a failed login counter consulted on a login.
+type throttleKey struct {
+	scope string
+	key   string
+	cfg   config.ThrottleConfig
+}
+
+// throttleKeys returns the counters of the account and, if known, of the
+// client address. Accounts are keyed by email so that unknown emails are
+// throttled the same way as registered ones. A successful login resets the
+// counter of the account only, the failures of the address just expire.
+func (a *Auth) throttleKeys(email string, appID int32, ip string) []throttleKey {
+	keys := []throttleKey{{
+		scope: models.LoginScopeAccount,
+		key:   models.AccountLoginKey(appID, email),
+		cfg:   a.throttle.Account,
+	}}
+	if ip != "" {
+		keys = append(keys, throttleKey{scope: models.LoginScopeIP, key: ip, cfg: a.throttle.IP})
+	}
+	return keys
+}
+
+// checkThrottle fails with *TooManyAttemptsError if logins of any of the keys are blocked.
+func (a *Auth) checkThrottle(ctx context.Context, keys []throttleKey) error {
+	now := time.Now()
+
+	var retryAfter time.Duration
+	for _, k := range keys {
+		failures, err := a.storage.LoginFailures(ctx, k.scope, k.key)
+		if err != nil {
+			return err
+		}
+		if wait := failures.BlockedUntil.Sub(now); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+
+	if retryAfter > 0 {
+		return &TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// reserveAttempt counts a login attempt for every key before the password is
+// compared, so that guesses sent in parallel cannot all pass checkThrottle
+// before the first of them fails. It returns the attempts counted so far per
+// key and fails with *TooManyAttemptsError if a key is blocked or the attempt
+// is over the lockout threshold of a key.
+func (a *Auth) reserveAttempt(ctx context.Context, keys []throttleKey) ([]int, error) {
+	if err := a.checkThrottle(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	attempts := make([]int, len(keys))
+	var retryAfter time.Duration
+	for i, k := range keys {
+		n, err := a.storage.AddLoginFailure(ctx, k.scope, k.key, now.Add(-k.cfg.Window))
+		if err != nil {
+			return nil, err
+		}
+		attempts[i] = n
+		if n > k.cfg.LockoutAfter {
+			retryAfter = max(retryAfter, k.cfg.LockoutDuration)
+		}
+	}
+
+	if retryAfter > 0 {
+		return nil, &TooManyAttemptsError{RetryAfter: retryAfter}
+	}
+	return attempts, nil
+}
+
+// attemptFailed blocks the keys whose attempts, as counted by reserveAttempt,
+// have reached a threshold.
+func (a *Auth) attemptFailed(ctx context.Context, keys []throttleKey, attempts []int) error {
+	now := time.Now()
+
+	for i, k := range keys {
+		if block := blockDuration(k.cfg, attempts[i]); block > 0 {
+			if err := a.storage.BlockLogin(ctx, k.scope, k.key, now.Add(block)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// attemptSucceeded forgets the failures of the account and takes the attempt
+// back from the address. The failures of the address stay, as a guesser may
+// well know the password of one account it tries, and many users may share
+// the address with it.
+func (a *Auth) attemptSucceeded(ctx context.Context, keys []throttleKey) error {
+	for _, k := range keys {
+		var err error
+		if k.scope == models.LoginScopeAccount {
+			err = a.storage.ResetLoginFailures(ctx, k.scope, k.key)
+		} else {
+			err = a.storage.RemoveLoginFailure(ctx, k.scope, k.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockDuration returns how long logins are blocked after the given number of
+// failures: nothing below BackoffAfter, an exponential back-off up to MaxDelay
+// below LockoutAfter and LockoutDuration from then on.
+func blockDuration(cfg config.ThrottleConfig, failures int) time.Duration {
+	switch {
+	case failures >= cfg.LockoutAfter:
+		return cfg.LockoutDuration
+	case failures >= cfg.BackoffAfter:
+		delay := cfg.BaseDelay
+		for i := cfg.BackoffAfter; i < failures && delay < cfg.MaxDelay; i++ {
+			delay *= 2
+		}
+		return min(delay, cfg.MaxDelay)
+	default:
+		return 0
+	}
+}
+
+// UnlockAccount forgets the failed logins of the user so that it can log in at once.
+func (a *Auth) UnlockAccount(ctx context.Context, actorID int64, userID int64) error {
+	const op = "auth.UnlockAccount"
+
+	log := a.log.With(
+		slog.String("op", op),
+		slog.Int64("user_id", userID),
+		slog.Int64("actor_id", actorID),
+	)
+
+	log.Info("unlocking account")
+
+	user, err := a.storage.UserByID(ctx, userID)
+	if err != nil {
+		log.Warn("failed to get the user", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	details, err := json.Marshal(map[string]any{
+		"user_id": userID,
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	err = a.storage.UnlockLogin(ctx, models.LoginScopeAccount, models.AccountLoginKey(user.AppID, user.NormalizedEmail), models.AuditEvent{
+		UserID:  actorID,
+		AppID:   user.AppID,
+		Action:  models.AuditAccountUnlocked,
+		Details: string(details),
+	})
+	if err != nil {
+		log.Error("failed to unlock account", slog.String("error", err.Error()))
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	log.Info("account unlocked")
+
+	return nil
+}
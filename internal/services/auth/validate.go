@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"log/slog"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// ValidateToken checks the signature of the access token against the key of
+// its app, its expiry and whether its session has been revoked, and returns its claims.
+func (a *Auth) ValidateToken(ctx context.Context, token string) (models.Claims, error) {
+	const op = "auth.ValidateToken"
+
+	log := a.log.With(
+		slog.String("op", op),
+	)
+
+	log.Info("validating token")
+
+	var keyErr error
+	claims, err := jwt.ParseToken(token, func(kid string, appID int32) (jwt.Key, error) {
+		key, err := a.keys.verificationKey(ctx, kid, appID)
+		if err != nil {
+			keyErr = err
+		}
+		return key, err
+	})
+	if keyErr != nil && !errors.Is(keyErr, storage.ErrAppNotFound) &&
+		!errors.Is(keyErr, storage.ErrKeyNotFound) && !errors.Is(keyErr, errKeyRetired) &&
+		!errors.Is(keyErr, errNoKeyID) {
+		log.Error("failed to get the verification key", slog.String("error", keyErr.Error()))
+		return models.Claims{}, fmt.Errorf("%s: %w", op, keyErr)
+	}
+	if err != nil {
+		log.Info("token rejected", slog.String("error", err.Error()))
+		return models.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	log = log.With(slog.Int64("uid", claims.UID))
+
+	revoked, err := a.sessionRevoked(ctx, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			log.Warn("session not found")
+			return models.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+		}
+
+		log.Error("failed to check the session", slog.String("error", err.Error()))
+		return models.Claims{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if revoked {
+		log.Info("session is revoked")
+		return models.Claims{}, fmt.Errorf("%s: %w", op, ErrInvalidToken)
+	}
+
+	return models.Claims{
+		TokenID:       claims.ID,
+		UserID:        claims.UID,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		AppID:         claims.AppID,
+		SessionID:     claims.SessionID,
+		Roles:         claims.Roles,
+		ExpiresAt:     claims.ExpiresAt.Time,
+	}, nil
+}
+
+// sessionRevoked consults the denylist cache before asking the storage.
+func (a *Auth) sessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	if revoked, ok := a.denylist.revoked(sessionID); ok {
+		return revoked, nil
+	}
+
+	revoked, err := a.storage.SessionFamilyRevoked(ctx, sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	a.denylist.set(sessionID, revoked)
+	return revoked, nil
+}
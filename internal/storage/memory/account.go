@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"slices"
+	"sort"
+	"time"
+)
+
+// ChangePassword sets the password hash of the user and revokes all of their
+// sessions except those of keepFamily. It returns the revoked session families.
+func (s *Storage) ChangePassword(ctx context.Context, userID int64, passwordHash []byte, keepFamily string) ([]string, error) {
+	const op = "storage.memory.ChangePassword"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.user(userID)
+	if u == nil {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.PasswordHash = bytes.Clone(passwordHash)
+
+	return s.revokeSessions(func(session *models.Session) bool {
+		return session.UserID == userID && session.Family != keepFamily
+	}), nil
+}
+
+// UpdatePasswordHash replaces the password hash of the user with a new hash of
+// the same password, unless the password has been changed since oldHash was read.
+func (s *Storage) UpdatePasswordHash(ctx context.Context, userID int64, oldHash []byte, newHash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.user(userID); u != nil && bytes.Equal(u.PasswordHash, oldHash) {
+		u.PasswordHash = bytes.Clone(newHash)
+	}
+	return nil
+}
+
+// ChangeEmail sets the email of the user, marks it as unverified and drops
+// the pending verifications of the previous email. It fails with
+// storage.ErrUserExists if the app already has a user with the normalized email.
+func (s *Storage) ChangeEmail(ctx context.Context, userID int64, email string, normalizedEmail string) error {
+	const op = "storage.memory.ChangeEmail"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.user(userID)
+	if u == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	if s.emailTaken(u.AppID, email, normalizedEmail, userID) {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	u.Email = email
+	u.NormalizedEmail = normalizedEmail
+	u.EmailVerified = false
+	s.useVerifications(userID)
+	return nil
+}
+
+// MarkUserDeleted marks the user as deleted, so that they can no longer sign
+// in. It fails with storage.ErrUserNotFound if the user is already deleted.
+func (s *Storage) MarkUserDeleted(ctx context.Context, userID int64) error {
+	const op = "storage.memory.MarkUserDeleted"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u := s.user(userID)
+	if u == nil || !u.deletedAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	u.deletedAt = time.Now().UTC()
+	return nil
+}
+
+// UnassignUserRoles takes every role away from the user. It fails with
+// storage.ErrLastAdmin if the user is the only admin of an app and the app of
+// the service admins, adminAppID, has no other admin.
+func (s *Storage) UnassignUserRoles(ctx context.Context, userID int64, adminAppID int32) error {
+	const op = "storage.memory.UnassignUserRoles"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lastAdmin := slices.ContainsFunc(s.userRoles, func(held userRole) bool {
+		return held.userID == userID && s.roleName(held.roleID) == models.RoleAdmin &&
+			!slices.ContainsFunc(s.userRoles, func(ur userRole) bool {
+				return ur.roleID == held.roleID && ur.userID != userID &&
+					(ur.appID == held.appID || ur.appID == adminAppID)
+			})
+	})
+	if lastAdmin {
+		return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+	}
+
+	s.userRoles = slices.DeleteFunc(s.userRoles, func(ur userRole) bool { return ur.userID == userID })
+	return nil
+}
+
+// PurgeDeletedUsers removes the users deleted before the given time together
+// with everything stored about them. Audit entries are kept without their actor.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purge := make(map[int64]bool)
+	for _, u := range s.users {
+		if !u.deletedAt.IsZero() && u.deletedAt.Before(deletedBefore) {
+			purge[u.ID] = true
+			delete(s.loginFailures, loginKey{
+				scope: models.LoginScopeAccount,
+				key:   models.AccountLoginKey(u.AppID, u.NormalizedEmail),
+			})
+		}
+	}
+	if len(purge) == 0 {
+		return 0, nil
+	}
+
+	s.users = slices.DeleteFunc(s.users, func(u *user) bool { return purge[u.ID] })
+	s.sessions = slices.DeleteFunc(s.sessions, func(session *models.Session) bool { return purge[session.UserID] })
+	s.userRoles = slices.DeleteFunc(s.userRoles, func(ur userRole) bool { return purge[ur.userID] })
+	s.recoveryCodes = slices.DeleteFunc(s.recoveryCodes, func(code *recoveryCode) bool { return purge[code.userID] })
+	s.challenges = slices.DeleteFunc(s.challenges, func(c *models.MFAChallenge) bool { return purge[c.UserID] })
+	s.resets = slices.DeleteFunc(s.resets, func(r *models.PasswordReset) bool { return purge[r.UserID] })
+	s.verifications = slices.DeleteFunc(s.verifications, func(v *models.EmailVerification) bool { return purge[v.UserID] })
+	for userID := range purge {
+		delete(s.mfa, userID)
+	}
+	for i := range s.auditLog {
+		if purge[s.auditLog[i].UserID] {
+			s.auditLog[i].UserID = 0
+		}
+	}
+	return int64(len(purge)), nil
+}
+
+// UserRoleAssignments returns the roles the user holds in every app.
+func (s *Storage) UserRoleAssignments(ctx context.Context, userID int64) ([]models.RoleAssignment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var assignments []models.RoleAssignment
+	for _, ur := range s.userRoles {
+		if ur.userID != userID {
+			continue
+		}
+		if name := s.roleName(ur.roleID); name != "" {
+			assignments = append(assignments, models.RoleAssignment{AppID: ur.appID, Role: name})
+		}
+	}
+	sort.Slice(assignments, func(i, j int) bool {
+		if assignments[i].AppID != assignments[j].AppID {
+			return assignments[i].AppID < assignments[j].AppID
+		}
+		return assignments[i].Role < assignments[j].Role
+	})
+	return assignments, nil
+}
+
+// UserSessions returns every stored session of the user, oldest first.
+func (s *Storage) UserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var sessions []models.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, copySession(session))
+		}
+	}
+	return sessions, nil
+}
+
+// UserAuditEvents returns the audit entries of the actions the user performed, oldest first.
+func (s *Storage) UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var events []models.AuditEvent
+	for _, event := range s.auditLog {
+		// System and CLI actions have no actor, so they belong to no user.
+		if event.UserID != 0 && event.UserID == userID {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
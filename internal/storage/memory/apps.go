@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"slices"
+)
+
+func (s *Storage) App(ctx context.Context, id int32) (models.App, error) {
+	const op = "storage.memory.App"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	app := s.app(id)
+	if app == nil {
+		return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	return copyApp(app), nil
+}
+
+// Apps returns every app ordered by id.
+func (s *Storage) Apps(ctx context.Context) ([]models.App, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var apps []models.App
+	for _, app := range s.apps {
+		apps = append(apps, copyApp(app))
+	}
+	return apps, nil
+}
+
+// CreateApp saves the app with its metadata and records the event in the
+// audit log. It fails with storage.ErrAppExists if the name is taken.
+func (s *Storage) CreateApp(ctx context.Context, app models.App, event models.AuditEvent) (int64, error) {
+	const op = "storage.memory.CreateApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	taken := slices.ContainsFunc(s.apps, func(a *models.App) bool {
+		return a.Name == app.Name || a.Secret == app.Secret
+	})
+	if taken {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+
+	created := &models.App{
+		ID:                   s.appIDs.next(),
+		Name:                 app.Name,
+		Secret:               app.Secret,
+		RequireVerifiedEmail: app.RequireVerifiedEmail,
+		PasswordPolicy:       app.PasswordPolicy,
+		Audiences:            uniqueValues(app.Audiences),
+		RedirectURIs:         uniqueValues(app.RedirectURIs),
+	}
+	s.apps = append(s.apps, created)
+
+	event.AppID = int32(created.ID)
+	s.audit(event)
+	return created.ID, nil
+}
+
+// UpdateApp replaces the name, the settings and the metadata of the app and
+// records the event in the audit log. It fails with storage.ErrAppExists if
+// another app has the name.
+func (s *Storage) UpdateApp(ctx context.Context, app models.App, event models.AuditEvent) error {
+	const op = "storage.memory.UpdateApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.apps, func(a *models.App) bool { return a.ID != app.ID && a.Name == app.Name }) {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+	}
+	updated := s.app(int32(app.ID))
+	if updated == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	updated.Name = app.Name
+	updated.RequireVerifiedEmail = app.RequireVerifiedEmail
+	updated.PasswordPolicy = app.PasswordPolicy
+	updated.Audiences = uniqueValues(app.Audiences)
+	updated.RedirectURIs = uniqueValues(app.RedirectURIs)
+	s.audit(event)
+	return nil
+}
+
+// UpdateAppSecret replaces the secret of the app and records the event in the audit log.
+func (s *Storage) UpdateAppSecret(ctx context.Context, appID int32, secret string, event models.AuditEvent) error {
+	const op = "storage.memory.UpdateAppSecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := s.app(appID)
+	if app == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	app.Secret = secret
+	s.audit(event)
+	return nil
+}
+
+// SetAppDisabled disables or enables the app. Disabling revokes every session
+// of the app and returns the families that were still active. Only an actual
+// change is recorded in the audit log.
+func (s *Storage) SetAppDisabled(
+	ctx context.Context,
+	appID int32,
+	disabled bool,
+	event models.AuditEvent,
+) ([]string, error) {
+	const op = "storage.memory.SetAppDisabled"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	app := s.app(appID)
+	if app == nil {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	if app.Disabled != disabled {
+		app.Disabled = disabled
+		s.audit(event)
+	}
+
+	if !disabled {
+		return nil, nil
+	}
+	return s.revokeSessions(func(session *models.Session) bool { return session.AppID == appID }), nil
+}
+
+// DeleteApp deletes the app together with its metadata, signing keys and
+// role assignments and records the event in the audit log. It fails with
+// storage.ErrAppInUse if the app has users, deleted ones awaiting the purge
+// included, and with storage.ErrLastAdmin if it holds the only admin assignments.
+func (s *Storage) DeleteApp(ctx context.Context, appID int32, event models.AuditEvent) error {
+	const op = "storage.memory.DeleteApp"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.users, func(u *user) bool { return u.AppID == appID }) {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppInUse)
+	}
+	if s.app(appID) == nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+	isAdmin := func(ur userRole) bool { return s.roleName(ur.roleID) == models.RoleAdmin }
+	holdsAdmin := slices.ContainsFunc(s.userRoles, func(ur userRole) bool { return ur.appID == appID && isAdmin(ur) })
+	otherAdmin := slices.ContainsFunc(s.userRoles, func(ur userRole) bool { return ur.appID != appID && isAdmin(ur) })
+	if holdsAdmin && !otherAdmin {
+		return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+	}
+
+	s.apps = slices.DeleteFunc(s.apps, func(app *models.App) bool { return app.ID == int64(appID) })
+	s.signingKeys = slices.DeleteFunc(s.signingKeys, func(key *models.SigningKey) bool { return key.AppID == appID })
+	s.userRoles = slices.DeleteFunc(s.userRoles, func(ur userRole) bool { return ur.appID == appID })
+	s.sessions = slices.DeleteFunc(s.sessions, func(session *models.Session) bool { return session.AppID == appID })
+	s.challenges = slices.DeleteFunc(s.challenges, func(c *models.MFAChallenge) bool { return c.AppID == appID })
+	s.audit(event)
+	return nil
+}
+
+func (s *Storage) app(id int32) *models.App {
+	for _, app := range s.apps {
+		if app.ID == int64(id) {
+			return app
+		}
+	}
+	return nil
+}
+
+func copyApp(app *models.App) models.App {
+	c := *app
+	c.Audiences = slices.Clone(app.Audiences)
+	c.RedirectURIs = slices.Clone(app.RedirectURIs)
+	return c
+}
+
+// uniqueValues drops the repeated values, keeping the first occurrence of
+// each. It never returns nil, as the SQL backends read empty metadata as an
+// empty list.
+func uniqueValues(values []string) []string {
+	unique := make([]string, 0, len(values))
+	for _, value := range values {
+		if !slices.Contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"slices"
+	"time"
+)
+
+func (s *Storage) SaveEmailVerification(ctx context.Context, verification models.EmailVerification) error {
+	const op = "storage.memory.SaveEmailVerification"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exists := slices.ContainsFunc(s.verifications, func(v *models.EmailVerification) bool {
+		return bytes.Equal(v.TokenHash, verification.TokenHash)
+	})
+	if exists {
+		return fmt.Errorf("%s: %w", op, errTokenExists)
+	}
+
+	s.verifications = append(s.verifications, &models.EmailVerification{
+		ID:        s.verificationIDs.next(),
+		UserID:    verification.UserID,
+		Email:     verification.Email,
+		TokenHash: bytes.Clone(verification.TokenHash),
+		ExpiresAt: verification.ExpiresAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+func (s *Storage) EmailVerification(ctx context.Context, tokenHash []byte) (models.EmailVerification, error) {
+	const op = "storage.memory.EmailVerification"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, verification := range s.verifications {
+		if bytes.Equal(verification.TokenHash, tokenHash) {
+			v := *verification
+			v.TokenHash = bytes.Clone(verification.TokenHash)
+			return v, nil
+		}
+	}
+	return models.EmailVerification{}, fmt.Errorf("%s: %w", op, storage.ErrVerificationNotFound)
+}
+
+// VerifyEmail uses the verification, together with every other pending
+// verification of the user, and marks the email of the user as verified.
+// It fails with storage.ErrVerificationUsed if the verification has been
+// used before and with storage.ErrVerificationNotFound if the user no longer
+// has the verified email.
+func (s *Storage) VerifyEmail(ctx context.Context, verification models.EmailVerification) error {
+	const op = "storage.memory.VerifyEmail"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.verifications, func(v *models.EmailVerification) bool { return v.ID == verification.ID })
+	if i < 0 || s.verifications[i].Used {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationUsed)
+	}
+
+	u := s.user(verification.UserID)
+	if u == nil || u.Email != verification.Email {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationNotFound)
+	}
+
+	u.EmailVerified = true
+	s.verifications[i].Used = true
+	s.useVerifications(verification.UserID)
+	return nil
+}
+
+// useVerifications marks the pending verifications of the user as used.
+func (s *Storage) useVerifications(userID int64) {
+	for _, verification := range s.verifications {
+		if verification.UserID == userID {
+			verification.Used = true
+		}
+	}
+}
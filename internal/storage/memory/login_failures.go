@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"time"
+)
+
+// LoginFailures returns the failed login counter of the key in the scope. A
+// key without recent failures yields a zero counter.
+func (s *Storage) LoginFailures(ctx context.Context, scope string, key string) (models.LoginFailures, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if failures, ok := s.loginFailures[loginKey{scope: scope, key: key}]; ok {
+		return *failures, nil
+	}
+	return models.LoginFailures{Scope: scope, Key: key}, nil
+}
+
+// AddLoginFailure counts a failed login of the key in the scope and returns
+// the number of failures so far. Failures older than since are forgotten.
+// The service counts every attempt in advance and takes back the successful ones.
+func (s *Storage) AddLoginFailure(ctx context.Context, scope string, key string, since time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := loginKey{scope: scope, key: key}
+	failures, ok := s.loginFailures[k]
+	switch {
+	case !ok:
+		failures = &models.LoginFailures{Scope: scope, Key: key}
+		s.loginFailures[k] = failures
+	case failures.LastFailureAt.Before(since):
+		failures.Failures = 0
+		failures.BlockedUntil = time.Time{}
+	}
+	failures.Failures++
+	failures.LastFailureAt = time.Now().UTC()
+	return failures.Failures, nil
+}
+
+// BlockLogin rejects the logins of the key in the scope until the given time.
+// It never shortens a longer block, which a concurrent failure may have set.
+func (s *Storage) BlockLogin(ctx context.Context, scope string, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if failures, ok := s.loginFailures[loginKey{scope: scope, key: key}]; ok && failures.BlockedUntil.Before(until) {
+		failures.BlockedUntil = until.UTC()
+	}
+	return nil
+}
+
+// RemoveLoginFailure takes back a failure of the key in the scope, counted in
+// advance for an attempt that has succeeded.
+func (s *Storage) RemoveLoginFailure(ctx context.Context, scope string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if failures, ok := s.loginFailures[loginKey{scope: scope, key: key}]; ok && failures.Failures > 0 {
+		failures.Failures--
+	}
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins of the key in the scope.
+func (s *Storage) ResetLoginFailures(ctx context.Context, scope string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginFailures, loginKey{scope: scope, key: key})
+	return nil
+}
+
+// UnlockLogin forgets the failed logins of the key in the scope and records
+// the event if there were any.
+func (s *Storage) UnlockLogin(ctx context.Context, scope string, key string, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := loginKey{scope: scope, key: key}
+	if _, ok := s.loginFailures[k]; !ok {
+		return nil
+	}
+	delete(s.loginFailures, k)
+	s.audit(event)
+	return nil
+}
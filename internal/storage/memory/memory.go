@@ -0,0 +1,563 @@
+// Package memory keeps the data of the service in memory. It is meant for
+// tests and ephemeral environments: everything is lost when the process exits.
+package memory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errTokenExists mirrors the unique constraints the SQL backends put on token hashes.
+var errTokenExists = errors.New("token hash already exists")
+
+// Storage implements the same contract as the SQL backends. Every method runs
+// under a single lock, so each call is atomic just like a transaction.
+type Storage struct {
+	mu sync.RWMutex
+	data
+}
+
+// data is everything the storage keeps. It is copied by WithTx.
+type data struct {
+	apps          []*models.App
+	users         []*user
+	sessions      []*models.Session
+	signingKeys   []*models.SigningKey
+	roles         []*models.Role
+	userRoles     []userRole
+	mfa           map[int64]*models.MFA
+	recoveryCodes []*recoveryCode
+	challenges    []*models.MFAChallenge
+	resets        []*models.PasswordReset
+	verifications []*models.EmailVerification
+	loginFailures map[loginKey]*models.LoginFailures
+	auditLog      []models.AuditEvent
+
+	appIDs, userIDs, sessionIDs, roleIDs, challengeIDs, resetIDs, verificationIDs, auditIDs sequence
+}
+
+type user struct {
+	models.User
+	// deletedAt is zero for users that have not been deleted.
+	deletedAt time.Time
+}
+
+type userRole struct {
+	userID int64
+	appID  int32
+	roleID int64
+}
+
+type recoveryCode struct {
+	userID int64
+	hash   []byte
+	used   bool
+}
+
+type loginKey struct {
+	scope string
+	key   string
+}
+
+// sequence hands out ids the way identity columns do, starting at 1.
+type sequence int64
+
+func (s *sequence) next() int64 {
+	*s++
+	return int64(*s)
+}
+
+// New returns an empty storage holding only the built-in admin role, as the
+// migrations of the SQL backends create it.
+func New() *Storage {
+	s := &Storage{data: data{
+		mfa:           make(map[int64]*models.MFA),
+		loginFailures: make(map[loginKey]*models.LoginFailures),
+	}}
+	s.roles = append(s.roles, &models.Role{
+		ID:          s.roleIDs.next(),
+		Name:        models.RoleAdmin,
+		Permissions: []string{models.PermissionAll},
+	})
+	return s
+}
+
+// SaveUser saves a new user of the app. It fails with storage.ErrUserExists
+// if the app already has a user with the email or the normalized email.
+func (s *Storage) SaveUser(ctx context.Context, email string, normalizedEmail string, passwordHash []byte, appId int32) (int64, error) {
+	const op = "storage.memory.SaveUser"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.emailTaken(appId, email, normalizedEmail, 0) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+	}
+
+	u := &user{
+		User: models.User{
+			ID:              s.userIDs.next(),
+			Email:           email,
+			NormalizedEmail: normalizedEmail,
+			PasswordHash:    bytes.Clone(passwordHash),
+			AppID:           appId,
+		},
+	}
+	s.users = append(s.users, u)
+	return u.ID, nil
+}
+
+// emailTaken reports whether a user of the app other than exceptID has the
+// email or the normalized email. Deleted users keep their emails until purged.
+func (s *Storage) emailTaken(appID int32, email string, normalizedEmail string, exceptID int64) bool {
+	return slices.ContainsFunc(s.users, func(u *user) bool {
+		return u.ID != exceptID && u.AppID == appID && (u.Email == email || u.NormalizedEmail == normalizedEmail)
+	})
+}
+
+// User returns the account registered with the normalized email in the app.
+func (s *Storage) User(ctx context.Context, appID int32, normalizedEmail string) (models.User, error) {
+	const op = "storage.memory.User"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, u := range s.users {
+		if u.AppID == appID && u.NormalizedEmail == normalizedEmail && u.deletedAt.IsZero() {
+			return u.copy(), nil
+		}
+	}
+	return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+}
+
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.memory.UserByID"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u := s.user(id)
+	if u == nil || !u.deletedAt.IsZero() {
+		return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return u.copy(), nil
+}
+
+// user returns the user with the id, deleted or not, or nil.
+func (s *Storage) user(id int64) *user {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+	return nil
+}
+
+func (u *user) copy() models.User {
+	c := u.User
+	c.PasswordHash = bytes.Clone(u.PasswordHash)
+	return c
+}
+
+// IsAdmin reports whether the user holds the built-in admin role in the app.
+func (s *Storage) IsAdmin(ctx context.Context, userID int64, appID int32) (bool, error) {
+	const op = "storage.memory.IsAdmin"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	u := s.user(userID)
+	if u == nil || !u.deletedAt.IsZero() {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return slices.ContainsFunc(s.userRoles, func(ur userRole) bool {
+		return ur.userID == userID && ur.appID == appID && s.roleName(ur.roleID) == models.RoleAdmin
+	}), nil
+}
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) (int64, error) {
+	const op = "storage.memory.SaveSession"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.sessions, func(c *models.Session) bool { return bytes.Equal(c.TokenHash, session.TokenHash) }) {
+		return 0, fmt.Errorf("%s: %w", op, errTokenExists)
+	}
+
+	saved := &models.Session{
+		ID:        s.sessionIDs.next(),
+		UserID:    session.UserID,
+		AppID:     session.AppID,
+		Family:    session.Family,
+		TokenHash: bytes.Clone(session.TokenHash),
+		ExpiresAt: session.ExpiresAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+	}
+	s.sessions = append(s.sessions, saved)
+	return saved.ID, nil
+}
+
+func (s *Storage) Session(ctx context.Context, tokenHash []byte) (models.Session, error) {
+	const op = "storage.memory.Session"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, session := range s.sessions {
+		if bytes.Equal(session.TokenHash, tokenHash) {
+			return copySession(session), nil
+		}
+	}
+	return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+}
+
+func copySession(session *models.Session) models.Session {
+	c := *session
+	c.TokenHash = bytes.Clone(session.TokenHash)
+	return c
+}
+
+// RotateSession marks the session as used. It fails with storage.ErrSessionRotated
+// if the session has already been rotated or revoked, so two concurrent refreshes
+// with the same token cannot both succeed.
+func (s *Storage) RotateSession(ctx context.Context, id int64) error {
+	const op = "storage.memory.RotateSession"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.ID == id && !session.Rotated && !session.Revoked {
+			session.Rotated = true
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", op, storage.ErrSessionRotated)
+}
+
+func (s *Storage) RevokeSessionFamily(ctx context.Context, family string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, session := range s.sessions {
+		if session.Family == family {
+			session.Revoked = true
+		}
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of the user and returns the
+// families that were still active.
+func (s *Storage) RevokeUserSessions(ctx context.Context, userID int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.revokeSessions(func(session *models.Session) bool { return session.UserID == userID }), nil
+}
+
+// revokeSessions revokes the sessions matching the filter and returns the
+// families that were still active.
+func (s *Storage) revokeSessions(filter func(session *models.Session) bool) []string {
+	var families []string
+	for _, session := range s.sessions {
+		if !filter(session) {
+			continue
+		}
+		if !session.Revoked && !slices.Contains(families, session.Family) {
+			families = append(families, session.Family)
+		}
+		session.Revoked = true
+	}
+	return families
+}
+
+// SessionFamilyRevoked reports whether any session of the family has been revoked.
+func (s *Storage) SessionFamilyRevoked(ctx context.Context, family string) (bool, error) {
+	const op = "storage.memory.SessionFamilyRevoked"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	found, revoked := false, false
+	for _, session := range s.sessions {
+		if session.Family == family {
+			found = true
+			revoked = revoked || session.Revoked
+		}
+	}
+	if !found {
+		return false, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+	}
+	return revoked, nil
+}
+
+// SaveSigningKey saves the key as the active key of its app. It fails with
+// storage.ErrKeyExists if the id is taken or the app already has an active key.
+func (s *Storage) SaveSigningKey(ctx context.Context, key models.SigningKey) error {
+	const op = "storage.memory.SaveSigningKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.signingKey(key.ID) != nil || s.activeSigningKey(key.AppID) != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyExists)
+	}
+	s.addSigningKey(key)
+	return nil
+}
+
+func (s *Storage) addSigningKey(key models.SigningKey) {
+	s.signingKeys = append(s.signingKeys, &models.SigningKey{
+		ID:         key.ID,
+		AppID:      key.AppID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: bytes.Clone(key.PrivateKey),
+		Status:     models.KeyStatusActive,
+		CreatedAt:  time.Now().UTC(),
+	})
+}
+
+// RotateSigningKey makes key the active key of its app and records event in
+// the audit log. The previously active key keeps verifying tokens until
+// retiresAt, or is retired right away if retiresAt is not in the future.
+func (s *Storage) RotateSigningKey(ctx context.Context, key models.SigningKey, retiresAt time.Time, event models.AuditEvent) error {
+	const op = "storage.memory.RotateSigningKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.signingKey(key.ID) != nil {
+		return fmt.Errorf("%s: %w", op, storage.ErrKeyExists)
+	}
+
+	if active := s.activeSigningKey(key.AppID); active != nil {
+		active.Status = models.KeyStatusVerifying
+		if !retiresAt.After(time.Now()) {
+			active.Status = models.KeyStatusRetired
+		}
+		active.RetiresAt = retiresAt.UTC()
+	}
+	s.addSigningKey(key)
+	s.audit(event)
+	return nil
+}
+
+func (s *Storage) SigningKey(ctx context.Context, id string) (models.SigningKey, error) {
+	const op = "storage.memory.SigningKey"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := s.signingKey(id)
+	if key == nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+	}
+	return copySigningKey(key), nil
+}
+
+// AppSigningKey returns the active key of the app.
+func (s *Storage) AppSigningKey(ctx context.Context, appID int32) (models.SigningKey, error) {
+	const op = "storage.memory.AppSigningKey"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key := s.activeSigningKey(appID)
+	if key == nil {
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+	}
+	return copySigningKey(key), nil
+}
+
+// SigningKeys returns the active and verifying keys of the app, or of every
+// app if appID is 0.
+func (s *Storage) SigningKeys(ctx context.Context, appID int32) ([]models.SigningKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []models.SigningKey
+	for _, key := range s.signingKeys {
+		if (appID == 0 || key.AppID == appID) && key.Status != models.KeyStatusRetired {
+			keys = append(keys, copySigningKey(key))
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (s *Storage) signingKey(id string) *models.SigningKey {
+	for _, key := range s.signingKeys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+func (s *Storage) activeSigningKey(appID int32) *models.SigningKey {
+	for _, key := range s.signingKeys {
+		if key.AppID == appID && key.Status == models.KeyStatusActive {
+			return key
+		}
+	}
+	return nil
+}
+
+func copySigningKey(key *models.SigningKey) models.SigningKey {
+	c := *key
+	c.PrivateKey = bytes.Clone(key.PrivateKey)
+	return c
+}
+
+// CreateRole saves the role with its permissions and records the event in
+// the audit log.
+func (s *Storage) CreateRole(ctx context.Context, role models.Role, event models.AuditEvent) (int64, error) {
+	const op = "storage.memory.CreateRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.role(role.Name) != nil {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+	}
+
+	var permissions []string
+	if len(role.Permissions) > 0 {
+		permissions = slices.Compact(slices.Sorted(slices.Values(role.Permissions)))
+	}
+	created := &models.Role{ID: s.roleIDs.next(), Name: role.Name, Permissions: permissions}
+	s.roles = append(s.roles, created)
+	s.audit(event)
+	return created.ID, nil
+}
+
+// Role returns the role with the given name together with its permissions.
+func (s *Storage) Role(ctx context.Context, name string) (models.Role, error) {
+	const op = "storage.memory.Role"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	role := s.role(name)
+	if role == nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+	}
+	c := *role
+	c.Permissions = slices.Clone(role.Permissions)
+	return c, nil
+}
+
+func (s *Storage) role(name string) *models.Role {
+	for _, role := range s.roles {
+		if role.Name == name {
+			return role
+		}
+	}
+	return nil
+}
+
+// roleName returns the name of the role with the id, or "" if there is none.
+func (s *Storage) roleName(id int64) string {
+	for _, role := range s.roles {
+		if role.ID == id {
+			return role.Name
+		}
+	}
+	return ""
+}
+
+// AssignRole gives the user the role in the app. Assigning a role the user
+// already holds is a no-op and is not recorded in the audit log.
+func (s *Storage) AssignRole(ctx context.Context, userID int64, appID int32, roleID int64, event models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assignment := userRole{userID: userID, appID: appID, roleID: roleID}
+	if slices.Contains(s.userRoles, assignment) {
+		return nil
+	}
+	s.userRoles = append(s.userRoles, assignment)
+	s.audit(event)
+	return nil
+}
+
+// UnassignRole takes the role in the app away from the user. Unassigning a
+// role the user does not hold is a no-op and is not recorded in the audit log.
+// Taking away the last admin of the app fails with storage.ErrLastAdmin unless
+// the app of the service admins, adminAppID, still has one.
+func (s *Storage) UnassignRole(ctx context.Context, userID int64, appID int32, roleID int64, adminAppID int32, event models.AuditEvent) error {
+	const op = "storage.memory.UnassignRole"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	assignment := userRole{userID: userID, appID: appID, roleID: roleID}
+	i := slices.Index(s.userRoles, assignment)
+	if i < 0 {
+		return nil
+	}
+
+	lastAdmin := s.roleName(roleID) == models.RoleAdmin && !slices.ContainsFunc(s.userRoles, func(ur userRole) bool {
+		return ur.roleID == roleID && ur != assignment && (ur.appID == appID || ur.appID == adminAppID)
+	})
+	if lastAdmin {
+		return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+	}
+
+	s.userRoles = slices.Delete(s.userRoles, i, i+1)
+	s.audit(event)
+	return nil
+}
+
+// UserRoles returns the names of the roles the user holds in the app.
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int32) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var roles []string
+	for _, ur := range s.userRoles {
+		if ur.userID == userID && ur.appID == appID {
+			if name := s.roleName(ur.roleID); name != "" {
+				roles = append(roles, name)
+			}
+		}
+	}
+	slices.Sort(roles)
+	return roles, nil
+}
+
+// HasPermission reports whether any role the user holds in the app grants
+// the permission, either by name or through PermissionAll.
+func (s *Storage) HasPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, ur := range s.userRoles {
+		if ur.userID != userID || ur.appID != appID {
+			continue
+		}
+		for _, role := range s.roles {
+			if role.ID == ur.roleID &&
+				(slices.Contains(role.Permissions, permission) || slices.Contains(role.Permissions, models.PermissionAll)) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *Storage) audit(event models.AuditEvent) {
+	event.ID = s.auditIDs.next()
+	event.CreatedAt = time.Now().UTC()
+	s.auditLog = append(s.auditLog, event)
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"slices"
+	"time"
+)
+
+// SaveMFASecret starts or restarts the enrollment of the user with a new
+// secret. It fails with storage.ErrMFAConfirmed once the enrollment is confirmed.
+func (s *Storage) SaveMFASecret(ctx context.Context, userID int64, secret string) error {
+	const op = "storage.memory.SaveMFASecret"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfa, ok := s.mfa[userID]
+	if ok && mfa.Confirmed {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAConfirmed)
+	}
+	if !ok {
+		mfa = &models.MFA{UserID: userID}
+		s.mfa[userID] = mfa
+	}
+	mfa.Secret = secret
+	mfa.CreatedAt = time.Now().UTC()
+	return nil
+}
+
+func (s *Storage) MFA(ctx context.Context, userID int64) (models.MFA, error) {
+	const op = "storage.memory.MFA"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	mfa, ok := s.mfa[userID]
+	if !ok {
+		return models.MFA{}, fmt.Errorf("%s: %w", op, storage.ErrMFANotFound)
+	}
+	return *mfa, nil
+}
+
+// ConfirmMFA enables the enrollment of the user, marking step as used, and
+// replaces the recovery codes of the user with the given hashes.
+func (s *Storage) ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes [][]byte) error {
+	const op = "storage.memory.ConfirmMFA"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfa, ok := s.mfa[userID]
+	if !ok || mfa.Confirmed {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAConfirmed)
+	}
+	mfa.Confirmed = true
+	mfa.LastStep = step
+
+	s.recoveryCodes = slices.DeleteFunc(s.recoveryCodes, func(code *recoveryCode) bool { return code.userID == userID })
+	for _, hash := range recoveryCodeHashes {
+		s.recoveryCodes = append(s.recoveryCodes, &recoveryCode{userID: userID, hash: bytes.Clone(hash)})
+	}
+	return nil
+}
+
+// UseMFAStep records that a code of the given step has been accepted. It fails
+// with storage.ErrMFAStepUsed if a code of this or a later step was accepted before.
+func (s *Storage) UseMFAStep(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.memory.UseMFAStep"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mfa, ok := s.mfa[userID]
+	if !ok || mfa.LastStep >= step {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAStepUsed)
+	}
+	mfa.LastStep = step
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) error {
+	const op = "storage.memory.UseRecoveryCode"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, code := range s.recoveryCodes {
+		if code.userID == userID && !code.used && bytes.Equal(code.hash, codeHash) {
+			code.used = true
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+}
+
+// RecoveryCodesLeft returns the number of unused recovery codes of the user.
+func (s *Storage) RecoveryCodesLeft(ctx context.Context, userID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	left := 0
+	for _, code := range s.recoveryCodes {
+		if code.userID == userID && !code.used {
+			left++
+		}
+	}
+	return left, nil
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	const op = "storage.memory.SaveMFAChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.challenges, func(c *models.MFAChallenge) bool { return bytes.Equal(c.TokenHash, challenge.TokenHash) }) {
+		return fmt.Errorf("%s: %w", op, errTokenExists)
+	}
+
+	s.challenges = append(s.challenges, &models.MFAChallenge{
+		ID:        s.challengeIDs.next(),
+		TokenHash: bytes.Clone(challenge.TokenHash),
+		UserID:    challenge.UserID,
+		AppID:     challenge.AppID,
+		ExpiresAt: challenge.ExpiresAt.UTC(),
+	})
+	return nil
+}
+
+func (s *Storage) MFAChallenge(ctx context.Context, tokenHash []byte) (models.MFAChallenge, error) {
+	const op = "storage.memory.MFAChallenge"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, challenge := range s.challenges {
+		if bytes.Equal(challenge.TokenHash, tokenHash) {
+			c := *challenge
+			c.TokenHash = bytes.Clone(challenge.TokenHash)
+			return c, nil
+		}
+	}
+	return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrChallengeNotFound)
+}
+
+// FailMFAChallenge counts a wrong code entered for the challenge.
+func (s *Storage) FailMFAChallenge(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if challenge := s.challenge(id); challenge != nil {
+		challenge.Attempts++
+	}
+	return nil
+}
+
+// UseMFAChallenge marks the challenge as completed. It fails with
+// storage.ErrChallengeUsed if it has been completed before.
+func (s *Storage) UseMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.memory.UseMFAChallenge"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge := s.challenge(id)
+	if challenge == nil || challenge.Used {
+		return fmt.Errorf("%s: %w", op, storage.ErrChallengeUsed)
+	}
+	challenge.Used = true
+	return nil
+}
+
+func (s *Storage) challenge(id int64) *models.MFAChallenge {
+	for _, challenge := range s.challenges {
+		if challenge.ID == id {
+			return challenge
+		}
+	}
+	return nil
+}
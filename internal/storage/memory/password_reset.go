@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"slices"
+	"time"
+)
+
+func (s *Storage) SavePasswordReset(ctx context.Context, reset models.PasswordReset) error {
+	const op = "storage.memory.SavePasswordReset"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.resets, func(r *models.PasswordReset) bool { return bytes.Equal(r.TokenHash, reset.TokenHash) }) {
+		return fmt.Errorf("%s: %w", op, errTokenExists)
+	}
+
+	s.resets = append(s.resets, &models.PasswordReset{
+		ID:        s.resetIDs.next(),
+		UserID:    reset.UserID,
+		TokenHash: bytes.Clone(reset.TokenHash),
+		ExpiresAt: reset.ExpiresAt.UTC(),
+		CreatedAt: time.Now().UTC(),
+	})
+	return nil
+}
+
+func (s *Storage) PasswordReset(ctx context.Context, tokenHash []byte) (models.PasswordReset, error) {
+	const op = "storage.memory.PasswordReset"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, reset := range s.resets {
+		if bytes.Equal(reset.TokenHash, tokenHash) {
+			r := *reset
+			r.TokenHash = bytes.Clone(reset.TokenHash)
+			return r, nil
+		}
+	}
+	return models.PasswordReset{}, fmt.Errorf("%s: %w", op, storage.ErrResetNotFound)
+}
+
+// UsePasswordReset marks the reset as used, together with every other pending
+// reset of the user. It fails with storage.ErrResetUsed if the reset has been
+// used before.
+func (s *Storage) UsePasswordReset(ctx context.Context, reset models.PasswordReset) error {
+	const op = "storage.memory.UsePasswordReset"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.resets, func(r *models.PasswordReset) bool { return r.ID == reset.ID })
+	if i < 0 || s.resets[i].Used {
+		return fmt.Errorf("%s: %w", op, storage.ErrResetUsed)
+	}
+
+	for _, r := range s.resets {
+		if r.ID == reset.ID || r.UserID == reset.UserID {
+			r.Used = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"maps"
+	"slices"
+)
+
+// WithTx runs fn against a copy of the data and keeps the changes if fn
+// returns nil. Every other call waits until fn returns.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &Storage{data: s.data.clone()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// clone copies the data deep enough for the copy to be changed without
+// touching the original. Nested slices are only ever replaced, never
+// changed in place, so they are shared.
+func (d *data) clone() data {
+	c := *d
+	c.apps = cloneAll(d.apps)
+	c.users = cloneAll(d.users)
+	c.sessions = cloneAll(d.sessions)
+	c.signingKeys = cloneAll(d.signingKeys)
+	c.roles = cloneAll(d.roles)
+	c.userRoles = slices.Clone(d.userRoles)
+	c.mfa = maps.Clone(d.mfa)
+	for id, mfa := range c.mfa {
+		m := *mfa
+		c.mfa[id] = &m
+	}
+	c.recoveryCodes = cloneAll(d.recoveryCodes)
+	c.challenges = cloneAll(d.challenges)
+	c.resets = cloneAll(d.resets)
+	c.verifications = cloneAll(d.verifications)
+	c.loginFailures = maps.Clone(d.loginFailures)
+	for key, failures := range c.loginFailures {
+		f := *failures
+		c.loginFailures[key] = &f
+	}
+	c.auditLog = slices.Clone(d.auditLog)
+	return c
+}
+
+func cloneAll[T any](items []*T) []*T {
+	c := make([]*T, len(items))
+	for i, item := range items {
+		v := *item
+		c[i] = &v
+	}
+	return c
+}
@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+// ChangePassword sets the password hash of the user and revokes all of their
+// sessions except those of keepFamily. It returns the revoked session families.
+func (s *Storage) ChangePassword(ctx context.Context, userID int64, passwordHash []byte, keepFamily string) (families []string, err error) {
+	const op = "storage.postgres.ChangePassword"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET pass_hash = $1 WHERE id = $2", passwordHash, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	families, err = revokeUserSessions(ctx, tx, userID, keepFamily)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return families, nil
+}
+
+// UpdatePasswordHash replaces the password hash of the user with a new hash of
+// the same password, unless the password has been changed since oldHash was read.
+func (s *Storage) UpdatePasswordHash(ctx context.Context, userID int64, oldHash []byte, newHash []byte) error {
+	const op = "storage.postgres.UpdatePasswordHash"
+
+	_, err := s.conn().ExecContext(ctx, "UPDATE users SET pass_hash = $1 WHERE id = $2 AND pass_hash = $3", newHash, userID, oldHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ChangeEmail sets the email of the user, marks it as unverified and drops
+// the pending verifications of the previous email. It fails with
+// storage.ErrUserExists if the app already has a user with the normalized email.
+func (s *Storage) ChangeEmail(ctx context.Context, userID int64, email string, normalizedEmail string) (err error) {
+	const op = "storage.postgres.ChangeEmail"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET email = $1, email_normalized = $2, email_verified = FALSE WHERE id = $3",
+		email, normalizedEmail, userID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrUserExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE email_verifications SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL",
+		time.Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// MarkUserDeleted marks the user as deleted, so that they can no longer sign
+// in. It fails with storage.ErrUserNotFound if the user is already deleted.
+func (s *Storage) MarkUserDeleted(ctx context.Context, userID int64) error {
+	const op = "storage.postgres.MarkUserDeleted"
+
+	res, err := s.conn().ExecContext(ctx, "UPDATE users SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL", time.Now().UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+	}
+	return nil
+}
+
+// UnassignUserRoles takes every role away from the user. It fails with
+// storage.ErrLastAdmin if the user is the only admin of an app and the app of
+// the service admins, adminAppID, has no other admin.
+func (s *Storage) UnassignUserRoles(ctx context.Context, userID int64, adminAppID int32) (err error) {
+	const op = "storage.postgres.UnassignUserRoles"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var lastAdmin bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = $1 AND r.name = $2
+			AND NOT EXISTS (SELECT 1 FROM user_roles o WHERE o.role_id = ur.role_id
+				AND o.user_id != ur.user_id AND o.app_id IN (ur.app_id, $3)))`,
+		userID, models.RoleAdmin, adminAppID).Scan(&lastAdmin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if lastAdmin {
+		return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// PurgeDeletedUsers removes the users deleted before the given time together
+// with everything stored about them. Audit entries are kept without their actor.
+func (s *Storage) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (purged int64, err error) {
+	const op = "storage.postgres.PurgeDeletedUsers"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	const deleted = "SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1"
+	for _, table := range []string{
+		"sessions", "user_roles", "mfa", "recovery_codes", "mfa_challenges", "password_resets", "email_verifications",
+	} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id IN ("+deleted+")", deletedBefore.UTC()); err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE audit_log SET user_id = NULL WHERE user_id IN ("+deleted+")", deletedBefore.UTC()); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	// The failed login counters of the accounts are keyed by email, see models.AccountLoginKey.
+	_, err = tx.ExecContext(ctx, `DELETE FROM login_failures WHERE scope = $1 AND key IN
+			(SELECT app_id::TEXT || ':' || email_normalized FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $2)`,
+		models.LoginScopeAccount, deletedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < $1", deletedBefore.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	purged, err = res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return purged, nil
+}
+
+// UserRoleAssignments returns the roles the user holds in every app.
+func (s *Storage) UserRoleAssignments(ctx context.Context, userID int64) ([]models.RoleAssignment, error) {
+	const op = "storage.postgres.UserRoleAssignments"
+
+	rows, err := s.conn().QueryContext(ctx, `SELECT ur.app_id, r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1 ORDER BY ur.app_id, r.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var assignments []models.RoleAssignment
+	for rows.Next() {
+		var assignment models.RoleAssignment
+		if err := rows.Scan(&assignment.AppID, &assignment.Role); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		assignments = append(assignments, assignment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return assignments, nil
+}
+
+// UserSessions returns every stored session of the user, oldest first.
+func (s *Storage) UserSessions(ctx context.Context, userID int64) ([]models.Session, error) {
+	const op = "storage.postgres.UserSessions"
+
+	rows, err := s.conn().QueryContext(ctx, `SELECT id, user_id, app_id, family, token_hash, expires_at, created_at, rotated, revoked
+		FROM sessions WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		var session models.Session
+		err := rows.Scan(
+			&session.ID, &session.UserID, &session.AppID, &session.Family, &session.TokenHash,
+			&session.ExpiresAt, &session.CreatedAt, &session.Rotated, &session.Revoked,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return sessions, nil
+}
+
+// UserAuditEvents returns the audit entries of the actions the user performed, oldest first.
+func (s *Storage) UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error) {
+	const op = "storage.postgres.UserAuditEvents"
+
+	rows, err := s.conn().QueryContext(ctx, `SELECT id, user_id, COALESCE(app_id, 0), action, details, created_at
+		FROM audit_log WHERE user_id = $1 ORDER BY id`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var events []models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		err := rows.Scan(&event.ID, &event.UserID, &event.AppID, &event.Action, &event.Details, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return events, nil
+}
@@ -0,0 +1,365 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/blockloop/scan/v2"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+// appColumns are the columns scanApp reads, the metadata of the app included
+// as JSON arrays.
+const appColumns = `id, name, secret, require_verified_email, password_min_length, password_max_length,
+	password_min_classes, password_forbid_email, password_check_breached, disabled_at IS NOT NULL,
+	(SELECT COALESCE(json_agg(audience ORDER BY audience), '[]') FROM app_audiences WHERE app_id = apps.id),
+	(SELECT COALESCE(json_agg(uri ORDER BY uri), '[]') FROM app_redirect_uris WHERE app_id = apps.id)`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanApp(row rowScanner) (models.App, error) {
+	var app models.App
+	var audiences, redirectURIs string
+	err := row.Scan(&app.ID, &app.Name, &app.Secret, &app.RequireVerifiedEmail,
+		&app.PasswordPolicy.MinLength, &app.PasswordPolicy.MaxLength, &app.PasswordPolicy.MinClasses,
+		&app.PasswordPolicy.ForbidEmail, &app.PasswordPolicy.CheckBreached, &app.Disabled,
+		&audiences, &redirectURIs)
+	if err != nil {
+		return models.App{}, err
+	}
+
+	if err := json.Unmarshal([]byte(audiences), &app.Audiences); err != nil {
+		return models.App{}, err
+	}
+	if err := json.Unmarshal([]byte(redirectURIs), &app.RedirectURIs); err != nil {
+		return models.App{}, err
+	}
+	return app, nil
+}
+
+// Apps returns every app ordered by id.
+func (s *Storage) Apps(ctx context.Context) ([]models.App, error) {
+	const op = "storage.postgres.Apps"
+
+	rows, err := s.conn().QueryContext(ctx, "SELECT "+appColumns+" FROM apps ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var apps []models.App
+	for rows.Next() {
+		app, err := scanApp(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		apps = append(apps, app)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return apps, nil
+}
+
+// CreateApp saves the app with its metadata and records the event in the
+// audit log. It fails with storage.ErrAppExists if the name is taken.
+func (s *Storage) CreateApp(ctx context.Context, app models.App, event models.AuditEvent) (id int64, err error) {
+	const op = "storage.postgres.CreateApp"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	err = tx.QueryRowContext(ctx, `INSERT INTO apps (name, secret, require_verified_email, password_min_length,
+		password_max_length, password_min_classes, password_forbid_email, password_check_breached)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		app.Name, app.Secret, app.RequireVerifiedEmail, app.PasswordPolicy.MinLength, app.PasswordPolicy.MaxLength,
+		app.PasswordPolicy.MinClasses, app.PasswordPolicy.ForbidEmail, app.PasswordPolicy.CheckBreached).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = saveAppMetadata(ctx, tx, id, app); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	event.AppID = int32(id)
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// UpdateApp replaces the name, the settings and the metadata of the app and
+// records the event in the audit log. It fails with storage.ErrAppExists if
+// another app has the name.
+func (s *Storage) UpdateApp(ctx context.Context, app models.App, event models.AuditEvent) (err error) {
+	const op = "storage.postgres.UpdateApp"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, `UPDATE apps SET name = $1, require_verified_email = $2, password_min_length = $3,
+		password_max_length = $4, password_min_classes = $5, password_forbid_email = $6, password_check_breached = $7
+		WHERE id = $8`,
+		app.Name, app.RequireVerifiedEmail, app.PasswordPolicy.MinLength, app.PasswordPolicy.MaxLength,
+		app.PasswordPolicy.MinClasses, app.PasswordPolicy.ForbidEmail, app.PasswordPolicy.CheckBreached, app.ID)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrAppExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = requireAffected(res, storage.ErrAppNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = deleteAppMetadata(ctx, tx, app.ID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = saveAppMetadata(ctx, tx, app.ID, app); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UpdateAppSecret replaces the secret of the app and records the event in the audit log.
+func (s *Storage) UpdateAppSecret(ctx context.Context, appID int32, secret string, event models.AuditEvent) (err error) {
+	const op = "storage.postgres.UpdateAppSecret"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE apps SET secret = $1 WHERE id = $2", secret, appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = requireAffected(res, storage.ErrAppNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SetAppDisabled disables or enables the app. Disabling revokes every session
+// of the app and returns the families that were still active. Only an actual
+// change is recorded in the audit log.
+func (s *Storage) SetAppDisabled(
+	ctx context.Context,
+	appID int32,
+	disabled bool,
+	event models.AuditEvent,
+) (families []string, err error) {
+	const op = "storage.postgres.SetAppDisabled"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var exists bool
+	if err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM apps WHERE id = $1)", appID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+	}
+
+	var res sql.Result
+	if disabled {
+		res, err = tx.ExecContext(ctx, "UPDATE apps SET disabled_at = $1 WHERE id = $2 AND disabled_at IS NULL",
+			time.Now().UTC(), appID)
+	} else {
+		res, err = tx.ExecContext(ctx, "UPDATE apps SET disabled_at = NULL WHERE id = $1 AND disabled_at IS NOT NULL", appID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if err = auditIfAffected(ctx, tx, res, event); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if disabled {
+		families, err = revokeAppSessions(ctx, tx, appID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return families, nil
+}
+
+// DeleteApp deletes the app together with its metadata, signing keys and
+// role assignments and records the event in the audit log. It fails with
+// storage.ErrAppInUse if the app has users, deleted ones awaiting the purge
+// included, and with storage.ErrLastAdmin if it holds the only admin assignments.
+func (s *Storage) DeleteApp(ctx context.Context, appID int32, event models.AuditEvent) (err error) {
+	const op = "storage.postgres.DeleteApp"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	var inUse bool
+	if err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE app_id = $1)", appID).Scan(&inUse); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if inUse {
+		return fmt.Errorf("%s: %w", op, storage.ErrAppInUse)
+	}
+
+	var lastAdmin bool
+	err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.app_id = $1 AND r.name = $2)
+		AND NOT EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.app_id != $3 AND r.name = $4)`,
+		appID, models.RoleAdmin, appID, models.RoleAdmin).Scan(&lastAdmin)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if lastAdmin {
+		return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+	}
+
+	// The rows referencing the app go first, the metadata cascades.
+	for _, table := range []string{"signing_keys", "user_roles", "sessions", "mfa_challenges"} {
+		if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE app_id = $1", appID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM apps WHERE id = $1", appID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = requireAffected(res, storage.ErrAppNotFound); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func saveAppMetadata(ctx context.Context, tx *txn, appID int64, app models.App) error {
+	for _, audience := range app.Audiences {
+		_, err := tx.ExecContext(ctx, "INSERT INTO app_audiences (app_id, audience) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			appID, audience)
+		if err != nil {
+			return err
+		}
+	}
+	for _, uri := range app.RedirectURIs {
+		_, err := tx.ExecContext(ctx, "INSERT INTO app_redirect_uris (app_id, uri) VALUES ($1, $2) ON CONFLICT DO NOTHING",
+			appID, uri)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteAppMetadata(ctx context.Context, tx *txn, appID int64) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM app_audiences WHERE app_id = $1", appID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM app_redirect_uris WHERE app_id = $1", appID)
+	return err
+}
+
+// revokeAppSessions revokes every session of the app and returns the
+// families that were still active.
+func revokeAppSessions(ctx context.Context, tx *txn, appID int32) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT family FROM sessions WHERE app_id = $1 AND revoked = FALSE", appID)
+	if err != nil {
+		return nil, err
+	}
+
+	var families []string
+	if err := scan.Rows(&families, rows); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked = TRUE WHERE app_id = $1", appID); err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+// requireAffected returns notFound if the statement changed no row.
+func requireAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
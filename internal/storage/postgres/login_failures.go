@@ -0,0 +1,113 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"time"
+)
+
+// LoginFailures returns the failed login counter of the key in the scope. A
+// key without recent failures yields a zero counter.
+func (s *Storage) LoginFailures(ctx context.Context, scope string, key string) (models.LoginFailures, error) {
+	const op = "storage.postgres.LoginFailures"
+
+	failures := models.LoginFailures{Scope: scope, Key: key}
+	var blockedUntil sql.NullTime
+	err := s.conn().QueryRowContext(ctx, "SELECT failures, blocked_until, last_failure_at FROM login_failures WHERE scope = $1 AND key = $2",
+		scope, key).Scan(&failures.Failures, &blockedUntil, &failures.LastFailureAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return failures, nil
+		}
+		return models.LoginFailures{}, fmt.Errorf("%s: %w", op, err)
+	}
+	failures.BlockedUntil = blockedUntil.Time
+
+	return failures, nil
+}
+
+// AddLoginFailure counts a failed login of the key in the scope and returns
+// the number of failures so far. Failures older than since are forgotten.
+// The service counts every attempt in advance and takes back the successful ones.
+func (s *Storage) AddLoginFailure(ctx context.Context, scope string, key string, since time.Time) (failures int, err error) {
+	const op = "storage.postgres.AddLoginFailure"
+
+	err = s.conn().QueryRowContext(ctx, `INSERT INTO login_failures (scope, key, failures, last_failure_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failure_at < $4 THEN 1 ELSE login_failures.failures + 1 END,
+			blocked_until = CASE WHEN login_failures.last_failure_at < $4 THEN NULL ELSE login_failures.blocked_until END,
+			last_failure_at = excluded.last_failure_at
+		RETURNING failures`, scope, key, time.Now().UTC(), since.UTC()).Scan(&failures)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return failures, nil
+}
+
+// BlockLogin rejects the logins of the key in the scope until the given time.
+// It never shortens a longer block, which a concurrent failure may have set.
+func (s *Storage) BlockLogin(ctx context.Context, scope string, key string, until time.Time) error {
+	const op = "storage.postgres.BlockLogin"
+
+	_, err := s.conn().ExecContext(ctx, `UPDATE login_failures SET blocked_until = $1
+		WHERE scope = $2 AND key = $3 AND (blocked_until IS NULL OR blocked_until < $1)`, until.UTC(), scope, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RemoveLoginFailure takes back a failure of the key in the scope, counted in
+// advance for an attempt that has succeeded.
+func (s *Storage) RemoveLoginFailure(ctx context.Context, scope string, key string) error {
+	const op = "storage.postgres.RemoveLoginFailure"
+
+	_, err := s.conn().ExecContext(ctx, "UPDATE login_failures SET failures = failures - 1 WHERE scope = $1 AND key = $2 AND failures > 0",
+		scope, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// ResetLoginFailures forgets the failed logins of the key in the scope.
+func (s *Storage) ResetLoginFailures(ctx context.Context, scope string, key string) error {
+	const op = "storage.postgres.ResetLoginFailures"
+
+	if _, err := s.conn().ExecContext(ctx, "DELETE FROM login_failures WHERE scope = $1 AND key = $2", scope, key); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UnlockLogin forgets the failed logins of the key in the scope and records
+// the event if there were any.
+func (s *Storage) UnlockLogin(ctx context.Context, scope string, key string, event models.AuditEvent) (err error) {
+	const op = "storage.postgres.UnlockLogin"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM login_failures WHERE scope = $1 AND key = $2", scope, key)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err = auditIfAffected(ctx, tx, res, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
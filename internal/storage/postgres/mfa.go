@@ -0,0 +1,198 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+// SaveMFASecret starts or restarts the enrollment of the user with a new
+// secret. It fails with storage.ErrMFAConfirmed once the enrollment is confirmed.
+func (s *Storage) SaveMFASecret(ctx context.Context, userID int64, secret string) error {
+	const op = "storage.postgres.SaveMFASecret"
+
+	res, err := s.conn().ExecContext(ctx, `INSERT INTO mfa (user_id, secret, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at
+		WHERE mfa.confirmed = FALSE`, userID, secret, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAConfirmed)
+	}
+	return nil
+}
+
+func (s *Storage) MFA(ctx context.Context, userID int64) (models.MFA, error) {
+	const op = "storage.postgres.MFA"
+
+	var mfa models.MFA
+	err := s.conn().QueryRowContext(ctx, "SELECT user_id, secret, confirmed, last_step, created_at FROM mfa WHERE user_id = $1", userID).
+		Scan(&mfa.UserID, &mfa.Secret, &mfa.Confirmed, &mfa.LastStep, &mfa.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFA{}, fmt.Errorf("%s: %w", op, storage.ErrMFANotFound)
+		}
+		return models.MFA{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return mfa, nil
+}
+
+// ConfirmMFA enables the enrollment of the user, marking step as used, and
+// replaces the recovery codes of the user with the given hashes.
+func (s *Storage) ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes [][]byte) (err error) {
+	const op = "storage.postgres.ConfirmMFA"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE mfa SET confirmed = TRUE, last_step = $1 WHERE user_id = $2 AND confirmed = FALSE",
+		step, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAConfirmed)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseMFAStep records that a code of the given step has been accepted. It fails
+// with storage.ErrMFAStepUsed if a code of this or a later step was accepted before.
+func (s *Storage) UseMFAStep(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.postgres.UseMFAStep"
+
+	res, err := s.conn().ExecContext(ctx, "UPDATE mfa SET last_step = $1 WHERE user_id = $2 AND last_step < $3", step, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAStepUsed)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) error {
+	const op = "storage.postgres.UseRecoveryCode"
+
+	res, err := s.conn().ExecContext(ctx, "UPDATE recovery_codes SET used_at = $1 WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL",
+		time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	const op = "storage.postgres.SaveMFAChallenge"
+
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO mfa_challenges (token_hash, user_id, app_id, expires_at) VALUES ($1, $2, $3, $4)",
+		challenge.TokenHash, challenge.UserID, challenge.AppID, challenge.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) MFAChallenge(ctx context.Context, tokenHash []byte) (models.MFAChallenge, error) {
+	const op = "storage.postgres.MFAChallenge"
+
+	var challenge models.MFAChallenge
+	err := s.conn().QueryRowContext(ctx, "SELECT id, token_hash, user_id, app_id, attempts, expires_at, used FROM mfa_challenges WHERE token_hash = $1",
+		tokenHash).Scan(
+		&challenge.ID, &challenge.TokenHash, &challenge.UserID, &challenge.AppID,
+		&challenge.Attempts, &challenge.ExpiresAt, &challenge.Used,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrChallengeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return challenge, nil
+}
+
+// FailMFAChallenge counts a wrong code entered for the challenge.
+func (s *Storage) FailMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.postgres.FailMFAChallenge"
+
+	if _, err := s.conn().ExecContext(ctx, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1", id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseMFAChallenge marks the challenge as completed. It fails with
+// storage.ErrChallengeUsed if it has been completed before.
+func (s *Storage) UseMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.postgres.UseMFAChallenge"
+
+	res, err := s.conn().ExecContext(ctx, "UPDATE mfa_challenges SET used = TRUE WHERE id = $1 AND used = FALSE", id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrChallengeUsed)
+	}
+	return nil
+}
+
+// RecoveryCodesLeft returns the number of unused recovery codes of the user.
+func (s *Storage) RecoveryCodesLeft(ctx context.Context, userID int64) (int, error) {
+	const op = "storage.postgres.RecoveryCodesLeft"
+
+	var left int
+	err := s.conn().QueryRowContext(ctx, "SELECT COUNT(*) FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL", userID).Scan(&left)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return left, nil
+}
@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+func (s *Storage) SavePasswordReset(ctx context.Context, reset models.PasswordReset) error {
+	const op = "storage.postgres.SavePasswordReset"
+
+	_, err := s.conn().ExecContext(ctx, "INSERT INTO password_resets (token_hash, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4)",
+		reset.TokenHash, reset.UserID, reset.ExpiresAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) PasswordReset(ctx context.Context, tokenHash []byte) (models.PasswordReset, error) {
+	const op = "storage.postgres.PasswordReset"
+
+	var reset models.PasswordReset
+	err := s.conn().QueryRowContext(ctx, `SELECT id, user_id, token_hash, expires_at, created_at, used_at IS NOT NULL
+		FROM password_resets WHERE token_hash = $1`, tokenHash).Scan(
+		&reset.ID, &reset.UserID, &reset.TokenHash, &reset.ExpiresAt, &reset.CreatedAt, &reset.Used,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.PasswordReset{}, fmt.Errorf("%s: %w", op, storage.ErrResetNotFound)
+		}
+		return models.PasswordReset{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return reset, nil
+}
+
+// UsePasswordReset marks the reset as used, together with every other pending
+// reset of the user. It fails with storage.ErrResetUsed if the reset has been
+// used before.
+func (s *Storage) UsePasswordReset(ctx context.Context, reset models.PasswordReset) (err error) {
+	const op = "storage.postgres.UsePasswordReset"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE password_resets SET used_at = $1 WHERE id = $2 AND used_at IS NULL",
+		time.Now().UTC(), reset.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrResetUsed)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE password_resets SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL",
+		time.Now().UTC(), reset.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+)
+
+// WithTx runs fn in a transaction and commits it if fn returns nil. Inside
+// another WithTx, fn joins the enclosing transaction.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) (err error) {
+	const op = "storage.postgres.WithTx"
+
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = fn(&Storage{db: s.db, tx: tx}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// querier runs the statements of an operation of the storage.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn returns the transaction of WithTx, if any, or the database.
+func (s *Storage) conn() querier {
+	if s.tx != nil {
+		return s.tx
+	}
+	return s.db
+}
+
+// txn is the transaction of a single operation of the storage. Inside WithTx
+// it is a savepoint of the enclosing transaction, so that a failed operation
+// is still undone as a whole.
+type txn struct {
+	*sql.Tx
+	savepoint bool
+}
+
+// begin starts the transaction of an operation of the storage.
+func (s *Storage) begin(ctx context.Context) (*txn, error) {
+	if s.tx == nil {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &txn{Tx: tx}, nil
+	}
+
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT operation"); err != nil {
+		return nil, err
+	}
+	return &txn{Tx: s.tx, savepoint: true}, nil
+}
+
+func (t *txn) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	_, err := t.Exec("RELEASE SAVEPOINT operation")
+	return err
+}
+
+func (t *txn) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if _, err := t.Exec("ROLLBACK TO SAVEPOINT operation"); err != nil {
+		return err
+	}
+	_, err := t.Exec("RELEASE SAVEPOINT operation")
+	return err
+}
@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+func (s *Storage) SaveEmailVerification(ctx context.Context, verification models.EmailVerification) error {
+	const op = "storage.sqlite.SaveEmailVerification"
+
+	_, err := s.stmt(ctx, s.stmts.saveEmailVerification).ExecContext(ctx, verification.TokenHash, verification.UserID, verification.Email,
+		verification.ExpiresAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) EmailVerification(ctx context.Context, tokenHash []byte) (models.EmailVerification, error) {
+	const op = "storage.sqlite.EmailVerification"
+
+	var verification models.EmailVerification
+	err := s.stmt(ctx, s.stmts.emailVerification).QueryRowContext(ctx, tokenHash).Scan(
+		&verification.ID, &verification.UserID, &verification.Email, &verification.TokenHash,
+		&verification.ExpiresAt, &verification.CreatedAt, &verification.Used,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.EmailVerification{}, fmt.Errorf("%s: %w", op, storage.ErrVerificationNotFound)
+		}
+		return models.EmailVerification{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return verification, nil
+}
+
+// VerifyEmail uses the verification, together with every other pending
+// verification of the user, and marks the email of the user as verified.
+// It fails with storage.ErrVerificationUsed if the verification has been
+// used before and with storage.ErrVerificationNotFound if the user no longer
+// has the verified email.
+func (s *Storage) VerifyEmail(ctx context.Context, verification models.EmailVerification) (err error) {
+	const op = "storage.sqlite.VerifyEmail"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE email_verifications SET used_at = ? WHERE id = ? AND used_at IS NULL",
+		time.Now().UTC(), verification.ID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationUsed)
+	}
+
+	res, err = tx.ExecContext(ctx, "UPDATE users SET email_verified = TRUE WHERE id = ? AND email = ?",
+		verification.UserID, verification.Email)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err = res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrVerificationNotFound)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE email_verifications SET used_at = ? WHERE user_id = ? AND used_at IS NULL",
+		time.Now().UTC(), verification.UserID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
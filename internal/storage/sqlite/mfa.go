@@ -0,0 +1,192 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+// SaveMFASecret starts or restarts the enrollment of the user with a new
+// secret. It fails with storage.ErrMFAConfirmed once the enrollment is confirmed.
+func (s *Storage) SaveMFASecret(ctx context.Context, userID int64, secret string) error {
+	const op = "storage.sqlite.SaveMFASecret"
+
+	res, err := s.stmt(ctx, s.stmts.saveMFASecret).ExecContext(ctx, userID, secret, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAConfirmed)
+	}
+	return nil
+}
+
+func (s *Storage) MFA(ctx context.Context, userID int64) (models.MFA, error) {
+	const op = "storage.sqlite.MFA"
+
+	var mfa models.MFA
+	err := s.stmt(ctx, s.stmts.mfa).QueryRowContext(ctx, userID).Scan(&mfa.UserID, &mfa.Secret, &mfa.Confirmed, &mfa.LastStep, &mfa.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFA{}, fmt.Errorf("%s: %w", op, storage.ErrMFANotFound)
+		}
+		return models.MFA{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return mfa, nil
+}
+
+// ConfirmMFA enables the enrollment of the user, marking step as used, and
+// replaces the recovery codes of the user with the given hashes.
+func (s *Storage) ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes [][]byte) (err error) {
+	const op = "storage.sqlite.ConfirmMFA"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "UPDATE mfa SET confirmed = TRUE, last_step = ? WHERE user_id = ? AND confirmed = FALSE",
+		step, userID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAConfirmed)
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err = tx.ExecContext(ctx, "INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseMFAStep records that a code of the given step has been accepted. It fails
+// with storage.ErrMFAStepUsed if a code of this or a later step was accepted before.
+func (s *Storage) UseMFAStep(ctx context.Context, userID int64, step int64) error {
+	const op = "storage.sqlite.UseMFAStep"
+
+	res, err := s.stmt(ctx, s.stmts.useMFAStep).ExecContext(ctx, step, userID, step)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrMFAStepUsed)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the unused recovery code with the given hash as used.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) error {
+	const op = "storage.sqlite.UseRecoveryCode"
+
+	res, err := s.stmt(ctx, s.stmts.useRecoveryCode).ExecContext(ctx, time.Now().UTC(), userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrRecoveryCodeNotFound)
+	}
+	return nil
+}
+
+func (s *Storage) SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error {
+	const op = "storage.sqlite.SaveMFAChallenge"
+
+	_, err := s.stmt(ctx, s.stmts.saveMFAChallenge).ExecContext(ctx, challenge.TokenHash, challenge.UserID, challenge.AppID, challenge.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) MFAChallenge(ctx context.Context, tokenHash []byte) (models.MFAChallenge, error) {
+	const op = "storage.sqlite.MFAChallenge"
+
+	var challenge models.MFAChallenge
+	err := s.stmt(ctx, s.stmts.mfaChallenge).QueryRowContext(ctx, tokenHash).Scan(
+		&challenge.ID, &challenge.TokenHash, &challenge.UserID, &challenge.AppID,
+		&challenge.Attempts, &challenge.ExpiresAt, &challenge.Used,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, storage.ErrChallengeNotFound)
+		}
+		return models.MFAChallenge{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return challenge, nil
+}
+
+// FailMFAChallenge counts a wrong code entered for the challenge.
+func (s *Storage) FailMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.FailMFAChallenge"
+
+	if _, err := s.stmt(ctx, s.stmts.failMFAChallenge).ExecContext(ctx, id); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UseMFAChallenge marks the challenge as completed. It fails with
+// storage.ErrChallengeUsed if it has been completed before.
+func (s *Storage) UseMFAChallenge(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.UseMFAChallenge"
+
+	res, err := s.stmt(ctx, s.stmts.useChallenge).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrChallengeUsed)
+	}
+	return nil
+}
+
+// RecoveryCodesLeft returns the number of unused recovery codes of the user.
+func (s *Storage) RecoveryCodesLeft(ctx context.Context, userID int64) (int, error) {
+	const op = "storage.sqlite.RecoveryCodesLeft"
+
+	var left int
+	err := s.stmt(ctx, s.stmts.recoveryCodesLeft).QueryRowContext(ctx, userID).Scan(&left)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return left, nil
+}
@@ -10,32 +10,107 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
 	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Storage struct {
-	db *sql.DB
+	db    *sql.DB
+	stmts *statements
+	// tx is the transaction of WithTx every operation of the storage runs in,
+	// if any.
+	tx *sql.Tx
 }
 
-func New(path string) (*Storage, error) {
+// Options tune the connections to the database. The zero value keeps the
+// defaults of SQLite and database/sql, except that foreign keys are enforced.
+type Options struct {
+	// JournalMode is the journal_mode pragma, e.g. WAL or DELETE.
+	JournalMode string
+	// BusyTimeout is how long a statement waits for the lock of another
+	// connection before failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// NoForeignKeys leaves the foreign keys of the schema unenforced, as
+	// SQLite does by default.
+	NoForeignKeys bool
+	// Synchronous is the synchronous pragma, e.g. NORMAL or FULL.
+	Synchronous string
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// New opens the database at path and prepares the statements of the storage,
+// so the database must already be migrated.
+func New(path string, opts Options) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", path)
+	db, err := sql.Open("sqlite3", dsn(path, opts))
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	return &Storage{db: db}, nil
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	db.SetMaxIdleConns(opts.MaxIdleConns)
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+
+	s := &Storage{db: db, stmts: &statements{}}
+	if err := s.stmts.prepare(context.Background(), db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return s, nil
 }
 
-func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byte, appId int32) (int64, error) {
-	const op = "storage.sqlite.SaveUser"
+// dsn adds the pragmas of opts to path. They are set by the driver on every
+// new connection, as most of them only apply to the connection they are run on.
+//
+// Transactions take the write lock as they begin: a transaction upgrading its
+// read lock fails at once if another connection wrote in between, no matter
+// the busy timeout.
+func dsn(path string, opts Options) string {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	if opts.JournalMode != "" {
+		params.Set("_journal_mode", opts.JournalMode)
+	}
+	if opts.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(opts.BusyTimeout.Milliseconds(), 10))
+	}
+	if opts.NoForeignKeys {
+		params.Set("_foreign_keys", "off")
+	} else {
+		params.Set("_foreign_keys", "on")
+	}
+	if opts.Synchronous != "" {
+		params.Set("_synchronous", opts.Synchronous)
+	}
 
-	req, err := s.db.Prepare("INSERT INTO users (email, pass_hash, app_id) VALUES (?, ?, ?)")
-	if err != nil {
-		return 0, fmt.Errorf("%s: %w", op, err)
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
 	}
+	return path + separator + params.Encode()
+}
 
-	res, err := req.ExecContext(ctx, email, passwordHash, appId)
+// Close closes the prepared statements and the database.
+func (s *Storage) Close() error {
+	const op = "storage.sqlite.Close"
+
+	if err := errors.Join(s.stmts.close(), s.db.Close()); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// SaveUser saves a new user of the app. It fails with storage.ErrUserExists
+// if the app already has a user with the normalized email.
+func (s *Storage) SaveUser(ctx context.Context, email string, normalizedEmail string, passwordHash []byte, appId int32) (int64, error) {
+	const op = "storage.sqlite.SaveUser"
+
+	res, err := s.stmt(ctx, s.stmts.saveUser).ExecContext(ctx, email, normalizedEmail, passwordHash, appId)
 	if err != nil {
 		var sqliteErr sqlite3.Error
 		if errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) {
@@ -50,18 +125,31 @@ func (s *Storage) SaveUser(ctx context.Context, email string, passwordHash []byt
 	return id, nil
 }
 
-func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
+// User returns the account registered with the normalized email in the app.
+func (s *Storage) User(ctx context.Context, appID int32, normalizedEmail string) (models.User, error) {
 	const op = "storage.sqlite.User"
 
-	req, err := s.db.Prepare("SELECT id, email, pass_hash, app_id FROM users WHERE email = ?")
+	row := s.stmt(ctx, s.stmts.user).QueryRowContext(ctx, appID, normalizedEmail)
+
+	var user models.User
+	err := row.Scan(&user.ID, &user.Email, &user.NormalizedEmail, &user.PasswordHash, &user.AppID, &user.EmailVerified)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
+		}
 		return models.User{}, fmt.Errorf("%s: %w", op, err)
 	}
 
-	row := req.QueryRowContext(ctx, strings.TrimSpace(email))
+	return user, nil
+}
+
+func (s *Storage) UserByID(ctx context.Context, id int64) (models.User, error) {
+	const op = "storage.sqlite.UserByID"
+
+	row := s.stmt(ctx, s.stmts.userByID).QueryRowContext(ctx, id)
 
 	var user models.User
-	err = row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.AppID)
+	err := row.Scan(&user.ID, &user.Email, &user.NormalizedEmail, &user.PasswordHash, &user.AppID, &user.EmailVerified)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return models.User{}, fmt.Errorf("%s: %w", op, storage.ErrUserNotFound)
@@ -72,15 +160,11 @@ func (s *Storage) User(ctx context.Context, email string) (models.User, error) {
 	return user, nil
 }
 
-func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
+// IsAdmin reports whether the user holds the built-in admin role in the app.
+func (s *Storage) IsAdmin(ctx context.Context, userID int64, appID int32) (bool, error) {
 	const op = "storage.sqlite.IsAdmin"
 
-	req, err := s.db.Prepare("SELECT is_admin FROM users WHERE id = ?")
-	if err != nil {
-		return false, fmt.Errorf("%s: %w", op, err)
-	}
-
-	row, err := req.QueryContext(ctx, userID)
+	row, err := s.stmt(ctx, s.stmts.isAdmin).QueryContext(ctx, appID, models.RoleAdmin, userID)
 	if err != nil {
 		return false, fmt.Errorf("%s: %w", op, err)
 	}
@@ -99,21 +183,466 @@ func (s *Storage) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 func (s *Storage) App(ctx context.Context, id int32) (models.App, error) {
 	const op = "storage.sqlite.App"
 
-	req, err := s.db.Prepare("SELECT id, name, secret FROM apps WHERE id = ?")
+	app, err := scanApp(s.stmt(ctx, s.stmts.app).QueryRowContext(ctx, id))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+		}
 		return models.App{}, fmt.Errorf("%s: %w", op, err)
 	}
+	return app, nil
+
+}
+
+func (s *Storage) SaveSession(ctx context.Context, session models.Session) (int64, error) {
+	const op = "storage.sqlite.SaveSession"
+
+	res, err := s.stmt(ctx, s.stmts.saveSession).ExecContext(ctx, session.UserID, session.AppID, session.Family, session.TokenHash, session.ExpiresAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
 
-	row := req.QueryRowContext(ctx, id)
+func (s *Storage) Session(ctx context.Context, tokenHash []byte) (models.Session, error) {
+	const op = "storage.sqlite.Session"
 
-	var app models.App
-	err = row.Scan(&app.ID, &app.Name, &app.Secret)
+	row := s.stmt(ctx, s.stmts.session).QueryRowContext(ctx, tokenHash)
+
+	var session models.Session
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.AppID, &session.Family, &session.TokenHash,
+		&session.ExpiresAt, &session.CreatedAt, &session.Rotated, &session.Revoked,
+	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return models.App{}, fmt.Errorf("%s: %w", op, storage.ErrAppNotFound)
+			return models.Session{}, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
 		}
-		return models.App{}, fmt.Errorf("%s: %w", op, err)
+		return models.Session{}, fmt.Errorf("%s: %w", op, err)
 	}
-	return app, nil
+	return session, nil
+}
+
+// RotateSession marks the session as used. It fails with storage.ErrSessionRotated
+// if the session has already been rotated or revoked, so two concurrent refreshes
+// with the same token cannot both succeed.
+func (s *Storage) RotateSession(ctx context.Context, id int64) error {
+	const op = "storage.sqlite.RotateSession"
+
+	res, err := s.stmt(ctx, s.stmts.rotateSession).ExecContext(ctx, id)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrSessionRotated)
+	}
+	return nil
+}
+
+func (s *Storage) RevokeSessionFamily(ctx context.Context, family string) error {
+	const op = "storage.sqlite.RevokeSessionFamily"
+
+	if _, err := s.stmt(ctx, s.stmts.revokeSessionFamily).ExecContext(ctx, family); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of the user and returns the
+// families that were still active.
+func (s *Storage) RevokeUserSessions(ctx context.Context, userID int64) (families []string, err error) {
+	const op = "storage.sqlite.RevokeUserSessions"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	families, err = revokeUserSessions(ctx, tx, userID, "")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return families, nil
+}
+
+// revokeUserSessions revokes every session of the user except those of the
+// keepFamily family, if not empty, and returns the families that were still active.
+func revokeUserSessions(ctx context.Context, tx *txn, userID int64, keepFamily string) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT family FROM sessions WHERE user_id = ? AND family != ? AND revoked = FALSE",
+		userID, keepFamily)
+	if err != nil {
+		return nil, err
+	}
+
+	var families []string
+	if err := scan.Rows(&families, rows); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked = TRUE WHERE user_id = ? AND family != ?", userID, keepFamily); err != nil {
+		return nil, err
+	}
+	return families, nil
+}
+
+// SessionFamilyRevoked reports whether any session of the family has been revoked.
+func (s *Storage) SessionFamilyRevoked(ctx context.Context, family string) (bool, error) {
+	const op = "storage.sqlite.SessionFamilyRevoked"
+
+	var revoked bool
+	err := s.stmt(ctx, s.stmts.sessionFamilyRevoked).QueryRowContext(ctx, family).Scan(&revoked)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, fmt.Errorf("%s: %w", op, storage.ErrSessionNotFound)
+		}
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return revoked, nil
+}
+
+func (s *Storage) SaveSigningKey(ctx context.Context, key models.SigningKey) error {
+	const op = "storage.sqlite.SaveSigningKey"
+
+	_, err := s.stmt(ctx, s.stmts.saveSigningKey).ExecContext(ctx, key.ID, key.AppID, key.Algorithm, key.PrivateKey, models.KeyStatusActive, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrKeyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RotateSigningKey makes key the active key of its app and records event in
+// the audit log. The previously active key keeps verifying tokens until
+// retiresAt, or is retired right away if retiresAt is not in the future.
+func (s *Storage) RotateSigningKey(ctx context.Context, key models.SigningKey, retiresAt time.Time, event models.AuditEvent) (err error) {
+	const op = "storage.sqlite.RotateSigningKey"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	status := models.KeyStatusVerifying
+	if !retiresAt.After(time.Now()) {
+		status = models.KeyStatusRetired
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE signing_keys SET status = ?, retires_at = ? WHERE app_id = ? AND status = ?",
+		status, retiresAt.UTC(), key.AppID, models.KeyStatusActive)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO signing_keys (id, app_id, algorithm, private_key, status, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		key.ID, key.AppID, key.Algorithm, key.PrivateKey, models.KeyStatusActive, time.Now().UTC())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return fmt.Errorf("%s: %w", op, storage.ErrKeyExists)
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (s *Storage) SigningKey(ctx context.Context, id string) (models.SigningKey, error) {
+	const op = "storage.sqlite.SigningKey"
+
+	key, err := scanSigningKey(s.stmt(ctx, s.stmts.signingKey).QueryRowContext(ctx, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SigningKey{}, fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+		}
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return key, nil
+}
+
+// AppSigningKey returns the active key of the app.
+func (s *Storage) AppSigningKey(ctx context.Context, appID int32) (models.SigningKey, error) {
+	const op = "storage.sqlite.AppSigningKey"
+
+	key, err := scanSigningKey(s.stmt(ctx, s.stmts.appSigningKey).QueryRowContext(ctx, appID, models.KeyStatusActive))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.SigningKey{}, fmt.Errorf("%s: %w", op, storage.ErrKeyNotFound)
+		}
+		return models.SigningKey{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return key, nil
+}
+
+// SigningKeys returns the active and verifying keys of the app, or of every
+// app if appID is 0.
+func (s *Storage) SigningKeys(ctx context.Context, appID int32) ([]models.SigningKey, error) {
+	const op = "storage.sqlite.SigningKeys"
+
+	rows, err := s.stmt(ctx, s.stmts.signingKeys).QueryContext(ctx, appID, appID, models.KeyStatusRetired)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKey
+	for rows.Next() {
+		key, err := scanSigningKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return keys, nil
+}
+
+const sessionColumns = "id, user_id, app_id, family, token_hash, expires_at, created_at, rotated, revoked"
+
+const signingKeyColumns = "id, app_id, algorithm, private_key, status, created_at, retires_at"
+
+func scanSigningKey(row interface{ Scan(dest ...any) error }) (models.SigningKey, error) {
+	var (
+		key       models.SigningKey
+		retiresAt sql.NullTime
+	)
+	err := row.Scan(&key.ID, &key.AppID, &key.Algorithm, &key.PrivateKey, &key.Status, &key.CreatedAt, &retiresAt)
+	if err != nil {
+		return models.SigningKey{}, err
+	}
+	key.RetiresAt = retiresAt.Time
+	return key, nil
+}
+
+// CreateRole saves the role with its permissions and records the event in
+// the audit log.
+func (s *Storage) CreateRole(ctx context.Context, role models.Role, event models.AuditEvent) (id int64, err error) {
+	const op = "storage.sqlite.CreateRole"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO roles (name) VALUES (?)", role.Name)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrRoleExists)
+		}
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	id, err = res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, permission := range role.Permissions {
+		_, err = tx.ExecContext(ctx, "INSERT INTO role_permissions (role_id, permission) VALUES (?, ?) ON CONFLICT DO NOTHING",
+			id, permission)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = insertAuditEvent(ctx, tx, event); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return id, nil
+}
+
+// Role returns the role with the given name together with its permissions.
+func (s *Storage) Role(ctx context.Context, name string) (models.Role, error) {
+	const op = "storage.sqlite.Role"
+
+	role := models.Role{Name: name}
+	err := s.stmt(ctx, s.stmts.roleID).QueryRowContext(ctx, name).Scan(&role.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Role{}, fmt.Errorf("%s: %w", op, storage.ErrRoleNotFound)
+		}
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	rows, err := s.stmt(ctx, s.stmts.rolePermissions).QueryContext(ctx, role.ID)
+	if err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	if err := scan.Rows(&role.Permissions, rows); err != nil {
+		return models.Role{}, fmt.Errorf("%s: %w", op, err)
+	}
+	return role, nil
+}
+
+// AssignRole gives the user the role in the app. Assigning a role the user
+// already holds is a no-op and is not recorded in the audit log.
+func (s *Storage) AssignRole(ctx context.Context, userID int64, appID int32, roleID int64, event models.AuditEvent) (err error) {
+	const op = "storage.sqlite.AssignRole"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO user_roles (user_id, app_id, role_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING",
+		userID, appID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = auditIfAffected(ctx, tx, res, event); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UnassignRole takes the role in the app away from the user. Unassigning a
+// role the user does not hold is a no-op and is not recorded in the audit log.
+// Taking away the last admin of the app fails with storage.ErrLastAdmin unless
+// the app of the service admins, adminAppID, still has one.
+func (s *Storage) UnassignRole(ctx context.Context, userID int64, appID int32, roleID int64, adminAppID int32, event models.AuditEvent) (err error) {
+	const op = "storage.sqlite.UnassignRole"
+
+	tx, err := s.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = ? AND app_id = ? AND role_id = ?",
+		userID, appID, roleID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if n > 0 {
+		var lastAdmin bool
+		err = tx.QueryRowContext(ctx, `SELECT r.name = ? AND NOT EXISTS (SELECT 1 FROM user_roles ur
+				WHERE ur.role_id = r.id AND ur.app_id IN (?, ?))
+			FROM roles r WHERE r.id = ?`, models.RoleAdmin, appID, adminAppID, roleID).Scan(&lastAdmin)
+		if err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if lastAdmin {
+			return fmt.Errorf("%s: %w", op, storage.ErrLastAdmin)
+		}
+
+		if err = insertAuditEvent(ctx, tx, event); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// UserRoles returns the names of the roles the user holds in the app.
+func (s *Storage) UserRoles(ctx context.Context, userID int64, appID int32) ([]string, error) {
+	const op = "storage.sqlite.UserRoles"
+
+	rows, err := s.stmt(ctx, s.stmts.userRoles).QueryContext(ctx, userID, appID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var roles []string
+	if err := scan.Rows(&roles, rows); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return roles, nil
+}
+
+// HasPermission reports whether any role the user holds in the app grants
+// the permission, either by name or through PermissionAll.
+func (s *Storage) HasPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error) {
+	const op = "storage.sqlite.HasPermission"
+
+	var has bool
+	err := s.stmt(ctx, s.stmts.hasPermission).QueryRowContext(ctx, userID, appID, permission, models.PermissionAll).Scan(&has)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return has, nil
+}
+
+func insertAuditEvent(ctx context.Context, tx *txn, event models.AuditEvent) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO audit_log (user_id, app_id, action, details, created_at) VALUES (NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?)",
+		event.UserID, event.AppID, event.Action, event.Details, time.Now().UTC())
+	return err
+}
+
+// auditIfAffected records the event only if the statement changed a row.
+func auditIfAffected(ctx context.Context, tx *txn, res sql.Result, event models.AuditEvent) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+	return insertAuditEvent(ctx, tx, event)
+}
 
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique) ||
+		errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintPrimaryKey))
 }
@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// statements are the queries run outside of transactions. They are prepared
+// once by New and closed by Close.
+type statements struct {
+	saveUser, user, userByID, isAdmin, updatePasswordHash          *sql.Stmt
+	markUserDeleted                                                *sql.Stmt
+	app, apps                                                      *sql.Stmt
+	saveSession, session, rotateSession, revokeSessionFamily       *sql.Stmt
+	sessionFamilyRevoked                                           *sql.Stmt
+	saveSigningKey, signingKey, appSigningKey, signingKeys         *sql.Stmt
+	roleID, rolePermissions, userRoles, hasPermission              *sql.Stmt
+	userRoleAssignments, userSessions, userAuditEvents             *sql.Stmt
+	saveMFASecret, mfa, useMFAStep, useRecoveryCode                *sql.Stmt
+	recoveryCodesLeft                                              *sql.Stmt
+	saveMFAChallenge, mfaChallenge, failMFAChallenge, useChallenge *sql.Stmt
+	savePasswordReset, passwordReset                               *sql.Stmt
+	saveEmailVerification, emailVerification                       *sql.Stmt
+	loginFailures, addLoginFailure, blockLogin, resetLoginFailures *sql.Stmt
+	removeLoginFailure                                             *sql.Stmt
+
+	prepared []*sql.Stmt
+}
+
+func (s *statements) prepare(ctx context.Context, db *sql.DB) error {
+	for _, q := range []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&s.saveUser, "INSERT INTO users (email, email_normalized, pass_hash, app_id) VALUES (?, ?, ?, ?)"},
+		{&s.user, `SELECT id, email, email_normalized, pass_hash, app_id, email_verified FROM users
+			WHERE app_id = ? AND email_normalized = ? AND deleted_at IS NULL`},
+		{&s.userByID, "SELECT id, email, email_normalized, pass_hash, app_id, email_verified FROM users WHERE id = ? AND deleted_at IS NULL"},
+		{&s.isAdmin, `SELECT EXISTS (SELECT 1
+			FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = u.id AND ur.app_id = ? AND r.name = ?)
+			FROM users u WHERE u.id = ? AND u.deleted_at IS NULL`},
+		{&s.updatePasswordHash, "UPDATE users SET pass_hash = ? WHERE id = ? AND pass_hash = ?"},
+		{&s.markUserDeleted, "UPDATE users SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL"},
+
+		{&s.app, "SELECT " + appColumns + " FROM apps WHERE id = ?"},
+		{&s.apps, "SELECT " + appColumns + " FROM apps ORDER BY id"},
+
+		{&s.saveSession, "INSERT INTO sessions (user_id, app_id, family, token_hash, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)"},
+		{&s.session, "SELECT " + sessionColumns + " FROM sessions WHERE token_hash = ?"},
+		{&s.rotateSession, "UPDATE sessions SET rotated = TRUE WHERE id = ? AND rotated = FALSE AND revoked = FALSE"},
+		{&s.revokeSessionFamily, "UPDATE sessions SET revoked = TRUE WHERE family = ?"},
+		{&s.sessionFamilyRevoked, "SELECT MAX(revoked) FROM sessions WHERE family = ? GROUP BY family"},
+
+		{&s.saveSigningKey, "INSERT INTO signing_keys (id, app_id, algorithm, private_key, status, created_at) VALUES (?, ?, ?, ?, ?, ?)"},
+		{&s.signingKey, "SELECT " + signingKeyColumns + " FROM signing_keys WHERE id = ?"},
+		{&s.appSigningKey, "SELECT " + signingKeyColumns + " FROM signing_keys WHERE app_id = ? AND status = ?"},
+		{&s.signingKeys, "SELECT " + signingKeyColumns + " FROM signing_keys WHERE (? = 0 OR app_id = ?) AND status != ? ORDER BY created_at"},
+
+		{&s.roleID, "SELECT id FROM roles WHERE name = ?"},
+		{&s.rolePermissions, "SELECT permission FROM role_permissions WHERE role_id = ? ORDER BY permission"},
+		{&s.userRoles, `SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = ? AND ur.app_id = ? ORDER BY r.name`},
+		{&s.hasPermission, `SELECT EXISTS (SELECT 1
+			FROM user_roles ur JOIN role_permissions rp ON rp.role_id = ur.role_id
+			WHERE ur.user_id = ? AND ur.app_id = ? AND rp.permission IN (?, ?))`},
+
+		{&s.userRoleAssignments, `SELECT ur.app_id, r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+			WHERE ur.user_id = ? ORDER BY ur.app_id, r.name`},
+		{&s.userSessions, "SELECT " + sessionColumns + " FROM sessions WHERE user_id = ? ORDER BY id"},
+		{&s.userAuditEvents, `SELECT id, user_id, COALESCE(app_id, 0), action, details, created_at
+			FROM audit_log WHERE user_id = ? ORDER BY id`},
+
+		{&s.saveMFASecret, `INSERT INTO mfa (user_id, secret, created_at) VALUES (?, ?, ?)
+			ON CONFLICT (user_id) DO UPDATE SET secret = excluded.secret, created_at = excluded.created_at
+			WHERE mfa.confirmed = FALSE`},
+		{&s.mfa, "SELECT user_id, secret, confirmed, last_step, created_at FROM mfa WHERE user_id = ?"},
+		{&s.useMFAStep, "UPDATE mfa SET last_step = ? WHERE user_id = ? AND last_step < ?"},
+		{&s.useRecoveryCode, "UPDATE recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL"},
+		{&s.recoveryCodesLeft, "SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL"},
+		{&s.saveMFAChallenge, "INSERT INTO mfa_challenges (token_hash, user_id, app_id, expires_at) VALUES (?, ?, ?, ?)"},
+		{&s.mfaChallenge, "SELECT id, token_hash, user_id, app_id, attempts, expires_at, used FROM mfa_challenges WHERE token_hash = ?"},
+		{&s.failMFAChallenge, "UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = ?"},
+		{&s.useChallenge, "UPDATE mfa_challenges SET used = TRUE WHERE id = ? AND used = FALSE"},
+
+		{&s.savePasswordReset, "INSERT INTO password_resets (token_hash, user_id, expires_at, created_at) VALUES (?, ?, ?, ?)"},
+		{&s.passwordReset, `SELECT id, user_id, token_hash, expires_at, created_at, used_at IS NOT NULL
+			FROM password_resets WHERE token_hash = ?`},
+
+		{&s.saveEmailVerification, `INSERT INTO email_verifications (token_hash, user_id, email, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?)`},
+		{&s.emailVerification, `SELECT id, user_id, email, token_hash, expires_at, created_at, used_at IS NOT NULL
+			FROM email_verifications WHERE token_hash = ?`},
+
+		{&s.loginFailures, "SELECT failures, blocked_until, last_failure_at FROM login_failures WHERE scope = ? AND key = ?"},
+		{&s.addLoginFailure, `INSERT INTO login_failures (scope, key, failures, last_failure_at) VALUES (?, ?, 1, ?)
+			ON CONFLICT (scope, key) DO UPDATE SET
+				failures = CASE WHEN last_failure_at < ? THEN 1 ELSE failures + 1 END,
+				blocked_until = CASE WHEN last_failure_at < ? THEN NULL ELSE blocked_until END,
+				last_failure_at = excluded.last_failure_at
+			RETURNING failures`},
+		{&s.blockLogin, `UPDATE login_failures SET blocked_until = ?
+			WHERE scope = ? AND key = ? AND (blocked_until IS NULL OR blocked_until < ?)`},
+		{&s.removeLoginFailure, "UPDATE login_failures SET failures = failures - 1 WHERE scope = ? AND key = ? AND failures > 0"},
+		{&s.resetLoginFailures, "DELETE FROM login_failures WHERE scope = ? AND key = ?"},
+	} {
+		stmt, err := db.PrepareContext(ctx, q.query)
+		if err != nil {
+			return errors.Join(err, s.close())
+		}
+		*q.stmt = stmt
+		s.prepared = append(s.prepared, stmt)
+	}
+	return nil
+}
+
+// close closes the statements prepared so far.
+func (s *statements) close() error {
+	var errs []error
+	for _, stmt := range s.prepared {
+		errs = append(errs, stmt.Close())
+	}
+	s.prepared = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/mattn/go-sqlite3"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"time"
+)
+
+const (
+	// txAttempts is how many times WithTx runs a transaction failing with
+	// SQLITE_BUSY before it gives up.
+	txAttempts = 5
+	// txRetryDelay is the wait before the second attempt, doubled before
+	// every further one.
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// WithTx runs fn in a transaction and commits it if fn returns nil. Inside
+// another WithTx, fn joins the enclosing transaction.
+//
+// The transaction holds the write lock of the database until it ends. If it
+// fails with SQLITE_BUSY, because the lock was not taken within the busy
+// timeout, it is rolled back and run again, so fn must not have effects
+// outside the storage.
+func (s *Storage) WithTx(ctx context.Context, fn func(tx storage.Storage) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	delay := txRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := s.runTx(ctx, fn)
+		if err == nil || !isBusy(err) || attempt == txAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// runTx runs fn in a new transaction. The errors of fn are returned as they are.
+func (s *Storage) runTx(ctx context.Context, fn func(tx storage.Storage) error) (err error) {
+	const op = "storage.sqlite.WithTx"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if err = fn(&Storage{db: s.db, stmts: s.stmts, tx: tx}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// isBusy reports whether err comes from SQLite failing to lock the database.
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrBusy
+}
+
+// txn is the transaction of a single operation of the storage. Inside WithTx
+// it is a savepoint of the enclosing transaction, so that a failed operation
+// is still undone as a whole.
+type txn struct {
+	*sql.Tx
+	savepoint bool
+}
+
+// begin starts the transaction of an operation of the storage.
+func (s *Storage) begin(ctx context.Context) (*txn, error) {
+	if s.tx == nil {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &txn{Tx: tx}, nil
+	}
+
+	if _, err := s.tx.ExecContext(ctx, "SAVEPOINT operation"); err != nil {
+		return nil, err
+	}
+	return &txn{Tx: s.tx, savepoint: true}, nil
+}
+
+func (t *txn) Commit() error {
+	if !t.savepoint {
+		return t.Tx.Commit()
+	}
+	_, err := t.Exec("RELEASE SAVEPOINT operation")
+	return err
+}
+
+func (t *txn) Rollback() error {
+	if !t.savepoint {
+		return t.Tx.Rollback()
+	}
+	if _, err := t.Exec("ROLLBACK TO SAVEPOINT operation"); err != nil {
+		return err
+	}
+	_, err := t.Exec("RELEASE SAVEPOINT operation")
+	return err
+}
+
+// stmt returns the prepared statement bound to the transaction of WithTx, if any.
+func (s *Storage) stmt(ctx context.Context, stmt *sql.Stmt) *sql.Stmt {
+	if s.tx == nil {
+		return stmt
+	}
+	return s.tx.StmtContext(ctx, stmt)
+}
@@ -1,9 +1,104 @@
 package storage
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"time"
+)
 
 var (
-	ErrUserExists   = errors.New("user already exists")
-	ErrUserNotFound = errors.New("user not found")
-	ErrAppNotFound  = errors.New("app not found")
+	ErrUserExists           = errors.New("user already exists")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrAppNotFound          = errors.New("app not found")
+	ErrAppExists            = errors.New("app already exists")
+	ErrAppInUse             = errors.New("app still has users")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrSessionRotated       = errors.New("session already rotated")
+	ErrKeyExists            = errors.New("signing key already exists")
+	ErrKeyNotFound          = errors.New("signing key not found")
+	ErrRoleExists           = errors.New("role already exists")
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrLastAdmin            = errors.New("cannot remove the last admin")
+	ErrMFANotFound          = errors.New("mfa not enrolled")
+	ErrMFAConfirmed         = errors.New("mfa already confirmed")
+	ErrMFAStepUsed          = errors.New("mfa code already used")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found")
+	ErrChallengeNotFound    = errors.New("mfa challenge not found")
+	ErrChallengeUsed        = errors.New("mfa challenge already used")
+	ErrResetNotFound        = errors.New("password reset not found")
+	ErrResetUsed            = errors.New("password reset already used")
+	ErrVerificationNotFound = errors.New("email verification not found")
+	ErrVerificationUsed     = errors.New("email verification already used")
 )
+
+// Storage keeps the data of the service. Operations taking an audit event
+// record it together with the change they make.
+type Storage interface {
+	// WithTx runs fn in a transaction and commits it if fn returns nil.
+	// Every operation of the storage passed to fn is part of the transaction.
+	// fn should return the error of the first operation that fails, as the
+	// transaction may not accept further ones. Inside another WithTx, fn joins
+	// the enclosing transaction. fn may be run more than once, so it should
+	// not have effects outside the storage.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+	SaveUser(ctx context.Context, email string, normalizedEmail string, passwordHash []byte, appId int32) (uid int64, err error)
+	User(ctx context.Context, appID int32, normalizedEmail string) (models.User, error)
+	UserByID(ctx context.Context, id int64) (models.User, error)
+	IsAdmin(ctx context.Context, userID int64, appID int32) (isAdmin bool, err error)
+	App(ctx context.Context, id int32) (models.App, error)
+	Apps(ctx context.Context) ([]models.App, error)
+	CreateApp(ctx context.Context, app models.App, event models.AuditEvent) (id int64, err error)
+	UpdateApp(ctx context.Context, app models.App, event models.AuditEvent) error
+	UpdateAppSecret(ctx context.Context, appID int32, secret string, event models.AuditEvent) error
+	SetAppDisabled(ctx context.Context, appID int32, disabled bool, event models.AuditEvent) (families []string, err error)
+	DeleteApp(ctx context.Context, appID int32, event models.AuditEvent) error
+	SaveSession(ctx context.Context, session models.Session) (id int64, err error)
+	Session(ctx context.Context, tokenHash []byte) (models.Session, error)
+	RotateSession(ctx context.Context, id int64) error
+	RevokeSessionFamily(ctx context.Context, family string) error
+	SessionFamilyRevoked(ctx context.Context, family string) (revoked bool, err error)
+	RevokeUserSessions(ctx context.Context, userID int64) (families []string, err error)
+	SaveSigningKey(ctx context.Context, key models.SigningKey) error
+	SigningKey(ctx context.Context, id string) (models.SigningKey, error)
+	AppSigningKey(ctx context.Context, appID int32) (models.SigningKey, error)
+	SigningKeys(ctx context.Context, appID int32) ([]models.SigningKey, error)
+	RotateSigningKey(ctx context.Context, key models.SigningKey, retiresAt time.Time, event models.AuditEvent) error
+	CreateRole(ctx context.Context, role models.Role, event models.AuditEvent) (id int64, err error)
+	Role(ctx context.Context, name string) (models.Role, error)
+	AssignRole(ctx context.Context, userID int64, appID int32, roleID int64, event models.AuditEvent) error
+	UnassignRole(ctx context.Context, userID int64, appID int32, roleID int64, adminAppID int32, event models.AuditEvent) error
+	UserRoles(ctx context.Context, userID int64, appID int32) (roles []string, err error)
+	HasPermission(ctx context.Context, userID int64, appID int32, permission string) (bool, error)
+	SaveMFASecret(ctx context.Context, userID int64, secret string) error
+	MFA(ctx context.Context, userID int64) (models.MFA, error)
+	ConfirmMFA(ctx context.Context, userID int64, step int64, recoveryCodeHashes [][]byte) error
+	UseMFAStep(ctx context.Context, userID int64, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int64, codeHash []byte) error
+	SaveMFAChallenge(ctx context.Context, challenge models.MFAChallenge) error
+	MFAChallenge(ctx context.Context, tokenHash []byte) (models.MFAChallenge, error)
+	FailMFAChallenge(ctx context.Context, id int64) error
+	UseMFAChallenge(ctx context.Context, id int64) error
+	SavePasswordReset(ctx context.Context, reset models.PasswordReset) error
+	PasswordReset(ctx context.Context, tokenHash []byte) (models.PasswordReset, error)
+	UsePasswordReset(ctx context.Context, reset models.PasswordReset) error
+	SaveEmailVerification(ctx context.Context, verification models.EmailVerification) error
+	EmailVerification(ctx context.Context, tokenHash []byte) (models.EmailVerification, error)
+	VerifyEmail(ctx context.Context, verification models.EmailVerification) error
+	ChangePassword(ctx context.Context, userID int64, passwordHash []byte, keepFamily string) (families []string, err error)
+	UpdatePasswordHash(ctx context.Context, userID int64, oldHash []byte, newHash []byte) error
+	ChangeEmail(ctx context.Context, userID int64, email string, normalizedEmail string) error
+	MarkUserDeleted(ctx context.Context, userID int64) error
+	UnassignUserRoles(ctx context.Context, userID int64, adminAppID int32) error
+	PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (purged int64, err error)
+	UserRoleAssignments(ctx context.Context, userID int64) ([]models.RoleAssignment, error)
+	UserSessions(ctx context.Context, userID int64) ([]models.Session, error)
+	UserAuditEvents(ctx context.Context, userID int64) ([]models.AuditEvent, error)
+	RecoveryCodesLeft(ctx context.Context, userID int64) (int, error)
+	LoginFailures(ctx context.Context, scope string, key string) (models.LoginFailures, error)
+	AddLoginFailure(ctx context.Context, scope string, key string, since time.Time) (failures int, err error)
+	BlockLogin(ctx context.Context, scope string, key string, until time.Time) error
+	RemoveLoginFailure(ctx context.Context, scope string, key string) error
+	ResetLoginFailures(ctx context.Context, scope string, key string) error
+	UnlockLogin(ctx context.Context, scope string, key string, event models.AuditEvent) error
+}
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS login_failures
+(
+    scope           TEXT      NOT NULL,
+    key             TEXT      NOT NULL,
+    failures        INTEGER   NOT NULL DEFAULT 0,
+    blocked_until   TIMESTAMP,
+    last_failure_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (scope, key)
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE apps DROP COLUMN password_check_breached;
+ALTER TABLE apps DROP COLUMN password_forbid_email;
+ALTER TABLE apps DROP COLUMN password_min_classes;
+ALTER TABLE apps DROP COLUMN password_max_length;
+ALTER TABLE apps DROP COLUMN password_min_length;
\ No newline at end of file
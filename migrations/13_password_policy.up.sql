@@ -0,0 +1,8 @@
+-- Password policy of the app. Lengths count characters, except the maximum,
+-- which counts bytes as bcrypt ignores everything after the 72nd byte.
+ALTER TABLE apps ADD COLUMN password_min_length INTEGER NOT NULL DEFAULT 8;
+ALTER TABLE apps ADD COLUMN password_max_length INTEGER NOT NULL DEFAULT 72;
+-- Number of character classes (lowercase, uppercase, digits, symbols) a password must mix.
+ALTER TABLE apps ADD COLUMN password_min_classes INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE apps ADD COLUMN password_forbid_email BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE apps ADD COLUMN password_check_breached BOOLEAN NOT NULL DEFAULT TRUE;
\ No newline at end of file
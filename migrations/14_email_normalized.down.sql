@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_app_email_normalized;
+
+ALTER TABLE users DROP COLUMN email_normalized;
\ No newline at end of file
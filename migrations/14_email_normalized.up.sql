@@ -0,0 +1,6 @@
+-- The email in the form emails are compared in, see pkg/emailaddr. SQL cannot
+-- apply its rules, so the migrator fills the column of existing users before
+-- 17_email_normalized_not_null and fails if any of them end up sharing an email.
+ALTER TABLE users ADD COLUMN email_normalized TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_email_normalized ON users (app_id, email_normalized);
\ No newline at end of file
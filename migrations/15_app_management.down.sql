@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS app_redirect_uris;
+DROP TABLE IF EXISTS app_audiences;
+
+ALTER TABLE apps DROP COLUMN disabled_at;
\ No newline at end of file
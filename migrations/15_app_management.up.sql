@@ -0,0 +1,18 @@
+-- A disabled app keeps its users but issues no tokens.
+ALTER TABLE apps ADD COLUMN disabled_at TIMESTAMP;
+
+-- Audiences the tokens of the app are meant for.
+CREATE TABLE IF NOT EXISTS app_audiences
+(
+    app_id   INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    audience TEXT    NOT NULL,
+    PRIMARY KEY (app_id, audience)
+);
+
+-- URIs users of the app may be sent back to after signing in.
+CREATE TABLE IF NOT EXISTS app_redirect_uris
+(
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    uri    TEXT    NOT NULL,
+    PRIMARY KEY (app_id, uri)
+);
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS audit_log_linked
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER REFERENCES users (id),
+    app_id     INTEGER REFERENCES apps (id),
+    action     TEXT      NOT NULL,
+    details    TEXT      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Entries of deleted apps lose their app, as the old table cannot reference it.
+INSERT INTO audit_log_linked (id, user_id, app_id, action, details, created_at)
+SELECT id, user_id, (SELECT id FROM apps WHERE id = audit_log.app_id), action, details, created_at
+FROM audit_log;
+
+DROP INDEX IF EXISTS idx_audit_log_user_id;
+DROP TABLE audit_log;
+ALTER TABLE audit_log_linked RENAME TO audit_log;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id);
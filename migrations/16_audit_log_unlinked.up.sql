@@ -0,0 +1,21 @@
+-- Audit entries outlive the apps and users they mention, so audit_log must
+-- not reference them once foreign keys are enforced.
+CREATE TABLE IF NOT EXISTS audit_log_unlinked
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER,
+    app_id     INTEGER,
+    action     TEXT      NOT NULL,
+    details    TEXT      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO audit_log_unlinked (id, user_id, app_id, action, details, created_at)
+SELECT id, user_id, app_id, action, details, created_at
+FROM audit_log;
+
+DROP INDEX IF EXISTS idx_audit_log_user_id;
+DROP TABLE audit_log;
+ALTER TABLE audit_log_unlinked RENAME TO audit_log;
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS users_nullable
+(
+    id               INTEGER PRIMARY KEY,
+    email            TEXT    NOT NULL,
+    pass_hash        BLOB    NOT NULL,
+    app_id           INTEGER REFERENCES apps (id),
+    email_verified   BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at       TIMESTAMP,
+    email_normalized TEXT,
+    UNIQUE (app_id, email)
+);
+
+INSERT INTO users_nullable (id, email, pass_hash, app_id, email_verified, deleted_at, email_normalized)
+SELECT id, email, pass_hash, app_id, email_verified, deleted_at, email_normalized
+FROM users;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_app_email_normalized;
+DROP TABLE users;
+ALTER TABLE users_nullable RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_email_normalized ON users (app_id, email_normalized);
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- Every user has a normalized email once the migrator has filled them in.
+CREATE TABLE IF NOT EXISTS users_normalized
+(
+    id               INTEGER PRIMARY KEY,
+    email            TEXT    NOT NULL,
+    pass_hash        BLOB    NOT NULL,
+    app_id           INTEGER REFERENCES apps (id),
+    email_verified   BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at       TIMESTAMP,
+    email_normalized TEXT    NOT NULL,
+    UNIQUE (app_id, email)
+);
+
+INSERT INTO users_normalized (id, email, pass_hash, app_id, email_verified, deleted_at, email_normalized)
+SELECT id, email, pass_hash, app_id, email_verified, deleted_at, email_normalized
+FROM users;
+
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_app_email_normalized;
+DROP TABLE users;
+ALTER TABLE users_normalized RENAME TO users;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_email_normalized ON users (app_id, email_normalized);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_user_id;
+DROP INDEX IF EXISTS idx_sessions_family;
+DROP TABLE IF EXISTS sessions;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER   NOT NULL REFERENCES users (id),
+    app_id     INTEGER   NOT NULL REFERENCES apps (id),
+    family     TEXT      NOT NULL,
+    token_hash BLOB      NOT NULL UNIQUE,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated    BOOLEAN   NOT NULL DEFAULT FALSE,
+    revoked    BOOLEAN   NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_family ON sessions (family);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
\ No newline at end of file
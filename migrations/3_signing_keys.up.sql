@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS signing_keys
+(
+    id          TEXT PRIMARY KEY,
+    app_id      INTEGER   NOT NULL UNIQUE REFERENCES apps (id),
+    algorithm   TEXT      NOT NULL,
+    private_key BLOB      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,18 @@
+DROP INDEX IF EXISTS idx_signing_keys_active;
+
+CREATE TABLE IF NOT EXISTS signing_keys_single
+(
+    id          TEXT PRIMARY KEY,
+    app_id      INTEGER   NOT NULL UNIQUE REFERENCES apps (id),
+    algorithm   TEXT      NOT NULL,
+    private_key BLOB      NOT NULL,
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO signing_keys_single (id, app_id, algorithm, private_key, created_at)
+SELECT id, app_id, algorithm, private_key, created_at
+FROM signing_keys
+WHERE status = 'active';
+
+DROP TABLE signing_keys;
+ALTER TABLE signing_keys_single RENAME TO signing_keys;
\ No newline at end of file
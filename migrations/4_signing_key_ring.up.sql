@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS signing_keys_ring
+(
+    id          TEXT PRIMARY KEY,
+    app_id      INTEGER   NOT NULL REFERENCES apps (id),
+    algorithm   TEXT      NOT NULL,
+    private_key BLOB      NOT NULL,
+    status      TEXT      NOT NULL DEFAULT 'active',
+    created_at  TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    retires_at  TIMESTAMP
+);
+
+INSERT INTO signing_keys_ring (id, app_id, algorithm, private_key, created_at)
+SELECT id, app_id, algorithm, private_key, created_at
+FROM signing_keys;
+
+DROP TABLE signing_keys;
+ALTER TABLE signing_keys_ring RENAME TO signing_keys;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_active ON signing_keys (app_id) WHERE status = 'active';
\ No newline at end of file
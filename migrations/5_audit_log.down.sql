@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_audit_log_user_id;
+DROP TABLE IF EXISTS audit_log;
\ No newline at end of file
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id         INTEGER PRIMARY KEY,
+    user_id    INTEGER REFERENCES users (id),
+    app_id     INTEGER REFERENCES apps (id),
+    action     TEXT      NOT NULL,
+    details    TEXT      NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id);
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS users_global
+(
+    id        INTEGER PRIMARY KEY,
+    email     TEXT    NOT NULL UNIQUE,
+    pass_hash BLOB    NOT NULL,
+    is_admin  BOOLEAN NOT NULL DEFAULT FALSE,
+    app_id    INTEGER REFERENCES apps (id)
+);
+
+-- Only the oldest account of every email survives the global uniqueness.
+INSERT INTO users_global (id, email, pass_hash, is_admin, app_id)
+SELECT id, email, pass_hash, is_admin, app_id
+FROM users
+WHERE id IN (SELECT MIN(id) FROM users GROUP BY email);
+
+DROP TABLE users;
+ALTER TABLE users_global RENAME TO users;
\ No newline at end of file
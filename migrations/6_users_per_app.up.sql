@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS users_per_app
+(
+    id        INTEGER PRIMARY KEY,
+    email     TEXT    NOT NULL,
+    pass_hash BLOB    NOT NULL,
+    is_admin  BOOLEAN NOT NULL DEFAULT FALSE,
+    app_id    INTEGER REFERENCES apps (id),
+    UNIQUE (app_id, email)
+);
+
+INSERT INTO users_per_app (id, email, pass_hash, is_admin, app_id)
+SELECT id, email, pass_hash, is_admin, app_id
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_per_app RENAME TO users;
\ No newline at end of file
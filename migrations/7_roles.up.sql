@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS roles
+(
+    id   INTEGER PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id    INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission TEXT    NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id  INTEGER NOT NULL REFERENCES apps (id),
+    role_id INTEGER NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, app_id, role_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+INSERT INTO roles (id, name)
+VALUES (1, 'admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+VALUES (1, '*')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, app_id, role_id)
+SELECT id, app_id, 1
+FROM users
+WHERE is_admin = TRUE
+  AND app_id IS NOT NULL;
+
+ALTER TABLE users DROP COLUMN is_admin;
\ No newline at end of file
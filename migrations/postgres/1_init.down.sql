@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS login_failures;
+DROP TABLE IF EXISTS email_verifications;
+DROP TABLE IF EXISTS password_resets;
+DROP TABLE IF EXISTS mfa_challenges;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS mfa;
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
+DROP TABLE IF EXISTS audit_log;
+DROP TABLE IF EXISTS signing_keys;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS app_redirect_uris;
+DROP TABLE IF EXISTS app_audiences;
+DROP TABLE IF EXISTS apps;
\ No newline at end of file
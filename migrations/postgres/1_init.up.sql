@@ -0,0 +1,176 @@
+-- The schema of the sqlite migrations up to 15_app_management. Audit entries
+-- outlive the users and apps they mention, so they reference neither.
+CREATE TABLE IF NOT EXISTS apps
+(
+    id                      INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name                    TEXT    NOT NULL UNIQUE,
+    secret                  TEXT    NOT NULL UNIQUE,
+    require_verified_email  BOOLEAN NOT NULL DEFAULT FALSE,
+    password_min_length     INTEGER NOT NULL DEFAULT 8,
+    password_max_length     INTEGER NOT NULL DEFAULT 72,
+    password_min_classes    INTEGER NOT NULL DEFAULT 1,
+    password_forbid_email   BOOLEAN NOT NULL DEFAULT TRUE,
+    password_check_breached BOOLEAN NOT NULL DEFAULT TRUE,
+    disabled_at             TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS app_audiences
+(
+    app_id   INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    audience TEXT    NOT NULL,
+    PRIMARY KEY (app_id, audience)
+);
+
+CREATE TABLE IF NOT EXISTS app_redirect_uris
+(
+    app_id INTEGER NOT NULL REFERENCES apps (id) ON DELETE CASCADE,
+    uri    TEXT    NOT NULL,
+    PRIMARY KEY (app_id, uri)
+);
+
+CREATE TABLE IF NOT EXISTS users
+(
+    id               BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email            TEXT    NOT NULL,
+    email_normalized TEXT    NOT NULL,
+    pass_hash        BYTEA   NOT NULL,
+    app_id           INTEGER NOT NULL REFERENCES apps (id),
+    email_verified   BOOLEAN NOT NULL DEFAULT FALSE,
+    deleted_at       TIMESTAMPTZ,
+    UNIQUE (app_id, email)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_app_email_normalized ON users (app_id, email_normalized);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS sessions
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id    BIGINT      NOT NULL REFERENCES users (id),
+    app_id     INTEGER     NOT NULL REFERENCES apps (id),
+    family     TEXT        NOT NULL,
+    token_hash BYTEA       NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    rotated    BOOLEAN     NOT NULL DEFAULT FALSE,
+    revoked    BOOLEAN     NOT NULL DEFAULT FALSE
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_family ON sessions (family);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_app_id ON sessions (app_id);
+
+CREATE TABLE IF NOT EXISTS signing_keys
+(
+    id          TEXT PRIMARY KEY,
+    app_id      INTEGER     NOT NULL REFERENCES apps (id),
+    algorithm   TEXT        NOT NULL,
+    private_key BYTEA       NOT NULL,
+    status      TEXT        NOT NULL DEFAULT 'active',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    retires_at  TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_active ON signing_keys (app_id) WHERE status = 'active';
+
+CREATE TABLE IF NOT EXISTS audit_log
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id    BIGINT,
+    app_id     INTEGER,
+    action     TEXT        NOT NULL,
+    details    TEXT        NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_audit_log_user_id ON audit_log (user_id);
+
+CREATE TABLE IF NOT EXISTS roles
+(
+    id   BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions
+(
+    role_id    BIGINT NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission TEXT   NOT NULL,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles
+(
+    user_id BIGINT  NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id  INTEGER NOT NULL REFERENCES apps (id),
+    role_id BIGINT  NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, app_id, role_id)
+);
+CREATE INDEX IF NOT EXISTS idx_user_roles_role_id ON user_roles (role_id);
+
+INSERT INTO roles (name)
+VALUES ('admin')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, '*'
+FROM roles
+WHERE name = 'admin'
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS mfa
+(
+    user_id    BIGINT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret     TEXT        NOT NULL,
+    confirmed  BOOLEAN     NOT NULL DEFAULT FALSE,
+    last_step  BIGINT      NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS recovery_codes
+(
+    id        BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id   BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash BYTEA  NOT NULL,
+    used_at   TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS mfa_challenges
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    token_hash BYTEA       NOT NULL UNIQUE,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    app_id     INTEGER     NOT NULL REFERENCES apps (id),
+    attempts   INTEGER     NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used       BOOLEAN     NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE IF NOT EXISTS password_resets
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    token_hash BYTEA       NOT NULL UNIQUE,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at    TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user_id ON password_resets (user_id);
+
+CREATE TABLE IF NOT EXISTS email_verifications
+(
+    id         BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    token_hash BYTEA       NOT NULL UNIQUE,
+    user_id    BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    email      TEXT        NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at    TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_email_verifications_user_id ON email_verifications (user_id);
+
+CREATE TABLE IF NOT EXISTS login_failures
+(
+    scope           TEXT        NOT NULL,
+    key             TEXT        NOT NULL,
+    failures        INTEGER     NOT NULL DEFAULT 0,
+    blocked_until   TIMESTAMPTZ,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
\ No newline at end of file
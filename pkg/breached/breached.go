@@ -0,0 +1,147 @@
+// Package breached checks passwords against a local list of passwords known
+// from data breaches. Only SHA-1 hashes are ever stored, so the list never
+// holds the passwords themselves. It comes in the two formats of the Pwned
+// Passwords downloads:
+//
+//   - a single file with one hex encoded hash per line, optionally followed by
+//     ":" and the number of times it was seen, which is loaded into memory and
+//     may hold at most MaxLen hashes;
+//   - a directory of range files, one per 5 character hash prefix and named
+//     after it, e.g. 21BD1.txt, with lines of the remaining 35 characters of
+//     the hash and the count, e.g. 0018A45C4D1DEF81644B54AB7F969B88D65:1. Only
+//     the file of the prefix of a password is read, when it is checked, so the
+//     full download can be used.
+package breached
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxLen is the most hashes a list loaded into memory may hold. Larger lists
+// are to be used as a directory of range files.
+const MaxLen = 1 << 20
+
+// prefixLen is the length of the hash prefix a range file is named after.
+const prefixLen = 5
+
+// List is a set of SHA-1 hashes of breached passwords held in memory.
+type List struct {
+	hashes map[[sha1.Size]byte]struct{}
+}
+
+// Load reads the list from the file at path.
+func Load(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// Read reads the list from r. Empty lines and lines starting with "#" are
+// skipped. It fails if the list holds more than MaxLen hashes.
+func Read(r io.Reader) (*List, error) {
+	list := &List{hashes: make(map[[sha1.Size]byte]struct{})}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		encoded, _, _ := strings.Cut(text, ":")
+
+		var hash [sha1.Size]byte
+		if len(encoded) != hex.EncodedLen(sha1.Size) {
+			return nil, fmt.Errorf("line %d: invalid SHA-1 hash", line)
+		}
+		if _, err := hex.Decode(hash[:], []byte(encoded)); err != nil {
+			return nil, fmt.Errorf("line %d: invalid SHA-1 hash", line)
+		}
+		list.hashes[hash] = struct{}{}
+
+		if len(list.hashes) > MaxLen {
+			return nil, fmt.Errorf("line %d: more than %d hashes, use a directory of range files", line, MaxLen)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Contains reports whether the password is in the list.
+func (l *List) Contains(password string) (bool, error) {
+	_, ok := l.hashes[sha1.Sum([]byte(password))]
+	return ok, nil
+}
+
+// Len returns the number of passwords in the list.
+func (l *List) Len() int {
+	return len(l.hashes)
+}
+
+// Ranges is a directory of range files read on demand.
+type Ranges struct {
+	dir string
+}
+
+// OpenRanges returns the range files in dir.
+func OpenRanges(dir string) (*Ranges, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", dir)
+	}
+	return &Ranges{dir: dir}, nil
+}
+
+// Contains reports whether the password is in the range file of its hash
+// prefix. A missing file means no breached password has the prefix.
+func (r *Ranges) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:prefixLen], hash[prefixLen:]
+
+	path := filepath.Join(r.dir, prefix+".txt")
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		encoded, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(encoded, suffix) {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,141 @@
+// Package emailaddr validates email addresses and brings them to the form
+// they are compared in, so that the same mailbox is never registered twice
+// under differently written addresses.
+//
+// Addresses must be an RFC 5322 addr-spec with a dot-atom local part and a
+// domain name of at least two labels. Quoted local parts, domain literals,
+// display names and comments are valid in mail headers but are rejected, as
+// no mailbox a user signs up with needs them.
+package emailaddr
+
+import (
+	"errors"
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalid = errors.New("invalid email address")
+
+// Length limits of RFC 5321.
+const (
+	maxLocalPartLength = 64
+	maxAddressLength   = 254
+	maxLabelLength     = 63
+)
+
+// Address is a valid email address.
+type Address struct {
+	// Email is the address as entered, without surrounding whitespace.
+	Email string
+	// Normalized is the address in the form addresses are compared in.
+	Normalized string
+}
+
+// Normalizer validates and normalizes email addresses.
+type Normalizer struct {
+	// CaseSensitiveLocalPart keeps the case of the local part. The domain is
+	// lowercased either way. RFC 5321 leaves the case of the local part to the
+	// receiving server, but practically no server tells cases apart.
+	CaseSensitiveLocalPart bool
+	// AllowUnicode accepts internationalized addresses (RFC 6531). Their local
+	// parts are normalized to Unicode NFC and their domains converted to ASCII
+	// (IDNA). Otherwise addresses with non-ASCII characters are rejected.
+	AllowUnicode bool
+}
+
+// Normalize validates the address and returns it together with its
+// normalized form. It returns ErrInvalid if the address is not valid.
+func (n Normalizer) Normalize(address string) (Address, error) {
+	email := strings.TrimSpace(address)
+
+	if !n.AllowUnicode && !isASCII(email) {
+		return Address{}, ErrInvalid
+	}
+	if n.AllowUnicode {
+		email = norm.NFC.String(email)
+	}
+
+	// ParseAddress accepts whole header values, so anything but a plain
+	// addr-spec shows up as a difference to the input.
+	parsed, err := mail.ParseAddress(email)
+	if err != nil || parsed.Name != "" || parsed.Address != email {
+		return Address{}, ErrInvalid
+	}
+
+	at := strings.LastIndexByte(email, '@')
+	local, domain := email[:at], email[at+1:]
+
+	if utf8.RuneCountInString(local) > maxLocalPartLength {
+		return Address{}, ErrInvalid
+	}
+
+	domain, err = normalizeDomain(domain)
+	if err != nil {
+		return Address{}, err
+	}
+
+	if !n.CaseSensitiveLocalPart {
+		local = strings.ToLower(local)
+	}
+
+	normalized := local + "@" + domain
+	if len(normalized) > maxAddressLength {
+		return Address{}, ErrInvalid
+	}
+
+	return Address{Email: email, Normalized: normalized}, nil
+}
+
+// normalizeDomain returns the lowercase ASCII form of the domain name.
+func normalizeDomain(domain string) (string, error) {
+	if strings.HasPrefix(domain, "[") {
+		return "", ErrInvalid
+	}
+
+	domain, err := idna.Lookup.ToASCII(domain)
+	if err != nil {
+		return "", ErrInvalid
+	}
+
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return "", ErrInvalid
+	}
+	for _, label := range labels {
+		if !isHostLabel(label) {
+			return "", ErrInvalid
+		}
+	}
+
+	return domain, nil
+}
+
+// isHostLabel reports whether the label is a valid label of a host name
+// (RFC 1123): letters, digits and hyphens not at either end.
+func isHostLabel(label string) bool {
+	if label == "" || len(label) > maxLabelLength {
+		return false
+	}
+	if label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		if !('a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
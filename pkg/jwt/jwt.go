@@ -1,21 +1,59 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
 	"time"
 )
 
-func NewToken(user models.User, app models.App, duration time.Duration) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
+var (
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+const tokenIDLen = 16
+
+// Claims is the typed view of the claims put into a token by NewToken.
+// The unique token ID is carried in the registered jti claim.
+type Claims struct {
+	UID           int64    `json:"uid"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	AppID         int32    `json:"app_id"`
+	SessionID     string   `json:"sid"`
+	Roles         []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// NewToken signs a token for the user with the given key. Asymmetric keys
+// put their ID into the kid header so verifiers can pick the right public key.
+// roles are the names of the roles the user holds in the app.
+func NewToken(user models.User, app models.App, sessionID string, roles []string, duration time.Duration, key Key) (string, error) {
+	id := make([]byte, tokenIDLen)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	token := jwt.New(signingMethod(key.Algorithm))
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
 
 	claims := token.Claims.(jwt.MapClaims)
 	claims["uid"] = user.ID
 	claims["email"] = user.Email
+	claims["email_verified"] = user.EmailVerified
 	claims["exp"] = time.Now().Add(duration).Unix()
 	claims["app_id"] = app.ID
+	claims["sid"] = sessionID
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+	claims["jti"] = base64.RawURLEncoding.EncodeToString(id)
 
-	tokenString, err := token.SignedString([]byte(app.Secret))
+	tokenString, err := token.SignedString(key.private)
 	if err != nil {
 		return "", err
 	}
@@ -23,3 +61,44 @@ func NewToken(user models.User, app models.App, duration time.Duration) (string,
 	return tokenString, nil
 
 }
+
+// ParseToken verifies the signature and expiry of the token and returns its claims.
+//
+// keyFunc is called with the kid header (empty for HMAC signed tokens) and the
+// app_id claim of the token and must return the key the token was signed with.
+func ParseToken(tokenString string, keyFunc func(kid string, appID int32) (Key, error)) (Claims, error) {
+	var claims Claims
+
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, err := keyFunc(kid, claims.AppID)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgES256, AlgEdDSA}),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Claims{}, errors.Join(ErrInvalidToken, err)
+	}
+
+	return claims, nil
+}
+
+func signingMethod(alg string) jwt.SigningMethod {
+	switch alg {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgES256:
+		return jwt.SigningMethodES256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodHS256
+}
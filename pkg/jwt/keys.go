@@ -0,0 +1,286 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// Supported signing algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+const (
+	rsaKeyBits    = 2048
+	hmacSecretLen = 32
+	hmacKeyIDLen  = 16
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrUnsupportedKey       = errors.New("unsupported key type")
+)
+
+// Key is the material tokens are signed and verified with.
+//
+// HMAC keys built from the app secret have no ID, so tokens signed with them
+// carry no kid header. Generated HMAC keys get a random ID and asymmetric keys
+// are identified by the RFC 7638 thumbprint of their public part.
+type Key struct {
+	ID        string
+	Algorithm string
+	private   any
+	public    any
+}
+
+// JWK is the public part of a key as published in a JWKS document (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// HMACKey returns the key for an app that signs its tokens with a shared secret.
+func HMACKey(secret string) Key {
+	return Key{
+		Algorithm: AlgHS256,
+		private:   []byte(secret),
+		public:    []byte(secret),
+	}
+}
+
+// NewHMACKey returns a stored HMAC key with the given ID.
+func NewHMACKey(id string, secret []byte) Key {
+	return Key{
+		ID:        id,
+		Algorithm: AlgHS256,
+		private:   secret,
+		public:    secret,
+	}
+}
+
+// Secret returns the shared secret of an HMAC key.
+func (k Key) Secret() []byte {
+	secret, _ := k.private.([]byte)
+	return secret
+}
+
+// IsAsymmetric reports whether the algorithm uses a private/public key pair.
+func IsAsymmetric(alg string) bool {
+	switch alg {
+	case AlgRS256, AlgES256, AlgEdDSA:
+		return true
+	}
+	return false
+}
+
+// GenerateKey creates a new random key for the given algorithm.
+func GenerateKey(alg string) (Key, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+
+	switch alg {
+	case AlgHS256:
+		return generateHMACKey()
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case AlgES256:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return Key{}, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	return newKey(private)
+}
+
+// LoadKeyFile reads a PEM encoded private key from disk.
+func LoadKeyFile(path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return ParsePrivateKeyPEM(data)
+}
+
+// ParsePrivateKeyPEM parses a PKCS#8, PKCS#1 or SEC 1 private key. The signing
+// algorithm is derived from the key type.
+func ParsePrivateKeyPEM(data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, errors.New("no PEM block found")
+	}
+
+	var (
+		private any
+		err     error
+	)
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return Key{}, ErrUnsupportedKey
+	}
+
+	return newKey(signer)
+}
+
+// MarshalPrivateKeyPEM encodes the private part of an asymmetric key as PKCS#8.
+func (k Key) MarshalPrivateKeyPEM() ([]byte, error) {
+	if !IsAsymmetric(k.Algorithm) {
+		return nil, ErrUnsupportedKey
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.private)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// JWK returns the public part of an asymmetric key.
+func (k Key) JWK() (JWK, error) {
+	jwk, err := publicJWK(k.public)
+	if err != nil {
+		return JWK{}, err
+	}
+
+	jwk.Kid = k.ID
+	jwk.Use = "sig"
+	jwk.Alg = k.Algorithm
+
+	return jwk, nil
+}
+
+func generateHMACKey() (Key, error) {
+	secret := make([]byte, hmacSecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, err
+	}
+
+	id := make([]byte, hmacKeyIDLen)
+	if _, err := rand.Read(id); err != nil {
+		return Key{}, err
+	}
+
+	return NewHMACKey(base64.RawURLEncoding.EncodeToString(id), secret), nil
+}
+
+func newKey(private crypto.Signer) (Key, error) {
+	var alg string
+
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		alg = AlgRS256
+	case *ecdsa.PrivateKey:
+		if key.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("%w: curve %s", ErrUnsupportedKey, key.Curve.Params().Name)
+		}
+		alg = AlgES256
+	case ed25519.PrivateKey:
+		alg = AlgEdDSA
+	default:
+		return Key{}, ErrUnsupportedKey
+	}
+
+	jwk, err := publicJWK(private.Public())
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		ID:        thumbprint(jwk),
+		Algorithm: alg,
+		private:   private,
+		public:    private.Public(),
+	}, nil
+}
+
+func publicJWK(public any) (JWK, error) {
+	enc := base64.RawURLEncoding
+
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   enc.EncodeToString(key.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   enc.EncodeToString(key.X.FillBytes(make([]byte, size))),
+			Y:   enc.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(key),
+		}, nil
+	}
+
+	return JWK{}, ErrUnsupportedKey
+}
+
+// thumbprint computes the RFC 7638 SHA-256 thumbprint of a public JWK.
+func thumbprint(jwk JWK) string {
+	var members map[string]string
+
+	switch jwk.Kty {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	case "EC":
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X, "y": jwk.Y}
+	default:
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+
+	// encoding/json sorts map keys, which gives the required canonical form.
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,171 @@
+// Package passhash hashes passwords into self-describing strings in the PHC
+// string format, e.g. "$argon2id$v=19$m=65536,t=3,p=4$<salt>$<hash>". bcrypt
+// hashes keep their usual "$2a$<cost>$..." encoding, which follows the same
+// "$<id>$" layout, so hashes stored before the package was used are accepted.
+package passhash
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+// Supported algorithms.
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+)
+
+var (
+	ErrMismatchedPassword = errors.New("password does not match the hash")
+	ErrUnknownAlgorithm   = errors.New("unknown password hash algorithm")
+	ErrMalformedHash      = errors.New("malformed password hash")
+)
+
+// Argon2idParams are the cost parameters of argon2id (RFC 9106).
+type Argon2idParams struct {
+	// Time is the number of passes over the memory.
+	Time uint32
+	// Memory is the memory size in KiB.
+	Memory     uint32
+	Threads    uint8
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// Hasher hashes passwords with one algorithm and verifies the hashes of every
+// supported algorithm, so that the algorithm can be changed at any time.
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2id   Argon2idParams
+}
+
+// New returns a Hasher hashing new passwords with the given algorithm. Only
+// the parameters of that algorithm are checked.
+func New(algorithm string, bcryptCost int, argon2id Argon2idParams) (*Hasher, error) {
+	switch algorithm {
+	case Bcrypt:
+		if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	case Argon2id:
+		if argon2id.Time == 0 || argon2id.Memory == 0 || argon2id.Threads == 0 ||
+			argon2id.SaltLength == 0 || argon2id.KeyLength == 0 {
+			return nil, errors.New("argon2id parameters must not be zero")
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, algorithm)
+	}
+
+	return &Hasher{algorithm: algorithm, bcryptCost: bcryptCost, argon2id: argon2id}, nil
+}
+
+// Hash hashes the password with a random salt.
+func (h *Hasher) Hash(password []byte) ([]byte, error) {
+	if h.algorithm == Bcrypt {
+		return bcrypt.GenerateFromPassword(password, h.bcryptCost)
+	}
+
+	salt := make([]byte, h.argon2id.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encodeArgon2id(h.argon2id, salt, argon2idKey(password, salt, h.argon2id)), nil
+}
+
+// Compare returns nil if the password matches the hash and
+// ErrMismatchedPassword if it does not.
+func (h *Hasher) Compare(hash, password []byte) error {
+	switch algorithmOf(hash) {
+	case Bcrypt:
+		err := bcrypt.CompareHashAndPassword(hash, password)
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrMismatchedPassword
+		}
+		return err
+	case Argon2id:
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(key, argon2idKey(password, salt, params)) != 1 {
+			return ErrMismatchedPassword
+		}
+		return nil
+	default:
+		return ErrUnknownAlgorithm
+	}
+}
+
+// NeedsRehash reports whether the hash was made with another algorithm or
+// other parameters than the ones new passwords are hashed with.
+func (h *Hasher) NeedsRehash(hash []byte) bool {
+	if algorithmOf(hash) != h.algorithm {
+		return true
+	}
+
+	if h.algorithm == Bcrypt {
+		cost, err := bcrypt.Cost(hash)
+		return err != nil || cost != h.bcryptCost
+	}
+
+	params, _, _, err := decodeArgon2id(hash)
+	return err != nil || params != h.argon2id
+}
+
+func argon2idKey(password, salt []byte, params Argon2idParams) []byte {
+	return argon2.IDKey(password, salt, params.Time, params.Memory, params.Threads, params.KeyLength)
+}
+
+func algorithmOf(hash []byte) string {
+	switch {
+	case bytes.HasPrefix(hash, []byte("$2a$")), bytes.HasPrefix(hash, []byte("$2b$")), bytes.HasPrefix(hash, []byte("$2y$")):
+		return Bcrypt
+	case bytes.HasPrefix(hash, []byte("$"+Argon2id+"$")):
+		return Argon2id
+	default:
+		return ""
+	}
+}
+
+var encoding = base64.RawStdEncoding
+
+func encodeArgon2id(params Argon2idParams, salt, key []byte) []byte {
+	return []byte(fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		Argon2id, argon2.Version, params.Memory, params.Time, params.Threads,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key)))
+}
+
+func decodeArgon2id(hash []byte) (params Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(string(hash), "$")
+	if len(parts) != 6 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || params.Memory == 0 || params.Time == 0 || params.Threads == 0 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+
+	if salt, err = encoding.DecodeString(parts[4]); err != nil || len(salt) == 0 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	if key, err = encoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return Argon2idParams{}, nil, nil, ErrMalformedHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestAccount(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	register := func(t *testing.T) (email, password string) {
+		email = gofakeit.Email()
+		password = fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		return email, password
+	}
+
+	login := func(t *testing.T, email, password string) *authv1.LoginResponse {
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		return respLogin
+	}
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		email, password := register(t)
+		current := login(t, email, password)
+		other := login(t, email, password)
+		newPassword := fakePassword()
+
+		_, err := st.AuthClient.ChangePassword(withBearer(ctx, current.GetToken()), &authv1.ChangePasswordRequest{
+			CurrentPassword: fakePassword(),
+			NewPassword:     newPassword,
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, err.Error(), "Invalid credentials")
+
+		_, err = st.AuthClient.ChangePassword(withBearer(ctx, current.GetToken()), &authv1.ChangePasswordRequest{
+			CurrentPassword: password,
+			NewPassword:     newPassword,
+		})
+		require.NoError(t, err)
+
+		respCurrent, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: current.GetToken()})
+		require.NoError(t, err)
+		assert.True(t, respCurrent.GetValid())
+
+		respOther, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: other.GetToken()})
+		require.NoError(t, err)
+		assert.False(t, respOther.GetValid())
+
+		_, err = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: other.GetRefreshToken()})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		login(t, email, newPassword)
+	})
+
+	t.Run("ChangeEmail", func(t *testing.T) {
+		email, password := register(t)
+		newEmail := gofakeit.Email()
+
+		_, err := st.AuthClient.ChangeEmail(withBearer(ctx, login(t, email, password).GetToken()), &authv1.ChangeEmailRequest{
+			Password: password,
+			NewEmail: newEmail,
+		})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: login(t, newEmail, password).GetToken()})
+		require.NoError(t, err)
+		assert.Equal(t, newEmail, resp.GetClaims().GetEmail())
+		assert.False(t, resp.GetClaims().GetEmailVerified())
+	})
+
+	t.Run("ChangeEmailTaken", func(t *testing.T) {
+		email, password := register(t)
+		takenEmail, _ := register(t)
+
+		_, err := st.AuthClient.ChangeEmail(withBearer(ctx, login(t, email, password).GetToken()), &authv1.ChangeEmailRequest{
+			Password: password,
+			NewEmail: takenEmail,
+		})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("ChangeEmailWrongPassword", func(t *testing.T) {
+		email, password := register(t)
+
+		_, err := st.AuthClient.ChangeEmail(withBearer(ctx, login(t, email, password).GetToken()), &authv1.ChangeEmailRequest{
+			Password: fakePassword(),
+			NewEmail: gofakeit.Email(),
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		_, err := st.AuthClient.ChangePassword(ctx, &authv1.ChangePasswordRequest{
+			CurrentPassword: fakePassword(),
+			NewPassword:     fakePassword(),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = st.AuthClient.ChangeEmail(ctx, &authv1.ChangeEmailRequest{
+			Password: fakePassword(),
+			NewEmail: gofakeit.Email(),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"context"
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestApps(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	respAdmin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+		Email:    adminEmail,
+		Password: adminPassword,
+		AppId:    appId,
+	})
+	require.NoError(t, err)
+	adminCtx := withBearer(ctx, respAdmin.GetToken())
+
+	createApp := func(t *testing.T) *authv1.CreateAppResponse {
+		resp, err := st.AppClient.CreateApp(adminCtx, &authv1.CreateAppRequest{
+			Name: "app-" + gofakeit.UUID(),
+			Settings: &authv1.AppSettings{
+				Audiences:    []string{"https://api.example.com"},
+				RedirectUris: []string{"https://example.com/callback", "com.example.app:/callback"},
+			},
+		})
+		require.NoError(t, err)
+
+		return resp
+	}
+
+	registerAndLogin := func(t *testing.T, ctx context.Context, appID int32) (email, password string, resp *authv1.LoginResponse) {
+		email = gofakeit.Email()
+		password = fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appID})
+		require.NoError(t, err)
+		resp, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appID})
+		require.NoError(t, err)
+
+		return email, password, resp
+	}
+
+	t.Run("CreateGetList", func(t *testing.T) {
+		created := createApp(t)
+		assert.NotEmpty(t, created.GetSecret())
+		assert.NotZero(t, created.GetApp().GetId())
+		assert.False(t, created.GetApp().GetDisabled())
+
+		policy := created.GetApp().GetSettings().GetPasswordPolicy()
+		assert.EqualValues(t, 8, policy.GetMinLength())
+		assert.True(t, policy.GetCheckBreached())
+
+		respGet, err := st.AppClient.GetApp(adminCtx, &authv1.GetAppRequest{AppId: created.GetApp().GetId()})
+		require.NoError(t, err)
+		assert.Equal(t, created.GetApp().GetName(), respGet.GetApp().GetName())
+		assert.Equal(t, []string{"https://api.example.com"}, respGet.GetApp().GetSettings().GetAudiences())
+		assert.ElementsMatch(t, []string{"https://example.com/callback", "com.example.app:/callback"},
+			respGet.GetApp().GetSettings().GetRedirectUris())
+
+		respList, err := st.AppClient.ListApps(adminCtx, &authv1.ListAppsRequest{})
+		require.NoError(t, err)
+		var ids []int32
+		for _, app := range respList.GetApps() {
+			ids = append(ids, app.GetId())
+		}
+		assert.Contains(t, ids, created.GetApp().GetId())
+		assert.Contains(t, ids, int32(appId))
+
+		registerAndLogin(t, ctx, created.GetApp().GetId())
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		created := createApp(t)
+		name := "app-" + gofakeit.UUID()
+
+		respUpdate, err := st.AppClient.UpdateApp(adminCtx, &authv1.UpdateAppRequest{
+			AppId: created.GetApp().GetId(),
+			Name:  name,
+			Settings: &authv1.AppSettings{
+				RequireVerifiedEmail: true,
+				PasswordPolicy:       &authv1.PasswordPolicy{MinLength: 16, MaxLength: 64, MinClasses: 2},
+				RedirectUris:         []string{"https://example.org/callback"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, name, respUpdate.GetApp().GetName())
+		assert.True(t, respUpdate.GetApp().GetSettings().GetRequireVerifiedEmail())
+		assert.EqualValues(t, 16, respUpdate.GetApp().GetSettings().GetPasswordPolicy().GetMinLength())
+		assert.Empty(t, respUpdate.GetApp().GetSettings().GetAudiences())
+		assert.Equal(t, []string{"https://example.org/callback"}, respUpdate.GetApp().GetSettings().GetRedirectUris())
+
+		_, err = st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    gofakeit.Email(),
+			Password: "Short1pass",
+			AppId:    created.GetApp().GetId(),
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assertReason(t, err, "WEAK_PASSWORD")
+
+		_, err = st.AppClient.UpdateApp(adminCtx, &authv1.UpdateAppRequest{
+			AppId: created.GetApp().GetId(),
+			Name:  "app1",
+		})
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+		assertReason(t, err, "APP_EXISTS")
+	})
+
+	t.Run("RotateSecret", func(t *testing.T) {
+		created := createApp(t)
+		_, _, respLogin := registerAndLogin(t, ctx, created.GetApp().GetId())
+
+		_, err := st.AppClient.RotateAppSecret(adminCtx, &authv1.RotateAppSecretRequest{
+			AppId: created.GetApp().GetId(),
+		})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assertReason(t, err, "APP_SECRET_IN_USE")
+
+		respValidate, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respLogin.GetToken()})
+		require.NoError(t, err)
+		assert.True(t, respValidate.GetValid(), "a refused rotation keeps the secret")
+
+		_, err = st.AdminClient.RotateSigningKey(adminCtx, &authv1.RotateSigningKeyRequest{
+			AppId:          created.GetApp().GetId(),
+			RevokePrevious: true,
+		})
+		require.NoError(t, err)
+
+		respRotate, err := st.AppClient.RotateAppSecret(adminCtx, &authv1.RotateAppSecretRequest{
+			AppId: created.GetApp().GetId(),
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, respRotate.GetSecret())
+		assert.NotEqual(t, created.GetSecret(), respRotate.GetSecret())
+	})
+
+	t.Run("DisableAndEnable", func(t *testing.T) {
+		created := createApp(t)
+		appID := created.GetApp().GetId()
+		email, password, respLogin := registerAndLogin(t, ctx, appID)
+
+		_, err := st.AppClient.SetAppDisabled(adminCtx, &authv1.SetAppDisabledRequest{AppId: appID, Disabled: true})
+		require.NoError(t, err)
+
+		respValidate, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respLogin.GetToken()})
+		require.NoError(t, err)
+		assert.False(t, respValidate.GetValid())
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appID})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assertReason(t, err, "APP_DISABLED")
+
+		_, err = st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: gofakeit.Email(), Password: fakePassword(), AppId: appID})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assertReason(t, err, "APP_DISABLED")
+
+		respGet, err := st.AppClient.GetApp(adminCtx, &authv1.GetAppRequest{AppId: appID})
+		require.NoError(t, err)
+		assert.True(t, respGet.GetApp().GetDisabled())
+
+		_, err = st.AppClient.SetAppDisabled(adminCtx, &authv1.SetAppDisabledRequest{AppId: appID, Disabled: false})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appID})
+		require.NoError(t, err)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		inUse := createApp(t)
+		registerAndLogin(t, ctx, inUse.GetApp().GetId())
+
+		_, err := st.AppClient.DeleteApp(adminCtx, &authv1.DeleteAppRequest{AppId: inUse.GetApp().GetId()})
+		require.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assertReason(t, err, "APP_IN_USE")
+
+		unused := createApp(t)
+
+		_, err = st.AppClient.DeleteApp(adminCtx, &authv1.DeleteAppRequest{AppId: unused.GetApp().GetId()})
+		require.NoError(t, err)
+
+		_, err = st.AppClient.GetApp(adminCtx, &authv1.GetAppRequest{AppId: unused.GetApp().GetId()})
+		require.Equal(t, codes.NotFound, status.Code(err))
+		assertReason(t, err, "APP_NOT_FOUND")
+	})
+
+	t.Run("InvalidSettings", func(t *testing.T) {
+		_, err := st.AppClient.CreateApp(adminCtx, &authv1.CreateAppRequest{
+			Name: "app-" + gofakeit.UUID(),
+			Settings: &authv1.AppSettings{
+				RedirectUris: []string{"/relative"},
+			},
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, []string{"must contain absolute URIs without a fragment"},
+			fieldViolations(t, err, "settings.redirect_uris"))
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		_, _, respLogin := registerAndLogin(t, ctx, appId)
+
+		_, err := st.AppClient.ListApps(withBearer(ctx, respLogin.GetToken()), &authv1.ListAppsRequest{})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		assertReason(t, err, "ADMIN_REQUIRED")
+	})
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	password := fakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appId})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+	require.NoError(t, err)
+
+	_, err = st.AuthClient.DeleteAccount(withBearer(ctx, respLogin.GetToken()), &authv1.DeleteAccountRequest{
+		Password: fakePassword(),
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = st.AuthClient.DeleteAccount(withBearer(ctx, respLogin.GetToken()), &authv1.DeleteAccountRequest{
+		Password: password,
+	})
+	require.NoError(t, err)
+
+	respValidate, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respLogin.GetToken()})
+	require.NoError(t, err)
+	assert.False(t, respValidate.GetValid())
+
+	_, err = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: respLogin.GetRefreshToken()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// The email stays reserved until the account is purged.
+	_, err = st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appId})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+}
+
+func TestExportMyData(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	email := gofakeit.Email()
+	password := fakePassword()
+
+	_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appId})
+	require.NoError(t, err)
+
+	respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+	require.NoError(t, err)
+
+	respExport, err := st.AuthClient.ExportMyData(withBearer(ctx, respLogin.GetToken()), &authv1.ExportMyDataRequest{})
+	require.NoError(t, err)
+
+	var export struct {
+		Profile struct {
+			Email string `json:"email"`
+		} `json:"profile"`
+		Sessions []struct {
+			AppID int32 `json:"app_id"`
+		} `json:"sessions"`
+		MFA *struct{} `json:"mfa"`
+	}
+	require.NoError(t, json.Unmarshal(respExport.GetData(), &export))
+
+	assert.Equal(t, email, export.Profile.Email)
+	require.Len(t, export.Sessions, 1)
+	assert.Equal(t, int32(appId), export.Sessions[0].AppID)
+	assert.Nil(t, export.MFA)
+	assert.NotContains(t, string(respExport.GetData()), password)
+}
+
+func TestDeleteExportMissingToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	_, err := st.AuthClient.DeleteAccount(ctx, &authv1.DeleteAccountRequest{Password: fakePassword()})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = st.AuthClient.ExportMyData(ctx, &authv1.ExportMyDataRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
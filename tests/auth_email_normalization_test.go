@@ -0,0 +1,99 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+func TestEmailNormalization(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	t.Run("CaseAndWhitespace", func(t *testing.T) {
+		local := strings.ToLower(gofakeit.Username())
+		email := local + "@example.com"
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    "  " + strings.ToUpper(local) + "@Example.COM ",
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    local + "@EXAMPLE.com",
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+		assertReason(t, err, "USER_EXISTS")
+
+		_, err = st.AuthClient.RequestPasswordReset(ctx, &authv1.RequestPasswordResetRequest{
+			Email: " " + email,
+			AppId: appId,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("InvalidOnRegister", func(t *testing.T) {
+		for _, email := range []string{
+			"no-at-sign",
+			"two@@example.com",
+			"dots..in@example.com",
+			"Display Name <name@example.com>",
+			`"quoted"@example.com`,
+			"name@localhost",
+			"name@-example.com",
+			"name@[127.0.0.1]",
+			"name@bücher.example",
+			strings.Repeat("a", 65) + "@example.com",
+		} {
+			_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+				Email:    email,
+				Password: fakePassword(),
+				AppId:    appId,
+			})
+			require.Equal(t, codes.InvalidArgument, status.Code(err), email)
+			assertReason(t, err, "INVALID_EMAIL")
+			assert.Equal(t, []string{"is not a valid email address"}, fieldViolations(t, err, "email"), email)
+		}
+	})
+
+	t.Run("InvalidOnLogin", func(t *testing.T) {
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    "no-at-sign",
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assertReason(t, err, "INVALID_CREDENTIALS")
+	})
+
+	t.Run("InvalidOnChangeEmail", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.ChangeEmail(withBearer(ctx, respLogin.GetToken()), &authv1.ChangeEmailRequest{
+			Password: password,
+			NewEmail: "no-at-sign",
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assertReason(t, err, "INVALID_EMAIL")
+		assert.Equal(t, []string{"is not a valid email address"}, fieldViolations(t, err, "new_email"))
+	})
+}
@@ -0,0 +1,60 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestEmailVerification(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	register := func(t *testing.T, appID int32) (email, password string) {
+		email = gofakeit.Email()
+		password = fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appID,
+		})
+		require.NoError(t, err)
+
+		return email, password
+	}
+
+	t.Run("UnverifiedClaim", func(t *testing.T) {
+		email, password := register(t, appId)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respLogin.GetToken()})
+		require.NoError(t, err)
+		assert.True(t, resp.GetValid())
+		assert.False(t, resp.GetClaims().GetEmailVerified())
+	})
+
+	t.Run("AppRequiresVerifiedEmail", func(t *testing.T) {
+		email, password := register(t, verifiedAppId)
+
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: verifiedAppId})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assert.Contains(t, err.Error(), "Email not verified")
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		_, err := st.AuthClient.VerifyEmail(ctx, &authv1.VerifyEmailRequest{Token: gofakeit.UUID()})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, err.Error(), "Invalid verification token")
+
+		_, err = st.AuthClient.VerifyEmail(ctx, &authv1.VerifyEmailRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, err.Error(), "Invalid argument")
+	})
+}
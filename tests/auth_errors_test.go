@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+const (
+	errorDomain  = "go-grpc-auth"
+	unknownAppId = 9999
+)
+
+func TestErrorDetails(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	t.Run("UserExists", func(t *testing.T) {
+		email := gofakeit.Email()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+		assertReason(t, err, "USER_EXISTS")
+	})
+
+	t.Run("AppNotFound", func(t *testing.T) {
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    gofakeit.Email(),
+			Password: fakePassword(),
+			AppId:    unknownAppId,
+		})
+		require.Equal(t, codes.NotFound, status.Code(err))
+		assertReason(t, err, "APP_NOT_FOUND")
+	})
+
+	t.Run("InvalidCredentials", func(t *testing.T) {
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    gofakeit.Email(),
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assertReason(t, err, "INVALID_CREDENTIALS")
+	})
+
+	t.Run("FieldViolations", func(t *testing.T) {
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assertReason(t, err, "INVALID_ARGUMENT")
+
+		badRequest := errorDetail[*errdetails.BadRequest](t, err)
+		var fields []string
+		for _, violation := range badRequest.GetFieldViolations() {
+			fields = append(fields, violation.GetField())
+			assert.Equal(t, "is required", violation.GetDescription())
+		}
+		assert.ElementsMatch(t, []string{"email", "password", "app_id"}, fields)
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		_, err := st.AdminClient.CreateRole(ctx, &authv1.CreateRoleRequest{Name: gofakeit.UUID()})
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+		assertReason(t, err, "MISSING_TOKEN")
+	})
+
+	t.Run("AdminRequired", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.CreateRole(withBearer(ctx, respLogin.GetToken()), &authv1.CreateRoleRequest{
+			Name: gofakeit.UUID(),
+		})
+		require.Equal(t, codes.PermissionDenied, status.Code(err))
+		assertReason(t, err, "ADMIN_REQUIRED")
+	})
+}
+
+func assertReason(t *testing.T, err error, reason string) {
+	t.Helper()
+
+	info := errorDetail[*errdetails.ErrorInfo](t, err)
+	assert.Equal(t, errorDomain, info.GetDomain())
+	assert.Equal(t, reason, info.GetReason())
+}
+
+// errorDetail returns the first detail of type T attached to a status error.
+func errorDetail[T any](t *testing.T, err error) T {
+	t.Helper()
+
+	st, _ := status.FromError(err)
+	for _, detail := range st.Details() {
+		if d, ok := detail.(T); ok {
+			return d
+		}
+	}
+
+	var zero T
+	require.Failf(t, "missing error detail", "%T not found in %v", zero, st.Details())
+	return zero
+}
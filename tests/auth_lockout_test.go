@@ -0,0 +1,291 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/memory"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// backoffAfter is login_throttle.account.backoff_after of the test config.
+const backoffAfter = 3
+
+func TestLoginLockout(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	failLogins := func(t *testing.T, email string) {
+		for i := 0; i < backoffAfter; i++ {
+			_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+				Email:    email,
+				Password: fakePassword(),
+				AppId:    appId,
+			})
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		}
+	}
+
+	requireBlocked := func(t *testing.T, email, password string) {
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+		retryInfo := errorDetail[*errdetails.RetryInfo](t, err)
+		assert.Positive(t, retryInfo.GetRetryDelay().AsDuration())
+	}
+
+	t.Run("BackoffAndUnlock", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		// Failures are counted per account whatever the case of the email.
+		respReg, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    strings.ToUpper(email),
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		failLogins(t, email)
+		requireBlocked(t, email, password)
+
+		respAdmin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    adminEmail,
+			Password: adminPassword,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.UnlockAccount(withBearer(ctx, respAdmin.GetToken()), &authv1.UnlockAccountRequest{
+			UserId: respReg.GetUserId(),
+		})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("UnknownEmail", func(t *testing.T) {
+		email := gofakeit.Email()
+
+		failLogins(t, email)
+		requireBlocked(t, email, fakePassword())
+	})
+
+	t.Run("PasswordChecks", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+		userCtx := withBearer(ctx, respLogin.GetToken())
+
+		// Guesses with a stolen access token count as failed logins.
+		for i := 0; i < backoffAfter; i++ {
+			_, err := st.AuthClient.DeleteAccount(userCtx, &authv1.DeleteAccountRequest{Password: fakePassword()})
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+		}
+
+		_, err = st.AuthClient.ChangeEmail(userCtx, &authv1.ChangeEmailRequest{
+			Password: password,
+			NewEmail: gofakeit.Email(),
+		})
+		require.Equal(t, codes.ResourceExhausted, status.Code(err))
+		requireBlocked(t, email, password)
+	})
+
+	t.Run("UnlockRequiresAdmin", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		respReg, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.UnlockAccount(withBearer(ctx, respLogin.GetToken()), &authv1.UnlockAccountRequest{
+			UserId: respReg.GetUserId(),
+		})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
+
+// TestLoginLockoutParallel checks that guesses sent at once are throttled as
+// if they were sent one after another. It runs the service on the memory
+// storage to count the password comparisons.
+func TestLoginLockoutParallel(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	const lockoutAfter, guesses, ip = 5, 20, "192.0.2.1"
+	throttle := config.ThrottleConfig{
+		BackoffAfter:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        time.Minute,
+		LockoutAfter:    lockoutAfter,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+	cfg := &config.Config{
+		TokenTTL:      time.Hour,
+		Refresh:       config.RefreshConfig{TTL: time.Hour, Rotation: config.RotationStrict},
+		Signing:       config.SigningConfig{Algorithm: jwt.AlgHS256},
+		Revocation:    config.RevocationConfig{CacheSize: 10, CacheTTL: time.Minute},
+		LoginThrottle: config.LoginThrottleConfig{Account: throttle, IP: config.ThrottleConfig{LockoutAfter: 1000, Window: time.Hour}},
+	}
+	s := memory.New()
+	hasher := &countingHasher{}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := auth.New(log, s, cfg, nil, hasher, nil, nil)
+
+	appID, err := s.CreateApp(ctx, models.App{
+		Name:           "app-" + gofakeit.UUID(),
+		Secret:         gofakeit.UUID(),
+		PasswordPolicy: models.DefaultPasswordPolicy,
+	}, models.AuditEvent{Action: models.AuditAppCreated})
+	require.NoError(t, err)
+	saveUser := func(email, password string) {
+		_, err := s.SaveUser(ctx, email, strings.ToLower(email), []byte(password), int32(appID))
+		require.NoError(t, err)
+	}
+
+	email := gofakeit.Email()
+	saveUser(email, fakePassword())
+
+	var wg sync.WaitGroup
+	var blocked atomic.Int32
+	for range guesses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := a.Login(ctx, email, fakePassword(), int32(appID), ip)
+			if errors.Is(err, auth.ErrTooManyAttempts) {
+				blocked.Add(1)
+				return
+			}
+			assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, hasher.compares.Load(), int32(lockoutAfter))
+	assert.Equal(t, guesses-hasher.compares.Load(), blocked.Load())
+
+	// A successful login from the address takes its attempt back.
+	other, password := gofakeit.Email(), fakePassword()
+	saveUser(other, password)
+	_, err = a.Login(ctx, other, password, int32(appID), ip)
+	require.NoError(t, err)
+	failures, err := s.LoginFailures(ctx, models.LoginScopeIP, ip)
+	require.NoError(t, err)
+	assert.Equal(t, guesses, failures.Failures)
+}
+
+// TestUnlockAccountStoredEmail checks that an account is unlocked under the
+// normalized email it was stored with, even if the email normalizes
+// differently now.
+func TestUnlockAccountStoredEmail(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	cfg := &config.Config{
+		TokenTTL:   time.Hour,
+		Signing:    config.SigningConfig{Algorithm: jwt.AlgHS256},
+		Revocation: config.RevocationConfig{CacheSize: 10, CacheTTL: time.Minute},
+	}
+	s := memory.New()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := auth.New(log, s, cfg, nil, &countingHasher{}, nil, nil)
+
+	appID, err := s.CreateApp(ctx, models.App{
+		Name:           "app-" + gofakeit.UUID(),
+		Secret:         gofakeit.UUID(),
+		PasswordPolicy: models.DefaultPasswordPolicy,
+	}, models.AuditEvent{Action: models.AuditAppCreated})
+	require.NoError(t, err)
+
+	email := gofakeit.Email()
+	stored := "legacy:" + strings.ToLower(email)
+	userID, err := s.SaveUser(ctx, email, stored, []byte(fakePassword()), int32(appID))
+	require.NoError(t, err)
+
+	key := models.AccountLoginKey(int32(appID), stored)
+	_, err = s.AddLoginFailure(ctx, models.LoginScopeAccount, key, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	require.NoError(t, s.BlockLogin(ctx, models.LoginScopeAccount, key, time.Now().Add(time.Hour)))
+
+	require.NoError(t, a.UnlockAccount(ctx, 0, userID))
+
+	failures, err := s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Zero(t, failures.Failures)
+	assert.True(t, failures.BlockedUntil.IsZero())
+}
+
+// countingHasher stores passwords as they are and counts the comparisons,
+// which take a while, as those of real hashes do.
+type countingHasher struct {
+	compares atomic.Int32
+}
+
+func (h *countingHasher) Hash(password []byte) ([]byte, error) {
+	return bytes.Clone(password), nil
+}
+
+func (h *countingHasher) Compare(hash []byte, password []byte) error {
+	h.compares.Add(1)
+	time.Sleep(50 * time.Millisecond)
+	if !bytes.Equal(hash, password) {
+		return errors.New("password mismatch")
+	}
+	return nil
+}
+
+func (h *countingHasher) NeedsRehash(hash []byte) bool {
+	return false
+}
@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"context"
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestLogout(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	register := func(t *testing.T) (email, password string) {
+		email = gofakeit.Email()
+		password = fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		return email, password
+	}
+
+	login := func(t *testing.T, email, password string) *authv1.LoginResponse {
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		return respLogin
+	}
+
+	valid := func(t *testing.T, token string) bool {
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: token})
+		require.NoError(t, err)
+
+		return resp.GetValid()
+	}
+
+	t.Run("CurrentSession", func(t *testing.T) {
+		email, password := register(t)
+		current := login(t, email, password)
+		other := login(t, email, password)
+
+		_, err := st.AuthClient.Logout(withBearer(ctx, current.GetToken()), &authv1.LogoutRequest{})
+		require.NoError(t, err)
+
+		assert.False(t, valid(t, current.GetToken()))
+		assert.True(t, valid(t, other.GetToken()))
+
+		_, err = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: current.GetRefreshToken()})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("AllSessions", func(t *testing.T) {
+		email, password := register(t)
+		current := login(t, email, password)
+		other := login(t, email, password)
+
+		_, err := st.AuthClient.LogoutAll(withBearer(ctx, current.GetToken()), &authv1.LogoutAllRequest{})
+		require.NoError(t, err)
+
+		assert.False(t, valid(t, current.GetToken()))
+		assert.False(t, valid(t, other.GetToken()))
+
+		_, err = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{RefreshToken: other.GetRefreshToken()})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("MissingToken", func(t *testing.T) {
+		_, err := st.AuthClient.Logout(ctx, &authv1.LogoutRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		_, err := st.AuthClient.LogoutAll(withBearer(ctx, gofakeit.LetterN(40)), &authv1.LogoutAllRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func withBearer(ctx context.Context, token string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
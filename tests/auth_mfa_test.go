@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/pkg/totp"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMFA(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	login := func(t *testing.T, email, password string) *authv1.LoginResponse {
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		return respLogin
+	}
+
+	// enroll registers a user with MFA enabled and returns its TOTP secret,
+	// recovery codes and the step of the code used for confirmation.
+	enroll := func(t *testing.T) (email, password, secret string, recoveryCodes []string, step int64) {
+		email = gofakeit.Email()
+		password = fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		authCtx := withBearer(ctx, login(t, email, password).GetToken())
+
+		respEnroll, err := st.AuthClient.EnrollMFA(authCtx, &authv1.EnrollMFARequest{})
+		require.NoError(t, err)
+		require.NotEmpty(t, respEnroll.GetSecret())
+		assert.True(t, strings.HasPrefix(respEnroll.GetUri(), "otpauth://totp/"))
+		assert.Contains(t, respEnroll.GetUri(), "secret="+respEnroll.GetSecret())
+
+		_, err = st.AuthClient.ConfirmMFA(authCtx, &authv1.ConfirmMFARequest{Code: "000000x"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		step = totp.Step(time.Now())
+		code, err := totp.Code(respEnroll.GetSecret(), step)
+		require.NoError(t, err)
+
+		respConfirm, err := st.AuthClient.ConfirmMFA(authCtx, &authv1.ConfirmMFARequest{Code: code})
+		require.NoError(t, err)
+		require.NotEmpty(t, respConfirm.GetRecoveryCodes())
+
+		_, err = st.AuthClient.EnrollMFA(authCtx, &authv1.EnrollMFARequest{})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		return email, password, respEnroll.GetSecret(), respConfirm.GetRecoveryCodes(), step
+	}
+
+	t.Run("TOTP", func(t *testing.T) {
+		email, password, secret, _, step := enroll(t)
+
+		respLogin := login(t, email, password)
+		assert.True(t, respLogin.GetMfaRequired())
+		assert.Empty(t, respLogin.GetToken())
+		assert.Empty(t, respLogin.GetRefreshToken())
+		require.NotEmpty(t, respLogin.GetMfaToken())
+
+		// The code used for confirmation cannot be used again, but the next one is
+		// accepted as clocks may drift by one period.
+		used, err := totp.Code(secret, step)
+		require.NoError(t, err)
+		_, err = st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{MfaToken: respLogin.GetMfaToken(), Code: used})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		next, err := totp.Code(secret, step+1)
+		require.NoError(t, err)
+		respVerify, err := st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{MfaToken: respLogin.GetMfaToken(), Code: next})
+		require.NoError(t, err)
+		require.NotEmpty(t, respVerify.GetToken())
+		require.NotEmpty(t, respVerify.GetRefreshToken())
+
+		respValidate, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respVerify.GetToken()})
+		require.NoError(t, err)
+		assert.True(t, respValidate.GetValid())
+		assert.Equal(t, email, respValidate.GetClaims().GetEmail())
+
+		_, err = st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{MfaToken: respLogin.GetMfaToken(), Code: next})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("RecoveryCode", func(t *testing.T) {
+		email, password, _, recoveryCodes, _ := enroll(t)
+
+		respLogin := login(t, email, password)
+		respVerify, err := st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{
+			MfaToken:     respLogin.GetMfaToken(),
+			RecoveryCode: strings.ToUpper(recoveryCodes[0]),
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, respVerify.GetToken())
+
+		respLogin = login(t, email, password)
+		_, err = st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{
+			MfaToken:     respLogin.GetMfaToken(),
+			RecoveryCode: recoveryCodes[0],
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{
+			MfaToken:     respLogin.GetMfaToken(),
+			RecoveryCode: recoveryCodes[1],
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("TooManyAttempts", func(t *testing.T) {
+		email, password, secret, _, step := enroll(t)
+
+		respLogin := login(t, email, password)
+		for i := 0; i < st.Cfg.MFA.MaxAttempts; i++ {
+			_, err := st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{
+				MfaToken:     respLogin.GetMfaToken(),
+				RecoveryCode: gofakeit.UUID(),
+			})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		}
+
+		code, err := totp.Code(secret, step+1)
+		require.NoError(t, err)
+		_, err = st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{MfaToken: respLogin.GetMfaToken(), Code: code})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		_, err := st.AuthClient.VerifyMFA(ctx, &authv1.VerifyMFARequest{MfaToken: gofakeit.UUID(), Code: "123456"})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = st.AuthClient.EnrollMFA(ctx, &authv1.EnrollMFARequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,40 @@
+package tests
+
+import (
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// The user with a bcrypt password hash is seeded by tests/migrations.
+const (
+	legacyEmail    = "legacy@example.com"
+	legacyPassword = "legacy-password"
+)
+
+func TestLegacyPasswordHash(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	// The first login verifies the bcrypt hash and replaces it, the second
+	// one verifies the new hash.
+	for i := 0; i < 2; i++ {
+		resp, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    legacyEmail,
+			Password: legacyPassword,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, resp.GetToken())
+	}
+
+	_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+		Email:    legacyEmail,
+		Password: fakePassword(),
+		AppId:    appId,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	tests := []struct {
+		name       string
+		appID      int32
+		email      string
+		password   string
+		violations []string
+	}{
+		{
+			name:       "TooShort",
+			appID:      appId,
+			email:      gofakeit.Email(),
+			password:   "Ab1!",
+			violations: []string{"must be at least 8 characters long"},
+		},
+		{
+			name:       "TooLong",
+			appID:      appId,
+			email:      gofakeit.Email(),
+			password:   strings.Repeat("Ab1!", 19),
+			violations: []string{"must be at most 72 bytes long"},
+		},
+		{
+			name:       "ContainsEmail",
+			appID:      appId,
+			email:      "policy.owner@example.com",
+			password:   "Policy.Owner-2024",
+			violations: []string{"must not contain the email"},
+		},
+		{
+			name:       "Breached",
+			appID:      appId,
+			email:      gofakeit.Email(),
+			password:   "password1",
+			violations: []string{"has appeared in a data breach"},
+		},
+		{
+			name:     "StrictApp",
+			appID:    strictPasswordAppId,
+			email:    gofakeit.Email(),
+			password: "lowercase",
+			violations: []string{
+				"must be at least 12 characters long",
+				"must mix at least 3 of lowercase letters, uppercase letters, digits and symbols",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+				Email:    tt.email,
+				Password: tt.password,
+				AppId:    tt.appID,
+			})
+			require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+			assert.Equal(t, tt.violations, fieldViolations(t, err, "password"))
+		})
+	}
+
+	t.Run("StrictAppAcceptsStrongPassword", func(t *testing.T) {
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    gofakeit.Email(),
+			Password: "Correct-Horse-Battery-9",
+			AppId:    strictPasswordAppId,
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("ChangePassword", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.ChangePassword(withBearer(ctx, respLogin.GetToken()), &authv1.ChangePasswordRequest{
+			CurrentPassword: password,
+			NewPassword:     "qwerty123",
+		})
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Equal(t, []string{"has appeared in a data breach"}, fieldViolations(t, err, "new_password"))
+	})
+}
+
+// fieldViolations returns the descriptions of the violations of the field in
+// the BadRequest details of the error.
+func fieldViolations(t *testing.T, err error, field string) []string {
+	t.Helper()
+
+	st, _ := status.FromError(err)
+
+	var violations []string
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+
+		for _, violation := range badRequest.GetFieldViolations() {
+			assert.Equal(t, field, violation.GetField())
+			violations = append(violations, violation.GetDescription())
+		}
+	}
+	return violations
+}
@@ -0,0 +1,56 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestPasswordReset(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	t.Run("SameResponseForUnknownEmail", func(t *testing.T) {
+		email := gofakeit.Email()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respKnown, err := st.AuthClient.RequestPasswordReset(ctx, &authv1.RequestPasswordResetRequest{
+			Email: email,
+			AppId: appId,
+		})
+		require.NoError(t, err)
+
+		respUnknown, err := st.AuthClient.RequestPasswordReset(ctx, &authv1.RequestPasswordResetRequest{
+			Email: gofakeit.Email(),
+			AppId: appId,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, respKnown.String(), respUnknown.String())
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		_, err := st.AuthClient.ResetPassword(ctx, &authv1.ResetPasswordRequest{
+			Token:       gofakeit.UUID(),
+			NewPassword: fakePassword(),
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, err.Error(), "Invalid reset token")
+	})
+
+	t.Run("EmptyPassword", func(t *testing.T) {
+		_, err := st.AuthClient.ResetPassword(ctx, &authv1.ResetPasswordRequest{Token: gofakeit.UUID()})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		assert.Contains(t, err.Error(), "Invalid argument")
+	})
+}
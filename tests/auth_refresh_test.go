@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+func TestRefresh(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	login := func(t *testing.T) *authv1.LoginResponse {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, respLogin.GetRefreshToken())
+
+		return respLogin
+	}
+
+	t.Run("HappyPath", func(t *testing.T) {
+		respLogin := login(t)
+
+		respRefresh, err := st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{
+			RefreshToken: respLogin.GetRefreshToken(),
+		})
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, respRefresh.GetToken())
+		assert.NotEmpty(t, respRefresh.GetRefreshToken())
+		assert.NotEqual(t, respLogin.GetRefreshToken(), respRefresh.GetRefreshToken())
+	})
+
+	t.Run("ReuseRevokesFamily", func(t *testing.T) {
+		respLogin := login(t)
+
+		respRefresh, err := st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{
+			RefreshToken: respLogin.GetRefreshToken(),
+		})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{
+			RefreshToken: respLogin.GetRefreshToken(),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		_, err = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{
+			RefreshToken: respRefresh.GetRefreshToken(),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("UnknownToken", func(t *testing.T) {
+		_, err := st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{
+			RefreshToken: gofakeit.UUID(),
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("BlankToken", func(t *testing.T) {
+		_, err := st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
@@ -3,8 +3,8 @@ package tests
 import (
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/golang-jwt/jwt/v5"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
 	"github.com/qu0ta/go-grpc-auth/tests/suite"
-	authv1 "github.com/qu0ta/pet-proto/gen/go/auth"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -12,9 +12,14 @@ import (
 )
 
 const (
-	appId          = 1
-	appSecret      = "secret1"
-	passDefaultLen = 10
+	appId       = 1
+	appSecret   = "secret1"
+	secondAppId = 2
+	// verifiedAppId refuses logins of users with an unverified email.
+	verifiedAppId = 3
+	// strictPasswordAppId requires passwords of at least 12 characters mixing 3 character classes.
+	strictPasswordAppId = 4
+	passDefaultLen      = 10
 )
 
 func TestRegisterLogin_Login_HappyPath(t *testing.T) {
@@ -36,6 +41,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
 			Email:    email,
 			Password: password,
+			AppId:    appId,
 		})
 
 		loginTime := time.Now()
@@ -74,6 +80,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{
 			Email:    email,
 			Password: "wrong-password",
+			AppId:    appId,
 		})
 
 		assert.Error(t, err)
@@ -84,6 +91,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
 			Email:    gofakeit.Email(),
 			Password: fakePassword(),
+			AppId:    appId,
 		})
 
 		assert.Error(t, err)
@@ -103,6 +111,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
 			Email:    "",
 			Password: fakePassword(),
+			AppId:    appId,
 		})
 
 		assert.Error(t, err)
@@ -122,6 +131,7 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
 			Email:    gofakeit.Email(),
 			Password: "",
+			AppId:    appId,
 		})
 
 		assert.Error(t, err)
@@ -147,6 +157,74 @@ func TestRegisterLogin_Login_HappyPath(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("SameEmailInTwoApps", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+		otherPassword := fakePassword()
+
+		respFirst, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respSecond, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: otherPassword,
+			AppId:    secondAppId,
+		})
+		require.NoError(t, err)
+		assert.NotEqual(t, respFirst.GetUserId(), respSecond.GetUserId())
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: otherPassword,
+			AppId:    secondAppId,
+		})
+		require.NoError(t, err)
+
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respLogin.GetToken()})
+		require.NoError(t, err)
+		assert.Equal(t, respSecond.GetUserId(), resp.GetClaims().GetUserId())
+		assert.Equal(t, int32(secondAppId), resp.GetClaims().GetAppId())
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    secondAppId,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("UnregisteredApp", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		_, err = st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    secondAppId,
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("BlankAppLogin", func(t *testing.T) {
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    gofakeit.Email(),
+			Password: fakePassword(),
+		})
+
+		assert.Error(t, err)
+	})
+
 }
 func fakePassword() string {
 	return gofakeit.Password(true, true, true, true, false, passDefaultLen)
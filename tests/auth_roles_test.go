@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+// The admin user is seeded by tests/migrations.
+const (
+	adminEmail    = "admin@example.com"
+	adminPassword = "admin-password"
+)
+
+func TestRoles(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	respAdmin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+		Email:    adminEmail,
+		Password: adminPassword,
+		AppId:    appId,
+	})
+	require.NoError(t, err)
+	adminCtx := withBearer(ctx, respAdmin.GetToken())
+
+	register := func(t *testing.T) (userID int64, email, password string) {
+		email = gofakeit.Email()
+		password = fakePassword()
+
+		respReg, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		return respReg.GetUserId(), email, password
+	}
+
+	t.Run("AdminRoleInClaims", func(t *testing.T) {
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respAdmin.GetToken()})
+		require.NoError(t, err)
+		assert.Contains(t, resp.GetClaims().GetRoles(), "admin")
+
+		respIsAdmin, err := st.AuthClient.IsAdmin(ctx, &authv1.IsAdminRequest{UserId: resp.GetClaims().GetUserId()})
+		require.NoError(t, err)
+		assert.True(t, respIsAdmin.GetIsAdmin())
+	})
+
+	t.Run("AssignAndUnassign", func(t *testing.T) {
+		role := "editor-" + gofakeit.UUID()
+		_, err := st.AdminClient.CreateRole(adminCtx, &authv1.CreateRoleRequest{
+			Name:        role,
+			Permissions: []string{"posts:read", "posts:write"},
+		})
+		require.NoError(t, err)
+
+		userID, email, password := register(t)
+
+		hasPermission := func(t *testing.T, appID int32, permission string) bool {
+			resp, err := st.AuthClient.HasPermission(ctx, &authv1.HasPermissionRequest{
+				UserId:     userID,
+				AppId:      appID,
+				Permission: permission,
+			})
+			require.NoError(t, err)
+
+			return resp.GetHasPermission()
+		}
+
+		assert.False(t, hasPermission(t, appId, "posts:write"))
+
+		_, err = st.AdminClient.AssignRole(adminCtx, &authv1.AssignRoleRequest{UserId: userID, AppId: appId, Role: role})
+		require.NoError(t, err)
+
+		assert.True(t, hasPermission(t, appId, "posts:write"))
+		assert.False(t, hasPermission(t, appId, "posts:delete"))
+		assert.False(t, hasPermission(t, secondAppId, "posts:write"))
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+		respValidate, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: respLogin.GetToken()})
+		require.NoError(t, err)
+		assert.Equal(t, []string{role}, respValidate.GetClaims().GetRoles())
+
+		respIsAdmin, err := st.AuthClient.IsAdmin(ctx, &authv1.IsAdminRequest{UserId: userID})
+		require.NoError(t, err)
+		assert.False(t, respIsAdmin.GetIsAdmin())
+
+		_, err = st.AdminClient.UnassignRole(adminCtx, &authv1.UnassignRoleRequest{UserId: userID, AppId: appId, Role: role})
+		require.NoError(t, err)
+
+		assert.False(t, hasPermission(t, appId, "posts:write"))
+	})
+
+	t.Run("DuplicateRole", func(t *testing.T) {
+		role := "viewer-" + gofakeit.UUID()
+		_, err := st.AdminClient.CreateRole(adminCtx, &authv1.CreateRoleRequest{Name: role})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.CreateRole(adminCtx, &authv1.CreateRoleRequest{Name: role})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("UnknownRole", func(t *testing.T) {
+		userID, _, _ := register(t)
+
+		_, err := st.AdminClient.AssignRole(adminCtx, &authv1.AssignRoleRequest{
+			UserId: userID,
+			AppId:  appId,
+			Role:   gofakeit.UUID(),
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("SetAdmin", func(t *testing.T) {
+		userID, email, password := register(t)
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+		userCtx := withBearer(ctx, respLogin.GetToken())
+
+		_, err = st.AdminClient.SetAdmin(adminCtx, &authv1.SetAdminRequest{UserId: userID, AppId: appId, IsAdmin: true})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.CreateRole(userCtx, &authv1.CreateRoleRequest{Name: gofakeit.UUID()})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.SetAdmin(adminCtx, &authv1.SetAdminRequest{UserId: userID, AppId: appId, IsAdmin: false})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.CreateRole(userCtx, &authv1.CreateRoleRequest{Name: gofakeit.UUID()})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("AppAdmin", func(t *testing.T) {
+		registerIn := func(t *testing.T, appID int32) (userID int64, email, password string) {
+			email, password = gofakeit.Email(), fakePassword()
+			respReg, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{Email: email, Password: password, AppId: appID})
+			require.NoError(t, err)
+			return respReg.GetUserId(), email, password
+		}
+
+		userID, email, password := registerIn(t, secondAppId)
+		_, err := st.AdminClient.SetAdmin(adminCtx, &authv1.SetAdminRequest{UserId: userID, AppId: appId, IsAdmin: true})
+		assert.Equal(t, codes.NotFound, status.Code(err), "users only hold roles in their own app")
+		_, err = st.AdminClient.SetAdmin(adminCtx, &authv1.SetAdminRequest{UserId: userID, AppId: secondAppId, IsAdmin: true})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: secondAppId})
+		require.NoError(t, err)
+		appAdminCtx := withBearer(ctx, respLogin.GetToken())
+
+		otherID, _, _ := registerIn(t, secondAppId)
+		_, err = st.AdminClient.SetAdmin(appAdminCtx, &authv1.SetAdminRequest{UserId: otherID, AppId: secondAppId, IsAdmin: true})
+		require.NoError(t, err)
+		_, err = st.AdminClient.UnlockAccount(appAdminCtx, &authv1.UnlockAccountRequest{UserId: otherID})
+		require.NoError(t, err)
+		_, err = st.AppClient.GetApp(appAdminCtx, &authv1.GetAppRequest{AppId: secondAppId})
+		require.NoError(t, err)
+
+		firstID, _, _ := register(t)
+		for name, call := range map[string]func() error{
+			"SetAdmin": func() error {
+				_, err := st.AdminClient.SetAdmin(appAdminCtx, &authv1.SetAdminRequest{UserId: firstID, AppId: appId, IsAdmin: true})
+				return err
+			},
+			"UnlockAccount": func() error {
+				_, err := st.AdminClient.UnlockAccount(appAdminCtx, &authv1.UnlockAccountRequest{UserId: firstID})
+				return err
+			},
+			"RotateSigningKey": func() error {
+				_, err := st.AdminClient.RotateSigningKey(appAdminCtx, &authv1.RotateSigningKeyRequest{AppId: appId})
+				return err
+			},
+			"GetApp": func() error {
+				_, err := st.AppClient.GetApp(appAdminCtx, &authv1.GetAppRequest{AppId: appId})
+				return err
+			},
+			"SetAppDisabled": func() error {
+				_, err := st.AppClient.SetAppDisabled(appAdminCtx, &authv1.SetAppDisabledRequest{AppId: appId, Disabled: true})
+				return err
+			},
+			"ListApps": func() error {
+				_, err := st.AppClient.ListApps(appAdminCtx, &authv1.ListAppsRequest{})
+				return err
+			},
+			"CreateRole": func() error {
+				_, err := st.AdminClient.CreateRole(appAdminCtx, &authv1.CreateRoleRequest{Name: gofakeit.UUID()})
+				return err
+			},
+		} {
+			assert.Equal(t, codes.PermissionDenied, status.Code(call()), name)
+		}
+	})
+
+	t.Run("SetAdminWithoutToken", func(t *testing.T) {
+		userID, _, _ := register(t)
+
+		_, err := st.AdminClient.SetAdmin(ctx, &authv1.SetAdminRequest{UserId: userID, AppId: appId, IsAdmin: true})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("NotAdmin", func(t *testing.T) {
+		_, email, password := register(t)
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{Email: email, Password: password, AppId: appId})
+		require.NoError(t, err)
+
+		_, err = st.AdminClient.CreateRole(withBearer(ctx, respLogin.GetToken()), &authv1.CreateRoleRequest{
+			Name: gofakeit.UUID(),
+		})
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+}
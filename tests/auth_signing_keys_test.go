@@ -0,0 +1,183 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/qu0ta/go-grpc-auth/internal/config"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/memory"
+	"github.com/qu0ta/go-grpc-auth/pkg/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// TestKeylessTokens checks which tokens without a kid, signed with the app
+// secret, the service accepts. It runs the service on the memory storage, as
+// the algorithm and the key file cannot be changed on the running server.
+func TestKeylessTokens(t *testing.T) {
+	t.Parallel()
+
+	t.Run("HS256", func(t *testing.T) {
+		a, s := newSigningAuth(t, jwt.AlgHS256, nil)
+		app, token := keylessToken(t, s)
+
+		_, err := a.ValidateToken(context.Background(), token)
+		assert.NoError(t, err)
+
+		err = s.SaveSigningKey(context.Background(), models.SigningKey{
+			ID:         gofakeit.UUID(),
+			AppID:      int32(app.ID),
+			Algorithm:  jwt.AlgHS256,
+			PrivateKey: []byte(gofakeit.UUID()),
+			Status:     models.KeyStatusActive,
+		})
+		require.NoError(t, err)
+		_, err = a.ValidateToken(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, "the app signs with stored keys")
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		a, s := newSigningAuth(t, jwt.AlgHS256, nil)
+		app, token := keylessToken(t, s)
+
+		_, err := a.RotateSigningKey(context.Background(), 0, int32(app.ID), 0, false)
+		require.NoError(t, err)
+		_, err = a.ValidateToken(context.Background(), token)
+		assert.NoError(t, err, "the secret verifies until the overlap is over")
+
+		_, err = a.RotateSigningKey(context.Background(), 0, int32(app.ID), 0, true)
+		require.NoError(t, err)
+		_, err = a.ValidateToken(context.Background(), token)
+		assert.NoError(t, err, "only the first rotation retires the secret")
+
+		a, s = newSigningAuth(t, jwt.AlgHS256, nil)
+		app, token = keylessToken(t, s)
+
+		_, err = a.RotateSigningKey(context.Background(), 0, int32(app.ID), 0, true)
+		require.NoError(t, err)
+		_, err = a.ValidateToken(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("SecretKeyID", func(t *testing.T) {
+		a, s := newSigningAuth(t, jwt.AlgHS256, nil)
+		app, token := keylessToken(t, s)
+		_, err := a.RotateSigningKey(context.Background(), 0, int32(app.ID), 0, false)
+		require.NoError(t, err)
+
+		claims, err := a.ValidateToken(context.Background(), token)
+		require.NoError(t, err)
+		user := models.User{ID: claims.UserID, Email: claims.Email, AppID: int32(app.ID)}
+		forged, err := jwt.NewToken(user, app, claims.SessionID, nil, time.Hour,
+			jwt.NewHMACKey(fmt.Sprintf("app-secret-%d", app.ID), []byte{}))
+		require.NoError(t, err)
+
+		_, err = a.ValidateToken(context.Background(), forged)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, "the ring member of the secret holds no key")
+	})
+
+	t.Run("RotateSecret", func(t *testing.T) {
+		a, s := newSigningAuth(t, jwt.AlgHS256, nil)
+		app, token := keylessToken(t, s)
+
+		_, err := a.RotateAppSecret(context.Background(), 0, int32(app.ID))
+		assert.ErrorIs(t, err, auth.ErrAppSecretInUse, "the secret signs tokens")
+
+		_, err = a.RotateSigningKey(context.Background(), 0, int32(app.ID), 0, false)
+		require.NoError(t, err)
+		_, err = a.RotateAppSecret(context.Background(), 0, int32(app.ID))
+		assert.ErrorIs(t, err, auth.ErrAppSecretInUse, "the secret verifies until the overlap is over")
+		_, err = a.ValidateToken(context.Background(), token)
+		assert.NoError(t, err)
+
+		a, s = newSigningAuth(t, jwt.AlgHS256, nil)
+		app, _ = keylessToken(t, s)
+		_, err = a.RotateSigningKey(context.Background(), 0, int32(app.ID), 0, true)
+		require.NoError(t, err)
+		secret, err := a.RotateAppSecret(context.Background(), 0, int32(app.ID))
+		require.NoError(t, err)
+		assert.NotEqual(t, app.Secret, secret)
+
+		a, s = newSigningAuth(t, jwt.AlgRS256, nil)
+		app, _ = keylessToken(t, s)
+		_, err = a.RotateAppSecret(context.Background(), 0, int32(app.ID))
+		assert.NoError(t, err, "the secret signs nothing under RS256")
+	})
+
+	t.Run("RS256", func(t *testing.T) {
+		a, s := newSigningAuth(t, jwt.AlgRS256, nil)
+		_, token := keylessToken(t, s)
+
+		_, err := a.ValidateToken(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+
+	t.Run("KeyFile", func(t *testing.T) {
+		global, err := jwt.GenerateKey(jwt.AlgHS256)
+		require.NoError(t, err)
+		a, s := newSigningAuth(t, jwt.AlgHS256, &global)
+		_, token := keylessToken(t, s)
+
+		_, err = a.ValidateToken(context.Background(), token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken)
+	})
+}
+
+// newSigningAuth returns the service signing with the algorithm, or with the
+// global key if not nil, and its storage.
+func newSigningAuth(t *testing.T, algorithm string, global *jwt.Key) (*auth.Auth, *memory.Storage) {
+	t.Helper()
+
+	cfg := &config.Config{
+		TokenTTL: time.Hour,
+		Signing: config.SigningConfig{
+			Algorithm:       algorithm,
+			RotationOverlap: time.Hour,
+		},
+		Revocation: config.RevocationConfig{CacheSize: 10, CacheTTL: time.Minute},
+	}
+	s := memory.New()
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return auth.New(log, s, cfg, global, nil, nil, nil), s
+}
+
+// keylessToken creates an app with a user and a session and returns the app
+// and a token of the session signed with the app secret, without a kid.
+func keylessToken(t *testing.T, s *memory.Storage) (models.App, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	app := models.App{
+		Name:           "app-" + gofakeit.UUID(),
+		Secret:         gofakeit.UUID(),
+		PasswordPolicy: models.DefaultPasswordPolicy,
+	}
+	id, err := s.CreateApp(ctx, app, models.AuditEvent{Action: models.AuditAppCreated})
+	require.NoError(t, err)
+	app.ID = id
+
+	email := gofakeit.Email()
+	uid, err := s.SaveUser(ctx, email, email, []byte("hash"), int32(app.ID))
+	require.NoError(t, err)
+
+	family := gofakeit.UUID()
+	_, err = s.SaveSession(ctx, models.Session{
+		UserID:    uid,
+		AppID:     int32(app.ID),
+		Family:    family,
+		TokenHash: []byte(gofakeit.UUID()),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	user := models.User{ID: uid, Email: email, AppID: int32(app.ID)}
+	token, err := jwt.NewToken(user, app, family, nil, time.Hour, jwt.HMACKey(app.Secret))
+	require.NoError(t, err)
+	return app, token
+}
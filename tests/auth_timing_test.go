@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"context"
+	"github.com/brianvoe/gofakeit/v6"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"slices"
+	"testing"
+	"time"
+)
+
+// timingSamples is the number of requests timed for registered and for
+// unknown emails each. Every login sample counts as a failed login of the
+// client address, so it stays well below login_throttle.ip.backoff_after.
+const timingSamples = 10
+
+// maxTimingRatio bounds how many times slower the median response for one
+// kind of email may be than for the other. Without uniform handling the
+// ratio is in the tens, as only one of them hashes a password.
+const maxTimingRatio = 3
+
+func TestLoginTiming(t *testing.T) {
+	_, st := suite.New(t)
+
+	// The samples take longer than the timeout of a single request.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	registered := make([]string, timingSamples)
+	for i := range registered {
+		registered[i] = gofakeit.Email()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    registered[i],
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+	}
+
+	login := func(email string) time.Duration {
+		start := time.Now()
+		_, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		elapsed := time.Since(start)
+
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+		return elapsed
+	}
+
+	var known, unknown []time.Duration
+	// Interleaved, so that the load of tests running in parallel affects both alike.
+	for _, email := range registered {
+		known = append(known, login(email))
+		unknown = append(unknown, login(gofakeit.Email()))
+	}
+
+	assertSimilarTiming(t, known, unknown)
+}
+
+func TestRegisterTiming(t *testing.T) {
+	_, st := suite.New(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	register := func(email string) (time.Duration, error) {
+		start := time.Now()
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: fakePassword(),
+			AppId:    appId,
+		})
+		return time.Since(start), err
+	}
+
+	var taken, free []time.Duration
+	for i := 0; i < timingSamples; i++ {
+		email := gofakeit.Email()
+
+		elapsed, err := register(email)
+		require.NoError(t, err)
+		free = append(free, elapsed)
+
+		elapsed, err = register(email)
+		require.Equal(t, codes.AlreadyExists, status.Code(err))
+		taken = append(taken, elapsed)
+	}
+
+	assertSimilarTiming(t, taken, free)
+}
+
+func assertSimilarTiming(t *testing.T, a, b []time.Duration) {
+	t.Helper()
+
+	medianA, medianB := median(a), median(b)
+	ratio := float64(medianA) / float64(medianB)
+
+	assert.Less(t, ratio, float64(maxTimingRatio), "medians %s and %s", medianA, medianB)
+	assert.Greater(t, ratio, 1/float64(maxTimingRatio), "medians %s and %s", medianA, medianB)
+}
+
+func median(durations []time.Duration) time.Duration {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-jwt/jwt/v5"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
+	"github.com/qu0ta/go-grpc-auth/tests/suite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+func TestValidateToken(t *testing.T) {
+	ctx, st := suite.New(t)
+
+	t.Run("HappyPath", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		respReg, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{
+			Token: respLogin.GetToken(),
+		})
+		require.NoError(t, err)
+
+		assert.True(t, resp.GetValid())
+		assert.Equal(t, respReg.GetUserId(), resp.GetClaims().GetUserId())
+		assert.Equal(t, email, resp.GetClaims().GetEmail())
+		assert.Equal(t, int32(appId), resp.GetClaims().GetAppId())
+		assert.NotEmpty(t, resp.GetClaims().GetSessionId())
+	})
+
+	t.Run("RevokedSession", func(t *testing.T) {
+		email := gofakeit.Email()
+		password := fakePassword()
+
+		_, err := st.AuthClient.Register(ctx, &authv1.RegisterRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		respLogin, err := st.AuthClient.Login(ctx, &authv1.LoginRequest{
+			Email:    email,
+			Password: password,
+			AppId:    appId,
+		})
+		require.NoError(t, err)
+
+		// Reusing a rotated refresh token revokes the whole session.
+		for range 2 {
+			_, _ = st.AuthClient.Refresh(ctx, &authv1.RefreshRequest{
+				RefreshToken: respLogin.GetRefreshToken(),
+			})
+		}
+
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{
+			Token: respLogin.GetToken(),
+		})
+		require.NoError(t, err)
+		assert.False(t, resp.GetValid())
+	})
+
+	t.Run("WrongSecret", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"uid":    1,
+			"app_id": appId,
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		})
+		tokenString, err := token.SignedString([]byte("not-" + appSecret))
+		require.NoError(t, err)
+
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{
+			Token: tokenString,
+		})
+		require.NoError(t, err)
+		assert.False(t, resp.GetValid())
+	})
+
+	t.Run("Garbage", func(t *testing.T) {
+		resp, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{
+			Token: gofakeit.LetterN(40),
+		})
+		require.NoError(t, err)
+		assert.False(t, resp.GetValid())
+	})
+
+	t.Run("BlankToken", func(t *testing.T) {
+		_, err := st.AuthClient.ValidateToken(ctx, &authv1.ValidateTokenRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+}
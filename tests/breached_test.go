@@ -0,0 +1,37 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/qu0ta/go-grpc-auth/pkg/breached"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBreachedRanges(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("password1"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	content := "0018A45C4D1DEF81644B54AB7F969B88D65:1\r\n" + strings.ToLower(hash[5:]) + ":2427\r\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte(content), 0o600))
+
+	ranges, err := breached.OpenRanges(dir)
+	require.NoError(t, err)
+
+	found, err := ranges.Contains("password1")
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = ranges.Contains("not breached, probably")
+	require.NoError(t, err)
+	assert.False(t, found, "the range file of the prefix is missing")
+
+	_, err = breached.OpenRanges(filepath.Join(dir, hash[:5]+".txt"))
+	assert.Error(t, err)
+}
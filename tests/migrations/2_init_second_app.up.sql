@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret)
+VALUES (2, 'app2', 'secret2')
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,9 @@
+INSERT INTO users (id, email, email_normalized, pass_hash, app_id)
+VALUES (1000, 'admin@example.com', 'admin@example.com', CAST('$2a$10$L58NBvr3Qa4Rsf9SVP4NYuxzcpwnvhCy.1H0.MqpyDZ0rcctZYtfS' AS BLOB), 1)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, app_id, role_id)
+SELECT 1000, 1, id
+FROM roles
+WHERE name = 'admin'
+ON CONFLICT DO NOTHING;
\ No newline at end of file
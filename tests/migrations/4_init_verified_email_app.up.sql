@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, require_verified_email)
+VALUES (3, 'app3', 'secret3', TRUE)
+ON CONFLICT DO NOTHING;
\ No newline at end of file
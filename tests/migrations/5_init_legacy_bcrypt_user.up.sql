@@ -0,0 +1,3 @@
+INSERT INTO users (id, email, email_normalized, pass_hash, app_id)
+VALUES (1001, 'legacy@example.com', 'legacy@example.com', CAST('$2a$10$Itdf/0SNz2Hbv2TYN4nZrONMVrwg1AfIn3WH4x2LIwdVrOSZFihwG' AS BLOB), 1)
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,3 @@
+INSERT INTO apps (id, name, secret, password_min_length, password_min_classes)
+VALUES (4, 'app4', 'secret4', 12, 3)
+ON CONFLICT DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,24 @@
+-- The data of the sqlite test migrations. Explicit ids leave the identity
+-- sequences behind, so they are moved past the seeded rows.
+INSERT INTO apps (id, name, secret, require_verified_email, password_min_length, password_min_classes)
+VALUES (1, 'app1', 'secret1', FALSE, 8, 1),
+       (2, 'app2', 'secret2', FALSE, 8, 1),
+       (3, 'app3', 'secret3', TRUE, 8, 1),
+       (4, 'app4', 'secret4', FALSE, 12, 3)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO users (id, email, email_normalized, pass_hash, app_id)
+VALUES (1000, 'admin@example.com', 'admin@example.com',
+        CAST('$2a$10$L58NBvr3Qa4Rsf9SVP4NYuxzcpwnvhCy.1H0.MqpyDZ0rcctZYtfS' AS BYTEA), 1),
+       (1001, 'legacy@example.com', 'legacy@example.com',
+        CAST('$2a$10$Itdf/0SNz2Hbv2TYN4nZrONMVrwg1AfIn3WH4x2LIwdVrOSZFihwG' AS BYTEA), 1)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO user_roles (user_id, app_id, role_id)
+SELECT 1000, 1, id
+FROM roles
+WHERE name = 'admin'
+ON CONFLICT DO NOTHING;
+
+SELECT setval(pg_get_serial_sequence('apps', 'id'), (SELECT MAX(id) FROM apps));
+SELECT setval(pg_get_serial_sequence('users', 'id'), (SELECT MAX(id) FROM users));
\ No newline at end of file
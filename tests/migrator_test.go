@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrator(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator := newMigrator(t, path)
+
+	stdout, _, code := migrator("version")
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "no migrations applied\n", stdout)
+
+	stdout, _, code = migrator("--dry-run")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "-- 1_init.up.sql\nCREATE TABLE")
+	stdout, _, _ = migrator("version")
+	assert.Equal(t, "no migrations applied\n", stdout, "a dry run applies nothing")
+
+	stdout, _, code = migrator("up")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Successfully migrated")
+
+	stdout, _, code = migrator("up")
+	assert.Equal(t, 0, code, "no change is not a failure")
+	assert.Equal(t, "No migrations to apply\n", stdout)
+
+	stdout, _, code = migrator("down", "1", "--dry-run")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, ".down.sql")
+	assert.NotContains(t, stdout, ".up.sql")
+
+	_, _, code = migrator("down", "2")
+	assert.Equal(t, 0, code)
+	stdout, _, _ = migrator("status")
+	assert.Contains(t, stdout, "2 pending")
+
+	_, _, code = migrator("goto", "3")
+	assert.Equal(t, 0, code)
+	stdout, _, _ = migrator("version")
+	assert.Equal(t, "version 3\n", stdout)
+
+	_, stderr, code := migrator("down", "4")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "3 applied")
+	stdout, _, _ = migrator("version")
+	assert.Equal(t, "version 3\n", stdout, "nothing is reverted if not all can be")
+
+	_, _, code = migrator("goto", "999")
+	assert.Equal(t, 1, code)
+
+	setDirty(t, path)
+	_, stderr, code = migrator("up")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "dirty")
+	stdout, _, _ = migrator("status")
+	assert.Contains(t, stdout, "version 3 (dirty)")
+
+	_, _, code = migrator("force", "3")
+	assert.Equal(t, 0, code)
+	stdout, _, code = migrator("up")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "Successfully migrated")
+	stdout, _, _ = migrator("status")
+	assert.Contains(t, stdout, "0 pending")
+
+	for _, args := range [][]string{{"down"}, {"down", "0"}, {"goto"}, {"bogus"}, {"version", "1"}, {"force", "1", "--dry-run"}, {"--bogus"}} {
+		_, _, code = migrator(args...)
+		assert.Equal(t, 2, code, args)
+	}
+}
+
+func TestMigratorNormalizesEmails(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "auth.db")
+	migrator := newMigrator(t, path)
+
+	_, _, code := migrator("goto", "16")
+	require.Equal(t, 0, code)
+	stdout, _, code := migrator("--dry-run")
+	assert.Equal(t, 0, code)
+	assert.Contains(t, stdout, "-- before 17_email_normalized_not_null.up.sql: normalize the emails of existing users")
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec(`INSERT INTO apps (id, name, secret) VALUES (1, 'first', 'secret1'), (2, 'second', 'secret2');
+		INSERT INTO users (id, email, pass_hash, app_id) VALUES
+			(1, 'Foo@Example.COM', 'hash', 1),
+			(2, 'foo@example.com', 'hash', 1),
+			(3, 'foo@example.com', 'hash', 2),
+			(4, 'not an email', 'hash', 1),
+			(5, 'Bar@Example.com', 'hash', 2)`)
+	require.NoError(t, err)
+
+	_, stderr, code := migrator("up")
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "users 1, 2 of app 1 share the email foo@example.com")
+	assert.Contains(t, stderr, `user 4 has an invalid email "not an email"`)
+	stdout, _, _ = migrator("version")
+	assert.Equal(t, "version 16\n", stdout, "nothing is migrated before the emails are fixed")
+
+	_, err = db.Exec("DELETE FROM users WHERE id IN (2, 4)")
+	require.NoError(t, err)
+	_, _, code = migrator("up")
+	assert.Equal(t, 0, code)
+
+	normalized := make(map[int64]string)
+	rows, err := db.Query("SELECT id, email_normalized FROM users")
+	require.NoError(t, err)
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var email string
+		require.NoError(t, rows.Scan(&id, &email))
+		normalized[id] = email
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, map[int64]string{1: "foo@example.com", 3: "foo@example.com", 5: "bar@example.com"}, normalized)
+
+	_, err = db.Exec("INSERT INTO users (email, pass_hash, app_id) VALUES ('baz@example.com', 'hash', 1)")
+	assert.Error(t, err, "every user has a normalized email")
+}
+
+// newMigrator builds the migrator and returns a function running it against
+// the database at path with the migrations of the service.
+func newMigrator(t *testing.T, path string) func(args ...string) (stdout string, stderr string, code int) {
+	t.Helper()
+
+	bin := filepath.Join(t.TempDir(), "migrator")
+	out, err := exec.Command("go", "build", "-o", bin, "../cmd/migrator").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	return func(args ...string) (stdout string, stderr string, code int) {
+		t.Helper()
+
+		var outBuf, errBuf bytes.Buffer
+		cmd := exec.Command(bin, append([]string{"--storage-path=" + path, "--migrations-path=../migrations"}, args...)...)
+		cmd.Stdout, cmd.Stderr = &outBuf, &errBuf
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return outBuf.String(), errBuf.String(), exitErr.ExitCode()
+		}
+		require.NoError(t, err)
+		return outBuf.String(), errBuf.String(), 0
+	}
+}
+
+// setDirty marks the version of the database at path as dirty, as a failed
+// migration leaves it.
+func setDirty(t *testing.T, path string) {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("UPDATE migrations SET dirty = TRUE")
+	require.NoError(t, err)
+}
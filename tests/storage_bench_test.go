@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/sqlite"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// BenchmarkSQLiteUser compares user lookups through the statements prepared
+// by sqlite.New with the same query prepared anew on every call.
+func BenchmarkSQLiteUser(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "auth.db")
+	s := openSQLiteStorage(b, path, sqliteOptions)
+	appID, email := seedSQLiteUser(b, s)
+
+	b.Run("Prepared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := s.User(context.Background(), appID, email); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("PreparedParallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, err := s.User(context.Background(), appID, email); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	require.NoError(b, err)
+	defer db.Close()
+	db.SetMaxOpenConns(sqliteOptions.MaxOpenConns)
+	db.SetMaxIdleConns(sqliteOptions.MaxIdleConns)
+
+	user := func() error {
+		var u models.User
+		return db.QueryRowContext(context.Background(), `SELECT id, email, pass_hash, app_id, email_verified FROM users
+			WHERE app_id = ? AND email_normalized = ? AND deleted_at IS NULL`, appID, email).
+			Scan(&u.ID, &u.Email, &u.PasswordHash, &u.AppID, &u.EmailVerified)
+	}
+	b.Run("Unprepared", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if err := user(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("UnpreparedParallel", func(b *testing.B) {
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if err := user(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkSQLiteSaveSession compares session writes with the defaults of
+// SQLite and with the settings of config/prod.yml.
+func BenchmarkSQLiteSaveSession(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		opts sqlite.Options
+	}{
+		{"Defaults", sqlite.Options{NoForeignKeys: true}},
+		{"Tuned", sqliteOptions},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			s := openSQLiteStorage(b, filepath.Join(b.TempDir(), "auth.db"), bc.opts)
+			appID, email := seedSQLiteUser(b, s)
+			user, err := s.User(context.Background(), appID, email)
+			require.NoError(b, err)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := s.SaveSession(context.Background(), models.Session{
+					UserID:    user.ID,
+					AppID:     appID,
+					Family:    gofakeit.UUID(),
+					TokenHash: []byte(gofakeit.UUID()),
+					ExpiresAt: time.Now().Add(time.Hour),
+				})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// seedSQLiteUser creates an app with a user and returns the app id and the
+// normalized email of the user.
+func seedSQLiteUser(b *testing.B, s *sqlite.Storage) (int32, string) {
+	b.Helper()
+
+	appID, err := s.CreateApp(context.Background(), models.App{
+		Name:           "app-" + gofakeit.UUID(),
+		Secret:         gofakeit.UUID(),
+		PasswordPolicy: models.DefaultPasswordPolicy,
+	}, models.AuditEvent{Action: models.AuditAppCreated})
+	require.NoError(b, err)
+
+	email := strings.ToLower(gofakeit.Email())
+	_, err = s.SaveUser(context.Background(), email, email, []byte("hash"), int32(appID))
+	require.NoError(b, err)
+	return int32(appID), email
+}
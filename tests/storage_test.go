@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"database/sql"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/memory"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/postgres"
+	"github.com/qu0ta/go-grpc-auth/internal/storage/sqlite"
+	"github.com/qu0ta/go-grpc-auth/tests/storagetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// postgresURLEnv names the variable holding the postgres:// URL of a database
+// the storage tests may create schemas in. The postgres tests are skipped
+// without it.
+const postgresURLEnv = "TEST_POSTGRES_URL"
+
+func TestStorage(t *testing.T) {
+	t.Parallel()
+
+	t.Run("SQLite", func(t *testing.T) {
+		storagetest.Run(t, newSQLiteStorage)
+	})
+	t.Run("Postgres", func(t *testing.T) {
+		storagetest.Run(t, newPostgresStorage)
+	})
+	t.Run("Memory", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) auth.Storage { return memory.New() })
+	})
+}
+
+// sqliteOptions are the settings of config/prod.yml.
+var sqliteOptions = sqlite.Options{
+	JournalMode:  "WAL",
+	BusyTimeout:  5 * time.Second,
+	Synchronous:  "NORMAL",
+	MaxOpenConns: 10,
+	MaxIdleConns: 10,
+}
+
+// newSQLiteStorage migrates a fresh database down and up again and opens it.
+func newSQLiteStorage(t *testing.T) auth.Storage {
+	t.Helper()
+
+	return openSQLiteStorage(t, filepath.Join(t.TempDir(), "auth.db"), sqliteOptions)
+}
+
+// openSQLiteStorage migrates the database at path down and up again and opens
+// it with opts. The storage is closed when the test ends.
+func openSQLiteStorage(tb testing.TB, path string, opts sqlite.Options) *sqlite.Storage {
+	tb.Helper()
+
+	migrateDownAndUp(tb, "../migrations", "sqlite3://"+path)
+
+	s, err := sqlite.New(path, opts)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { assert.NoError(tb, s.Close()) })
+	return s
+}
+
+// newPostgresStorage migrates a fresh schema down and up again and opens it.
+// The schema is dropped when the test ends.
+func newPostgresStorage(t *testing.T) auth.Storage {
+	t.Helper()
+
+	dsn := os.Getenv(postgresURLEnv)
+	if dsn == "" {
+		t.Skip(postgresURLEnv + " is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	schema := "test_" + strings.ReplaceAll(gofakeit.UUID(), "-", "")
+	_, err = db.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, err := db.Exec("DROP SCHEMA " + schema + " CASCADE")
+		assert.NoError(t, err)
+	})
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+
+	migrateURL := *u
+	migrateURL.Scheme = "pgx5"
+	migrateDownAndUp(t, "../migrations/postgres", migrateURL.String())
+
+	s, err := postgres.New(u.String())
+	require.NoError(t, err)
+	t.Cleanup(func() { assert.NoError(t, s.Close()) })
+	return s
+}
+
+// migrateDownAndUp applies the migrations, reverts them all and applies them again.
+func migrateDownAndUp(t testing.TB, migrationsPath string, databaseURL string) {
+	t.Helper()
+
+	m, err := migrate.New("file://"+migrationsPath, databaseURL)
+	require.NoError(t, err)
+	defer m.Close()
+
+	require.NoError(t, m.Up())
+	require.NoError(t, m.Down())
+	require.NoError(t, m.Up())
+}
@@ -0,0 +1,982 @@
+// Package storagetest is the conformance suite of the storage backends. Every
+// implementation of auth.Storage must pass it, so that the services behave the
+// same whichever backend they run on.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/qu0ta/go-grpc-auth/internal/domain/models"
+	"github.com/qu0ta/go-grpc-auth/internal/services/auth"
+	"github.com/qu0ta/go-grpc-auth/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// noAdminApp is passed as the app of the service admins where no app is one.
+const noAdminApp int32 = 0
+
+// Run checks the storage returned by newStorage against the contract of
+// auth.Storage. Every subtest gets its own storage, which must be empty apart
+// from the built-in admin role.
+func Run(t *testing.T, newStorage func(t *testing.T) auth.Storage) {
+	for _, test := range []struct {
+		name string
+		run  func(t *testing.T, s auth.Storage)
+	}{
+		{"Apps", testApps},
+		{"DeleteApp", testDeleteApp},
+		{"Users", testUsers},
+		{"ChangeEmail", testChangeEmail},
+		{"DeleteUser", testDeleteUser},
+		{"Sessions", testSessions},
+		{"ConcurrentRotation", testConcurrentRotation},
+		{"ChangePassword", testChangePassword},
+		{"SigningKeys", testSigningKeys},
+		{"Roles", testRoles},
+		{"LastAdmin", testLastAdmin},
+		{"LastAdminPerApp", testLastAdminPerApp},
+		{"MFA", testMFA},
+		{"MFAChallenge", testMFAChallenge},
+		{"PasswordReset", testPasswordReset},
+		{"EmailVerification", testEmailVerification},
+		{"LoginFailures", testLoginFailures},
+		{"Tx", testTx},
+		{"ConcurrentTx", testConcurrentTx},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, newStorage(t))
+		})
+	}
+}
+
+func testApps(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+
+	app := models.App{
+		Name:           "app-" + gofakeit.UUID(),
+		Secret:         gofakeit.UUID(),
+		PasswordPolicy: models.DefaultPasswordPolicy,
+		Audiences:      []string{"https://api.example.com", "https://api.example.com"},
+		RedirectURIs:   []string{"https://example.com/a", "https://example.com/b"},
+	}
+	id, err := s.CreateApp(ctx, app, models.AuditEvent{Action: models.AuditAppCreated})
+	require.NoError(t, err)
+	app.ID = id
+
+	got, err := s.App(ctx, int32(id))
+	require.NoError(t, err)
+	assert.Equal(t, app.Name, got.Name)
+	assert.Equal(t, app.Secret, got.Secret)
+	assert.Equal(t, app.PasswordPolicy, got.PasswordPolicy)
+	assert.Equal(t, []string{"https://api.example.com"}, got.Audiences)
+	assert.ElementsMatch(t, app.RedirectURIs, got.RedirectURIs)
+	assert.False(t, got.Disabled)
+
+	_, err = s.CreateApp(ctx, models.App{Name: app.Name, Secret: gofakeit.UUID()}, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrAppExists)
+
+	_, err = s.App(ctx, int32(id)+1000)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	other := createApp(t, s)
+	apps, err := s.Apps(ctx)
+	require.NoError(t, err)
+	require.Len(t, apps, 2)
+	assert.Equal(t, id, apps[0].ID)
+	assert.Equal(t, int64(other), apps[1].ID)
+
+	app.Name = "app-" + gofakeit.UUID()
+	app.RequireVerifiedEmail = true
+	app.PasswordPolicy.MinLength = 16
+	app.Audiences = nil
+	app.RedirectURIs = []string{"https://example.org/callback"}
+	require.NoError(t, s.UpdateApp(ctx, app, models.AuditEvent{Action: models.AuditAppUpdated}))
+
+	got, err = s.App(ctx, int32(id))
+	require.NoError(t, err)
+	assert.Equal(t, app.Name, got.Name)
+	assert.Equal(t, app.Secret, got.Secret)
+	assert.True(t, got.RequireVerifiedEmail)
+	assert.Equal(t, 16, got.PasswordPolicy.MinLength)
+	assert.Empty(t, got.Audiences)
+	assert.Equal(t, app.RedirectURIs, got.RedirectURIs)
+
+	otherApp, err := s.App(ctx, other)
+	require.NoError(t, err)
+	err = s.UpdateApp(ctx, models.App{ID: id, Name: otherApp.Name}, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrAppExists)
+	err = s.UpdateApp(ctx, models.App{ID: id + 1000, Name: "app-" + gofakeit.UUID()}, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+
+	secret := gofakeit.UUID()
+	require.NoError(t, s.UpdateAppSecret(ctx, int32(id), secret, models.AuditEvent{Action: models.AuditAppSecretRotated}))
+	got, err = s.App(ctx, int32(id))
+	require.NoError(t, err)
+	assert.Equal(t, secret, got.Secret)
+	assert.ErrorIs(t, s.UpdateAppSecret(ctx, int32(id)+1000, secret, models.AuditEvent{}), storage.ErrAppNotFound)
+
+	userID := createUser(t, s, int32(id))
+	session := saveSession(t, s, userID, int32(id))
+
+	families, err := s.SetAppDisabled(ctx, int32(id), true, models.AuditEvent{Action: models.AuditAppDisabled})
+	require.NoError(t, err)
+	assert.Equal(t, []string{session.Family}, families)
+
+	got, err = s.App(ctx, int32(id))
+	require.NoError(t, err)
+	assert.True(t, got.Disabled)
+
+	families, err = s.SetAppDisabled(ctx, int32(id), true, models.AuditEvent{Action: models.AuditAppDisabled})
+	require.NoError(t, err)
+	assert.Empty(t, families)
+
+	_, err = s.SetAppDisabled(ctx, int32(id), false, models.AuditEvent{Action: models.AuditAppEnabled})
+	require.NoError(t, err)
+	got, err = s.App(ctx, int32(id))
+	require.NoError(t, err)
+	assert.False(t, got.Disabled)
+
+	_, err = s.SetAppDisabled(ctx, int32(id)+1000, true, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+}
+
+func testDeleteApp(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+
+	inUse := createApp(t, s)
+	userID := createUser(t, s, inUse)
+	require.NoError(t, s.MarkUserDeleted(ctx, userID))
+
+	err := s.DeleteApp(ctx, inUse, models.AuditEvent{Action: models.AuditAppDeleted})
+	assert.ErrorIs(t, err, storage.ErrAppInUse)
+
+	unused := createApp(t, s)
+	require.NoError(t, s.SaveSigningKey(ctx, models.SigningKey{ID: gofakeit.UUID(), AppID: unused, Algorithm: "EdDSA", PrivateKey: []byte("key")}))
+
+	admin, err := s.Role(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+	home := createApp(t, s)
+	adminID := createUser(t, s, home)
+	require.NoError(t, s.AssignRole(ctx, adminID, unused, admin.ID, models.AuditEvent{}))
+	err = s.DeleteApp(ctx, unused, models.AuditEvent{Action: models.AuditAppDeleted})
+	assert.ErrorIs(t, err, storage.ErrLastAdmin)
+
+	require.NoError(t, s.AssignRole(ctx, adminID, home, admin.ID, models.AuditEvent{}))
+	require.NoError(t, s.DeleteApp(ctx, unused, models.AuditEvent{Action: models.AuditAppDeleted}))
+
+	_, err = s.App(ctx, unused)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+	_, err = s.AppSigningKey(ctx, unused)
+	assert.ErrorIs(t, err, storage.ErrKeyNotFound)
+
+	err = s.DeleteApp(ctx, unused, models.AuditEvent{Action: models.AuditAppDeleted})
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+}
+
+func testUsers(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	otherAppID := createApp(t, s)
+
+	email := gofakeit.Email()
+	normalized := strings.ToLower(email)
+
+	userID, err := s.SaveUser(ctx, email, normalized, []byte("hash"), appID)
+	require.NoError(t, err)
+
+	_, err = s.SaveUser(ctx, strings.ToUpper(email), normalized, []byte("hash"), appID)
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	_, err = s.SaveUser(ctx, email, normalized, []byte("hash"), otherAppID)
+	assert.NoError(t, err, "apps have their own users")
+
+	user, err := s.User(ctx, appID, normalized)
+	require.NoError(t, err)
+	assert.Equal(t, models.User{
+		ID:              userID,
+		Email:           email,
+		NormalizedEmail: normalized,
+		PasswordHash:    []byte("hash"),
+		AppID:           appID,
+	}, user)
+
+	user, err = s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+	assert.Equal(t, normalized, user.NormalizedEmail)
+
+	_, err = s.User(ctx, appID, "missing-"+normalized)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.UserByID(ctx, userID+1000)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	require.NoError(t, s.UpdatePasswordHash(ctx, userID, []byte("stale"), []byte("rehashed")))
+	user, err = s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), user.PasswordHash, "the hash has changed since it was read")
+
+	require.NoError(t, s.UpdatePasswordHash(ctx, userID, []byte("hash"), []byte("rehashed")))
+	user, err = s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("rehashed"), user.PasswordHash)
+
+	isAdmin, err := s.IsAdmin(ctx, userID, appID)
+	require.NoError(t, err)
+	assert.False(t, isAdmin)
+	_, err = s.IsAdmin(ctx, userID+1000, appID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testChangeEmail(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	userID := createUser(t, s, appID)
+
+	user, err := s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	verification := models.EmailVerification{
+		UserID:    userID,
+		Email:     user.Email,
+		TokenHash: []byte(gofakeit.UUID()),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, s.SaveEmailVerification(ctx, verification))
+
+	email := gofakeit.Email()
+	require.NoError(t, s.ChangeEmail(ctx, userID, email, strings.ToLower(email)))
+
+	user, err = s.User(ctx, appID, strings.ToLower(email))
+	require.NoError(t, err)
+	assert.Equal(t, email, user.Email)
+	assert.Equal(t, strings.ToLower(email), user.NormalizedEmail)
+	assert.False(t, user.EmailVerified)
+
+	got, err := s.EmailVerification(ctx, verification.TokenHash)
+	require.NoError(t, err)
+	assert.True(t, got.Used, "verifications of the previous email are dropped")
+
+	otherID := createUser(t, s, appID)
+	err = s.ChangeEmail(ctx, otherID, strings.ToUpper(email), strings.ToLower(email))
+	assert.ErrorIs(t, err, storage.ErrUserExists)
+
+	err = s.ChangeEmail(ctx, userID+1000, gofakeit.Email(), gofakeit.UUID())
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testDeleteUser(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	admin, err := s.Role(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+
+	userID := createUser(t, s, appID)
+	user, err := s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	saveSession(t, s, userID, appID)
+	require.NoError(t, s.AssignRole(ctx, userID, appID, admin.ID, models.AuditEvent{UserID: userID}))
+
+	err = s.UnassignUserRoles(ctx, userID, noAdminApp)
+	assert.ErrorIs(t, err, storage.ErrLastAdmin)
+	assignments, err := s.UserRoleAssignments(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, assignments, 1, "the last admin keeps their roles")
+
+	otherAdmin := createUser(t, s, appID)
+	require.NoError(t, s.AssignRole(ctx, otherAdmin, appID, admin.ID, models.AuditEvent{}))
+
+	require.NoError(t, s.UnassignUserRoles(ctx, userID, noAdminApp))
+	assignments, err = s.UserRoleAssignments(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, assignments)
+
+	require.NoError(t, s.MarkUserDeleted(ctx, userID))
+	_, err = s.UserByID(ctx, userID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	_, err = s.User(ctx, appID, strings.ToLower(user.Email))
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+	err = s.MarkUserDeleted(ctx, userID)
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+
+	_, err = s.SaveUser(ctx, user.Email, strings.ToLower(user.Email), []byte("hash"), appID)
+	assert.ErrorIs(t, err, storage.ErrUserExists, "deleted users keep their email until purged")
+	_, err = s.AddLoginFailure(ctx, models.LoginScopeAccount, models.AccountLoginKey(appID, strings.ToLower(user.Email)), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	purged, err = s.PurgeDeletedUsers(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+
+	sessions, err := s.UserSessions(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	events, err := s.UserAuditEvents(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, events)
+	failures, err := s.LoginFailures(ctx, models.LoginScopeAccount, models.AccountLoginKey(appID, strings.ToLower(user.Email)))
+	require.NoError(t, err)
+	assert.Zero(t, failures.Failures, "the failed logins of the account are purged")
+
+	_, err = s.SaveUser(ctx, user.Email, strings.ToLower(user.Email), []byte("hash"), appID)
+	assert.NoError(t, err)
+}
+
+func testSessions(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	userID := createUser(t, s, appID)
+
+	session := saveSession(t, s, userID, appID)
+
+	got, err := s.Session(ctx, session.TokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, session.ID, got.ID)
+	assert.Equal(t, userID, got.UserID)
+	assert.Equal(t, appID, got.AppID)
+	assert.Equal(t, session.Family, got.Family)
+	assert.WithinDuration(t, session.ExpiresAt, got.ExpiresAt, time.Second)
+	assert.WithinDuration(t, time.Now(), got.CreatedAt, time.Minute)
+	assert.False(t, got.Rotated)
+	assert.False(t, got.Revoked)
+
+	_, err = s.Session(ctx, []byte("missing"))
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	require.NoError(t, s.RotateSession(ctx, session.ID))
+	assert.ErrorIs(t, s.RotateSession(ctx, session.ID), storage.ErrSessionRotated)
+
+	next := session
+	next.TokenHash = []byte(gofakeit.UUID())
+	next.ID, err = s.SaveSession(ctx, next)
+	require.NoError(t, err)
+	other := saveSession(t, s, userID, appID)
+
+	revoked, err := s.SessionFamilyRevoked(ctx, session.Family)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+	_, err = s.SessionFamilyRevoked(ctx, gofakeit.UUID())
+	assert.ErrorIs(t, err, storage.ErrSessionNotFound)
+
+	require.NoError(t, s.RevokeSessionFamily(ctx, session.Family))
+	revoked, err = s.SessionFamilyRevoked(ctx, session.Family)
+	require.NoError(t, err)
+	assert.True(t, revoked)
+	assert.ErrorIs(t, s.RotateSession(ctx, next.ID), storage.ErrSessionRotated, "revoked sessions cannot be rotated")
+
+	families, err := s.RevokeUserSessions(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{other.Family}, families, "only the families that were still active")
+
+	sessions, err := s.UserSessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	assert.Equal(t, session.ID, sessions[0].ID)
+	for _, session := range sessions {
+		assert.True(t, session.Revoked)
+	}
+}
+
+func testConcurrentRotation(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	session := saveSession(t, s, createUser(t, s, appID), appID)
+
+	const refreshes = 10
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range refreshes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := s.RotateSession(ctx, session.ID)
+			if err != nil {
+				assert.ErrorIs(t, err, storage.ErrSessionRotated)
+				return
+			}
+			mu.Lock()
+			succeeded++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, succeeded)
+}
+
+func testChangePassword(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	userID := createUser(t, s, appID)
+
+	current := saveSession(t, s, userID, appID)
+	other := saveSession(t, s, userID, appID)
+
+	families, err := s.ChangePassword(ctx, userID, []byte("new hash"), current.Family)
+	require.NoError(t, err)
+	assert.Equal(t, []string{other.Family}, families)
+
+	user, err := s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("new hash"), user.PasswordHash)
+
+	revoked, err := s.SessionFamilyRevoked(ctx, current.Family)
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	_, err = s.ChangePassword(ctx, userID+1000, []byte("new hash"), "")
+	assert.ErrorIs(t, err, storage.ErrUserNotFound)
+}
+
+func testSigningKeys(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	otherAppID := createApp(t, s)
+
+	first := models.SigningKey{ID: gofakeit.UUID(), AppID: appID, Algorithm: "EdDSA", PrivateKey: []byte("first")}
+	require.NoError(t, s.SaveSigningKey(ctx, first))
+	require.NoError(t, s.SaveSigningKey(ctx,
+		models.SigningKey{ID: gofakeit.UUID(), AppID: otherAppID, Algorithm: "EdDSA", PrivateKey: []byte("other")}))
+
+	err := s.SaveSigningKey(ctx, models.SigningKey{ID: first.ID, AppID: createApp(t, s), Algorithm: "EdDSA", PrivateKey: []byte("key")})
+	assert.ErrorIs(t, err, storage.ErrKeyExists, "the id is taken")
+	err = s.SaveSigningKey(ctx, models.SigningKey{ID: gofakeit.UUID(), AppID: appID, Algorithm: "EdDSA", PrivateKey: []byte("key")})
+	assert.ErrorIs(t, err, storage.ErrKeyExists, "the app already has an active key")
+
+	key, err := s.AppSigningKey(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, key.ID)
+	assert.Equal(t, first.PrivateKey, key.PrivateKey)
+	assert.Equal(t, models.KeyStatusActive, key.Status)
+	assert.True(t, key.RetiresAt.IsZero())
+
+	second := models.SigningKey{ID: gofakeit.UUID(), AppID: appID, Algorithm: "EdDSA", PrivateKey: []byte("second")}
+	retiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, s.RotateSigningKey(ctx, second, retiresAt, models.AuditEvent{Action: models.AuditSigningKeyRotated}))
+
+	err = s.RotateSigningKey(ctx, second, retiresAt, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrKeyExists)
+
+	key, err = s.AppSigningKey(ctx, appID)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, key.ID)
+
+	key, err = s.SigningKey(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.KeyStatusVerifying, key.Status)
+	assert.WithinDuration(t, retiresAt, key.RetiresAt, time.Second)
+
+	keys, err := s.SigningKeys(ctx, appID)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, first.ID, keys[0].ID)
+	assert.Equal(t, second.ID, keys[1].ID)
+
+	keys, err = s.SigningKeys(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+
+	third := models.SigningKey{ID: gofakeit.UUID(), AppID: appID, Algorithm: "EdDSA", PrivateKey: []byte("key")}
+	require.NoError(t, s.RotateSigningKey(ctx, third, time.Now().Add(-time.Second), models.AuditEvent{}))
+
+	key, err = s.SigningKey(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.KeyStatusRetired, key.Status)
+
+	keys, err = s.SigningKeys(ctx, appID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2, "retired keys are left out")
+
+	_, err = s.SigningKey(ctx, gofakeit.UUID())
+	assert.ErrorIs(t, err, storage.ErrKeyNotFound)
+}
+
+func testRoles(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	otherAppID := createApp(t, s)
+	userID := createUser(t, s, appID)
+
+	admin, err := s.Role(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.PermissionAll}, admin.Permissions)
+
+	name := "role-" + gofakeit.UUID()
+	roleID, err := s.CreateRole(ctx, models.Role{Name: name, Permissions: []string{"b", "a", "b"}},
+		models.AuditEvent{UserID: userID, AppID: appID, Action: models.AuditRoleCreated})
+	require.NoError(t, err)
+
+	_, err = s.CreateRole(ctx, models.Role{Name: name}, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrRoleExists)
+
+	role, err := s.Role(ctx, name)
+	require.NoError(t, err)
+	assert.Equal(t, roleID, role.ID)
+	assert.Equal(t, []string{"a", "b"}, role.Permissions)
+
+	_, err = s.Role(ctx, "missing-"+name)
+	assert.ErrorIs(t, err, storage.ErrRoleNotFound)
+
+	for range 2 {
+		require.NoError(t, s.AssignRole(ctx, userID, appID, roleID,
+			models.AuditEvent{UserID: userID, AppID: appID, Action: models.AuditRoleAssigned}))
+	}
+	require.NoError(t, s.AssignRole(ctx, userID, otherAppID, admin.ID, models.AuditEvent{}))
+
+	roles, err := s.UserRoles(ctx, userID, appID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{name}, roles)
+
+	assignments, err := s.UserRoleAssignments(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []models.RoleAssignment{{AppID: appID, Role: name}, {AppID: otherAppID, Role: models.RoleAdmin}}, assignments)
+
+	has, err := s.HasPermission(ctx, userID, appID, "a")
+	require.NoError(t, err)
+	assert.True(t, has)
+	has, err = s.HasPermission(ctx, userID, appID, "c")
+	require.NoError(t, err)
+	assert.False(t, has)
+	has, err = s.HasPermission(ctx, userID, otherAppID, "c")
+	require.NoError(t, err)
+	assert.True(t, has, "the admin role holds every permission")
+
+	isAdmin, err := s.IsAdmin(ctx, userID, otherAppID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+	isAdmin, err = s.IsAdmin(ctx, userID, appID)
+	require.NoError(t, err)
+	assert.False(t, isAdmin, "the admin role is held in another app")
+
+	for range 2 {
+		require.NoError(t, s.UnassignRole(ctx, userID, appID, roleID, noAdminApp,
+			models.AuditEvent{UserID: userID, AppID: appID, Action: models.AuditRoleUnassigned}))
+	}
+	roles, err = s.UserRoles(ctx, userID, appID)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	events, err := s.UserAuditEvents(ctx, userID)
+	require.NoError(t, err)
+	var actions []string
+	for _, event := range events {
+		assert.Equal(t, appID, event.AppID)
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{models.AuditRoleCreated, models.AuditRoleAssigned, models.AuditRoleUnassigned}, actions,
+		"no-ops are not recorded")
+}
+
+func testLastAdmin(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	admin, err := s.Role(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+
+	first := createUser(t, s, appID)
+	second := createUser(t, s, appID)
+	require.NoError(t, s.AssignRole(ctx, first, appID, admin.ID, models.AuditEvent{}))
+	require.NoError(t, s.AssignRole(ctx, second, appID, admin.ID, models.AuditEvent{}))
+
+	require.NoError(t, s.UnassignRole(ctx, first, appID, admin.ID, noAdminApp, models.AuditEvent{}))
+
+	err = s.UnassignRole(ctx, second, appID, admin.ID, noAdminApp, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrLastAdmin)
+
+	isAdmin, err := s.IsAdmin(ctx, second, appID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin, "a failed unassignment changes nothing")
+}
+
+func testLastAdminPerApp(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	admin, err := s.Role(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+
+	firstApp, secondApp := createApp(t, s), createApp(t, s)
+	first := createUser(t, s, firstApp)
+	second := createUser(t, s, secondApp)
+	require.NoError(t, s.AssignRole(ctx, first, firstApp, admin.ID, models.AuditEvent{}))
+	require.NoError(t, s.AssignRole(ctx, second, secondApp, admin.ID, models.AuditEvent{}))
+
+	err = s.UnassignRole(ctx, first, firstApp, admin.ID, noAdminApp, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrLastAdmin, "an admin of another app does not count")
+	err = s.UnassignUserRoles(ctx, second, noAdminApp)
+	assert.ErrorIs(t, err, storage.ErrLastAdmin, "an admin of another app does not count")
+
+	adminApp := createApp(t, s)
+	serviceAdmin := createUser(t, s, adminApp)
+	require.NoError(t, s.AssignRole(ctx, serviceAdmin, adminApp, admin.ID, models.AuditEvent{}))
+
+	require.NoError(t, s.UnassignRole(ctx, first, firstApp, admin.ID, adminApp, models.AuditEvent{}),
+		"the service admins still manage the app")
+	require.NoError(t, s.UnassignUserRoles(ctx, second, adminApp))
+
+	err = s.UnassignRole(ctx, serviceAdmin, adminApp, admin.ID, adminApp, models.AuditEvent{})
+	assert.ErrorIs(t, err, storage.ErrLastAdmin)
+}
+
+func testMFA(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	userID := createUser(t, s, createApp(t, s))
+
+	_, err := s.MFA(ctx, userID)
+	assert.ErrorIs(t, err, storage.ErrMFANotFound)
+
+	require.NoError(t, s.SaveMFASecret(ctx, userID, "first"))
+	require.NoError(t, s.SaveMFASecret(ctx, userID, "second"))
+
+	mfa, err := s.MFA(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, userID, mfa.UserID)
+	assert.Equal(t, "second", mfa.Secret)
+	assert.False(t, mfa.Confirmed)
+
+	require.NoError(t, s.ConfirmMFA(ctx, userID, 10, [][]byte{[]byte("a"), []byte("b")}))
+	assert.ErrorIs(t, s.ConfirmMFA(ctx, userID, 11, nil), storage.ErrMFAConfirmed)
+	assert.ErrorIs(t, s.SaveMFASecret(ctx, userID, "third"), storage.ErrMFAConfirmed)
+
+	mfa, err = s.MFA(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, mfa.Confirmed)
+	assert.EqualValues(t, 10, mfa.LastStep)
+
+	assert.ErrorIs(t, s.UseMFAStep(ctx, userID, 10), storage.ErrMFAStepUsed)
+	require.NoError(t, s.UseMFAStep(ctx, userID, 11))
+	assert.ErrorIs(t, s.UseMFAStep(ctx, userID, 11), storage.ErrMFAStepUsed)
+
+	left, err := s.RecoveryCodesLeft(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, left)
+
+	require.NoError(t, s.UseRecoveryCode(ctx, userID, []byte("a")))
+	assert.ErrorIs(t, s.UseRecoveryCode(ctx, userID, []byte("a")), storage.ErrRecoveryCodeNotFound)
+	assert.ErrorIs(t, s.UseRecoveryCode(ctx, userID, []byte("c")), storage.ErrRecoveryCodeNotFound)
+
+	left, err = s.RecoveryCodesLeft(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, left)
+}
+
+func testMFAChallenge(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	userID := createUser(t, s, appID)
+
+	challenge := models.MFAChallenge{
+		TokenHash: []byte(gofakeit.UUID()),
+		UserID:    userID,
+		AppID:     appID,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	require.NoError(t, s.SaveMFAChallenge(ctx, challenge))
+
+	got, err := s.MFAChallenge(ctx, challenge.TokenHash)
+	require.NoError(t, err)
+	assert.NotZero(t, got.ID)
+	assert.Equal(t, userID, got.UserID)
+	assert.Equal(t, appID, got.AppID)
+	assert.Zero(t, got.Attempts)
+	assert.WithinDuration(t, challenge.ExpiresAt, got.ExpiresAt, time.Second)
+	assert.False(t, got.Used)
+
+	require.NoError(t, s.FailMFAChallenge(ctx, got.ID))
+	require.NoError(t, s.FailMFAChallenge(ctx, got.ID))
+	require.NoError(t, s.UseMFAChallenge(ctx, got.ID))
+	assert.ErrorIs(t, s.UseMFAChallenge(ctx, got.ID), storage.ErrChallengeUsed)
+
+	got, err = s.MFAChallenge(ctx, challenge.TokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Attempts)
+	assert.True(t, got.Used)
+
+	_, err = s.MFAChallenge(ctx, []byte("missing"))
+	assert.ErrorIs(t, err, storage.ErrChallengeNotFound)
+}
+
+func testPasswordReset(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	userID := createUser(t, s, appID)
+	var resets []models.PasswordReset
+	for range 2 {
+		reset := models.PasswordReset{UserID: userID, TokenHash: []byte(gofakeit.UUID()), ExpiresAt: time.Now().Add(time.Hour)}
+		require.NoError(t, s.SavePasswordReset(ctx, reset))
+
+		got, err := s.PasswordReset(ctx, reset.TokenHash)
+		require.NoError(t, err)
+		assert.Equal(t, userID, got.UserID)
+		assert.WithinDuration(t, reset.ExpiresAt, got.ExpiresAt, time.Second)
+		assert.False(t, got.Used)
+		resets = append(resets, got)
+	}
+
+	require.NoError(t, s.UsePasswordReset(ctx, resets[0]))
+	got, err := s.PasswordReset(ctx, resets[0].TokenHash)
+	require.NoError(t, err)
+	assert.True(t, got.Used)
+
+	err = s.UsePasswordReset(ctx, resets[0])
+	assert.ErrorIs(t, err, storage.ErrResetUsed)
+	err = s.UsePasswordReset(ctx, resets[1])
+	assert.ErrorIs(t, err, storage.ErrResetUsed, "every pending reset of the user is used")
+
+	_, err = s.PasswordReset(ctx, []byte("missing"))
+	assert.ErrorIs(t, err, storage.ErrResetNotFound)
+}
+
+func testEmailVerification(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	userID := createUser(t, s, appID)
+	user, err := s.UserByID(ctx, userID)
+	require.NoError(t, err)
+
+	stale := models.EmailVerification{
+		UserID:    userID,
+		Email:     "old-" + user.Email,
+		TokenHash: []byte(gofakeit.UUID()),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, s.SaveEmailVerification(ctx, stale))
+	stale, err = s.EmailVerification(ctx, stale.TokenHash)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.VerifyEmail(ctx, stale), storage.ErrVerificationNotFound)
+	stale, err = s.EmailVerification(ctx, stale.TokenHash)
+	require.NoError(t, err)
+	assert.False(t, stale.Used, "a failed verification changes nothing")
+
+	verification := models.EmailVerification{
+		UserID:    userID,
+		Email:     user.Email,
+		TokenHash: []byte(gofakeit.UUID()),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, s.SaveEmailVerification(ctx, verification))
+	verification, err = s.EmailVerification(ctx, verification.TokenHash)
+	require.NoError(t, err)
+	assert.Equal(t, user.Email, verification.Email)
+
+	require.NoError(t, s.VerifyEmail(ctx, verification))
+	assert.ErrorIs(t, s.VerifyEmail(ctx, verification), storage.ErrVerificationUsed)
+	assert.ErrorIs(t, s.VerifyEmail(ctx, stale), storage.ErrVerificationUsed, "every pending verification of the user is used")
+
+	user, err = s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.True(t, user.EmailVerified)
+
+	_, err = s.EmailVerification(ctx, []byte("missing"))
+	assert.ErrorIs(t, err, storage.ErrVerificationNotFound)
+}
+
+func testLoginFailures(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	userID := createUser(t, s, createApp(t, s))
+	key := gofakeit.UUID()
+
+	failures, err := s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Equal(t, models.LoginFailures{Scope: models.LoginScopeAccount, Key: key}, failures)
+
+	for want := 1; want <= 2; want++ {
+		n, err := s.AddLoginFailure(ctx, models.LoginScopeAccount, key, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, want, n)
+	}
+
+	until := time.Now().Add(time.Minute)
+	require.NoError(t, s.BlockLogin(ctx, models.LoginScopeAccount, key, until))
+
+	failures, err = s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Equal(t, 2, failures.Failures)
+	assert.WithinDuration(t, until, failures.BlockedUntil, time.Second)
+	assert.WithinDuration(t, time.Now(), failures.LastFailureAt, time.Minute)
+
+	require.NoError(t, s.BlockLogin(ctx, models.LoginScopeAccount, key, time.Now().Add(time.Second)))
+	require.NoError(t, s.RemoveLoginFailure(ctx, models.LoginScopeAccount, key))
+	failures, err = s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Equal(t, 1, failures.Failures)
+	assert.WithinDuration(t, until, failures.BlockedUntil, time.Second, "a shorter block does not replace a longer one")
+
+	failures, err = s.LoginFailures(ctx, models.LoginScopeIP, key)
+	require.NoError(t, err)
+	assert.Zero(t, failures.Failures, "scopes have their own counters")
+
+	n, err := s.AddLoginFailure(ctx, models.LoginScopeAccount, key, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, n, "failures before since are forgotten")
+	require.NoError(t, s.RemoveLoginFailure(ctx, models.LoginScopeAccount, key))
+	require.NoError(t, s.RemoveLoginFailure(ctx, models.LoginScopeAccount, key))
+	failures, err = s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.True(t, failures.BlockedUntil.IsZero())
+	assert.Zero(t, failures.Failures, "the counter does not go below zero")
+
+	require.NoError(t, s.ResetLoginFailures(ctx, models.LoginScopeAccount, key))
+	failures, err = s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Zero(t, failures.Failures)
+
+	_, err = s.AddLoginFailure(ctx, models.LoginScopeAccount, key, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	for range 2 {
+		require.NoError(t, s.UnlockLogin(ctx, models.LoginScopeAccount, key,
+			models.AuditEvent{UserID: userID, Action: models.AuditAccountUnlocked}))
+	}
+	failures, err = s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Zero(t, failures.Failures)
+
+	events, err := s.UserAuditEvents(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, events, 1, "unlocking an account without failures is not recorded")
+	assert.Equal(t, models.AuditAccountUnlocked, events[0].Action)
+	assert.Zero(t, events[0].AppID)
+}
+
+func testTx(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	appID := createApp(t, s)
+	admin, err := s.Role(ctx, models.RoleAdmin)
+	require.NoError(t, err)
+
+	var userID int64
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		userID = createUser(t, tx, appID)
+		if err := tx.AssignRole(ctx, userID, appID, admin.ID, models.AuditEvent{Action: models.AuditRoleAssigned}); err != nil {
+			return err
+		}
+		saveSession(t, tx, userID, appID)
+
+		isAdmin, err := tx.IsAdmin(ctx, userID, appID)
+		require.NoError(t, err)
+		assert.True(t, isAdmin, "the transaction sees its own writes")
+
+		return tx.WithTx(ctx, func(tx storage.Storage) error {
+			_, err := tx.RevokeUserSessions(ctx, userID)
+			return err
+		})
+	})
+	require.NoError(t, err)
+
+	isAdmin, err := s.IsAdmin(ctx, userID, appID)
+	require.NoError(t, err)
+	assert.True(t, isAdmin)
+	sessions, err := s.UserSessions(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.True(t, sessions[0].Revoked, "the nested transaction is committed with the enclosing one")
+
+	errRollback := errors.New("rollback")
+	otherUserID := createUser(t, s, appID)
+	var otherAppID int32
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		otherAppID = createApp(t, tx)
+		createUser(t, tx, otherAppID)
+		require.NoError(t, tx.MarkUserDeleted(ctx, otherUserID))
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	_, err = s.App(ctx, otherAppID)
+	assert.ErrorIs(t, err, storage.ErrAppNotFound)
+	_, err = s.UserByID(ctx, otherUserID)
+	assert.NoError(t, err, "the deletion is rolled back")
+
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.ChangePassword(ctx, userID, []byte("new hash"), ""); err != nil {
+			return err
+		}
+		return tx.UnassignRole(ctx, userID, appID, admin.ID, noAdminApp, models.AuditEvent{Action: models.AuditRoleUnassigned})
+	})
+	assert.ErrorIs(t, err, storage.ErrLastAdmin)
+
+	user, err := s.UserByID(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hash"), user.PasswordHash, "a failed operation rolls back the whole transaction")
+}
+
+// testConcurrentTx runs transactions reading before they write, which must
+// all commit even though they contend for the same rows.
+func testConcurrentTx(t *testing.T, s auth.Storage) {
+	ctx := context.Background()
+	key := gofakeit.UUID()
+
+	const txs = 10
+	var wg sync.WaitGroup
+	for range txs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := s.WithTx(ctx, func(tx storage.Storage) error {
+				if _, err := tx.LoginFailures(ctx, models.LoginScopeAccount, key); err != nil {
+					return err
+				}
+				_, err := tx.AddLoginFailure(ctx, models.LoginScopeAccount, key, time.Now().Add(-time.Hour))
+				return err
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	failures, err := s.LoginFailures(ctx, models.LoginScopeAccount, key)
+	require.NoError(t, err)
+	assert.Equal(t, txs, failures.Failures)
+}
+
+// createApp creates an app with a unique name and returns its id.
+func createApp(t *testing.T, s auth.Storage) int32 {
+	t.Helper()
+
+	id, err := s.CreateApp(context.Background(), models.App{
+		Name:           "app-" + gofakeit.UUID(),
+		Secret:         gofakeit.UUID(),
+		PasswordPolicy: models.DefaultPasswordPolicy,
+	}, models.AuditEvent{Action: models.AuditAppCreated})
+	require.NoError(t, err)
+	return int32(id)
+}
+
+// createUser registers a user with a random email in the app and returns their id.
+func createUser(t *testing.T, s auth.Storage, appID int32) int64 {
+	t.Helper()
+
+	email := gofakeit.Email()
+	id, err := s.SaveUser(context.Background(), email, strings.ToLower(email), []byte("hash"), appID)
+	require.NoError(t, err)
+	return id
+}
+
+// saveSession saves a session of a new family, valid for an hour.
+func saveSession(t *testing.T, s auth.Storage, userID int64, appID int32) models.Session {
+	t.Helper()
+
+	session := models.Session{
+		UserID:    userID,
+		AppID:     appID,
+		Family:    gofakeit.UUID(),
+		TokenHash: []byte(gofakeit.UUID()),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	id, err := s.SaveSession(context.Background(), session)
+	require.NoError(t, err)
+	session.ID = id
+	return session
+}
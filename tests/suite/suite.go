@@ -2,17 +2,21 @@ package suite
 
 import (
 	"context"
+	authv1 "github.com/qu0ta/go-grpc-auth/gen/go/auth"
 	"github.com/qu0ta/go-grpc-auth/internal/config"
-	authv1 "github.com/qu0ta/pet-proto/gen/go/auth"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"math/rand/v2"
+	"net"
 	"testing"
 )
 
 type Suite struct {
 	*testing.T
-	Cfg        *config.Config
-	AuthClient authv1.AuthClient
+	Cfg         *config.Config
+	AuthClient  authv1.AuthClient
+	AdminClient authv1.AdminClient
+	AppClient   authv1.AppServiceClient
 }
 
 func New(t *testing.T) (context.Context, *Suite) {
@@ -28,16 +32,31 @@ func New(t *testing.T) (context.Context, *Suite) {
 		cancelCtx()
 	})
 
-	cc, err := grpc.NewClient("localhost:50000",
+	cc, err := grpc.NewClient("127.0.0.1:50000",
 		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(loopbackDialer()),
 	)
 	if err != nil {
 		t.Fatal(err)
 	}
 	return ctx, &Suite{
-		T:          t,
-		Cfg:        cfg,
-		AuthClient: authv1.NewAuthClient(cc),
+		T:           t,
+		Cfg:         cfg,
+		AuthClient:  authv1.NewAuthClient(cc),
+		AdminClient: authv1.NewAdminClient(cc),
+		AppClient:   authv1.NewAppServiceClient(cc),
 	}
 
 }
+
+// loopbackDialer returns a dialer connecting from a random address of
+// 127.0.0.0/8, so that the failed logins of a test, which the service counts
+// per client address, do not add up with those of other tests and runs.
+func loopbackDialer() func(ctx context.Context, addr string) (net.Conn, error) {
+	d := net.Dialer{LocalAddr: &net.TCPAddr{
+		IP: net.IPv4(127, byte(rand.IntN(256)), byte(rand.IntN(256)), byte(2+rand.IntN(253))),
+	}}
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+}